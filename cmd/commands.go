@@ -0,0 +1,540 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"glusterfs-plugin/internal/admin"
+	"glusterfs-plugin/internal/backend"
+	"glusterfs-plugin/internal/utils"
+)
+
+// commandTimeout bounds the admin API calls of a command.
+const commandTimeout = 30 * time.Second
+
+// commands are the subcommands of the plugin binary besides serve.
+var commands = map[string]func(args []string, stdout io.Writer) error{
+	"ls":       runList,
+	"inspect":  runInspect,
+	"mounts":   runMounts,
+	"unmount":  runUnmount,
+	"remount":  runRemount,
+	"config":   runConfig,
+	"reload":   runReload,
+	"stale":    runStale,
+	"validate": runValidate,
+	"explain":  runExplain,
+	"doctor":   runDoctor,
+	"version":  runVersion,
+	"snapshot": runSnapshot,
+}
+
+// runCommand runs a subcommand of the plugin binary.
+//
+// Parameters:
+// - name: The subcommand
+// - args: The arguments after the subcommand
+// - stdout: Receives the command output
+//
+// Returns:
+// - error if the command is unknown or fails
+func runCommand(name string, args []string, stdout io.Writer) error {
+	run, ok := commands[name]
+	if !ok {
+		names := []string{"serve"}
+		for command := range commands {
+			names = append(names, command)
+		}
+		sort.Strings(names[1:])
+		return fmt.Errorf("unknown command %q, must be one of %s", name, strings.Join(names, ", "))
+	}
+	return run(args, stdout)
+}
+
+// adminFlags returns the flag set of a command talking to the admin API.
+func adminFlags(name, usage string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	socket := fs.String("socket", envString("ADMIN_SOCKET", admin.DefaultSocketPath), "Admin socket of the running plugin (env ADMIN_SOCKET)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s\n", usage)
+		fs.PrintDefaults()
+	}
+	return fs, socket
+}
+
+// parseArgs parses flags placed before or after the positional arguments
+// and checks their number.
+func parseArgs(fs *flag.FlagSet, args []string, positional int) ([]string, error) {
+	var rest []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		rest = append(rest, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(rest) != positional {
+		fs.Usage()
+		return nil, fmt.Errorf("%s takes %d argument(s), got %d", fs.Name(), positional, len(rest))
+	}
+	return rest, nil
+}
+
+func commandContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), commandTimeout)
+}
+
+// runList prints the registered volumes.
+func runList(args []string, stdout io.Writer) error {
+	fs, socket := adminFlags("ls", "ls")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	volumes, err := admin.NewClient(*socket).Volumes(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tMOUNTPOINT\tDISCOVERED")
+	for _, v := range volumes {
+		discovered, _ := v.Status["discovered"].(bool)
+		fmt.Fprintf(w, "%s\t%s\t%t\n", v.Name, v.Mountpoint, discovered)
+	}
+	return w.Flush()
+}
+
+// runInspect prints a volume with its status as JSON.
+func runInspect(args []string, stdout io.Writer) error {
+	fs, socket := adminFlags("inspect", "inspect <volume>")
+	rest, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	v, err := admin.NewClient(*socket).Volume(ctx, rest[0])
+	if err != nil {
+		return err
+	}
+	return printJSON(stdout, v)
+}
+
+// runMounts prints the active mounts.
+func runMounts(args []string, stdout io.Writer) error {
+	fs, socket := adminFlags("mounts", "mounts")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	mounts, err := admin.NewClient(*socket).Mounts(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tMOUNTPOINT\tREFS\tTYPE\tSERVERS")
+	for _, m := range mounts {
+		typ := m.Type
+		if m.SharedFrom != "" {
+			typ += " of " + m.SharedFrom
+		}
+		servers := "-"
+		if len(m.Servers) > 0 {
+			servers = strings.Join(m.Servers, ",")
+		}
+		if m.Stale {
+			servers += " (stale)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", m.Name, m.Mountpoint, m.Refs, typ, servers)
+	}
+	return w.Flush()
+}
+
+// runStale lists the mounts still built from the servers the plugin was
+// configured with before a reload, whose containers must be restarted
+// to use the current servers.
+func runStale(args []string, stdout io.Writer) error {
+	fs, socket := adminFlags("stale", "stale")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	stale, err := admin.NewClient(*socket).StaleMounts(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(stale) == 0 {
+		fmt.Fprintln(stdout, "no mounts use stale servers")
+		return nil
+	}
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tREFS\tSERVERS")
+	for _, m := range stale {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", m.Name, m.Refs, strings.Join(m.Servers, ","))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, "restart the containers using these volumes to mount them from the current servers")
+	return nil
+}
+
+// runUnmount forcibly unmounts a volume. Docker unmounts volumes itself,
+// so the command only exists in its forced form.
+func runUnmount(args []string, stdout io.Writer) error {
+	fs, socket := adminFlags("unmount", "unmount --force <volume>")
+	force := fs.Bool("force", false, "Unmount even if containers still use the volume")
+	rest, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if !*force {
+		return fmt.Errorf("unmount requires --force, Docker unmounts volumes when containers stop")
+	}
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	if err := admin.NewClient(*socket).ForceUnmount(ctx, rest[0]); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "unmounted %s\n", rest[0])
+	return nil
+}
+
+// runRemount unmounts a volume and mounts it again, for volumes whose
+// client hangs or lost its servers.
+func runRemount(args []string, stdout io.Writer) error {
+	fs, socket := adminFlags("remount", "remount <volume>")
+	rest, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	if err := admin.NewClient(*socket).Remount(ctx, rest[0]); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "remounted %s\n", rest[0])
+	return nil
+}
+
+// runConfig prints the settings of the running plugin, with secrets
+// redacted.
+func runConfig(args []string, stdout io.Writer) error {
+	fs, socket := adminFlags("config", "config")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	config, err := admin.NewClient(*socket).Config(ctx)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(config))
+	for name := range config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(w, "%s\t%s\n", name, config[name])
+	}
+	return w.Flush()
+}
+
+// runReload makes the running plugin re-read its configuration and
+// prints what changed.
+func runReload(args []string, stdout io.Writer) error {
+	fs, socket := adminFlags("reload", "reload [-set name=value]...")
+	overrides := optionsFlag{}
+	fs.Var(overrides, "set", "Setting as name=value on top of the config file, may be repeated")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	result, err := admin.NewClient(*socket).Reload(ctx, overrides)
+	if err != nil {
+		return err
+	}
+
+	if len(result.Applied) == 0 && len(result.Pending) == 0 {
+		fmt.Fprintln(stdout, "nothing changed")
+		return nil
+	}
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SETTING\tOLD\tNEW\tSTATUS")
+	for _, name := range sortedNames(result.Applied) {
+		change := result.Applied[name]
+		fmt.Fprintf(w, "%s\t%s\t%s\tapplied\n", name, quoteArg(change.Old), quoteArg(change.New))
+	}
+	for _, name := range sortedNames(result.Pending) {
+		change := result.Pending[name]
+		fmt.Fprintf(w, "%s\t%s\t%s\trestart required\n", name, quoteArg(change.Old), quoteArg(change.New))
+	}
+	return w.Flush()
+}
+
+// optionsFlag collects repeated -o key=value flags.
+type optionsFlag map[string]string
+
+func (o optionsFlag) String() string {
+	var pairs []string
+	for k, v := range o {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (o optionsFlag) Set(value string) error {
+	key, v, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("option %q must be key=value", value)
+	}
+	o[key] = v
+	return nil
+}
+
+// runValidate validates a create request without creating the volume
+// and prints the mount arguments it would use.
+func runValidate(args []string, stdout io.Writer) error {
+	fs, socket := adminFlags("validate", "validate <volume> [-o key=value]...")
+	options := optionsFlag{}
+	fs.Var(options, "o", "Volume option as key=value, may be repeated")
+	rest, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	resp, err := admin.NewClient(*socket).Validate(ctx, &admin.ValidateRequest{Name: rest[0], Options: options})
+	if err != nil {
+		return err
+	}
+	if resp.Error != "" {
+		return fmt.Errorf("invalid: %s", resp.Error)
+	}
+
+	fmt.Fprintln(stdout, "valid")
+	quoted := make([]string, len(resp.Args))
+	for i, arg := range resp.Args {
+		quoted[i] = quoteArg(arg)
+	}
+	fmt.Fprintln(stdout, strings.Join(quoted, " "))
+	return nil
+}
+
+// runExplain explains how the plugin would handle a create request:
+// the validation rule rejecting it, or its servers and mount arguments
+// with the reason of each.
+func runExplain(args []string, stdout io.Writer) error {
+	fs, socket := adminFlags("explain", "explain <volume> [-o key=value]... [-json]")
+	options := optionsFlag{}
+	fs.Var(options, "o", "Volume option as key=value, may be repeated")
+	asJSON := fs.Bool("json", false, "Print the explanation as JSON")
+	rest, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	e, err := admin.NewClient(*socket).Explain(ctx, &admin.ValidateRequest{Name: rest[0], Options: options})
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(stdout, e)
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "volume:\t%s\n", e.Name)
+	fmt.Fprintf(w, "options:\t%s\n", optionsFlag(e.Options))
+	if e.Valid {
+		fmt.Fprintf(w, "result:\tvalid\n")
+	} else {
+		fmt.Fprintf(w, "result:\tinvalid, %s\n", e.Error)
+		fmt.Fprintf(w, "rule:\t%s: %s\n", e.Rule, e.RuleDescription)
+	}
+	if e.Backend != "" {
+		fmt.Fprintf(w, "backend:\t%s\n", e.Backend)
+	}
+	if e.SharedFrom != "" {
+		fmt.Fprintf(w, "shared from:\t%s\n", e.SharedFrom)
+	}
+	if e.ServersSource != "" {
+		fmt.Fprintf(w, "servers:\t%s (from %s)\n", strings.Join(e.Servers, ", "), e.ServersSource)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(e.Args) > 0 {
+		fmt.Fprintln(stdout, "argv:")
+		w = tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+		for _, arg := range e.Args {
+			fmt.Fprintf(w, "  %s\t%s\n", quoteArg(arg.Value), arg.Reason)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	if !e.Valid {
+		return fmt.Errorf("invalid: %s", e.Error)
+	}
+	return nil
+}
+
+// quoteArg quotes an argument for display if the shell would split it.
+func quoteArg(arg string) string {
+	if arg == "" || strings.ContainsAny(arg, " \t\"'\\$") {
+		return strconv.Quote(arg)
+	}
+	return arg
+}
+
+// runVersion prints the version of the plugin binary.
+func runVersion(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("version", flag.ContinueOnError)
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, version)
+	return nil
+}
+
+// doctor checks the environment the plugin needs.
+type doctor struct {
+	glusterfsBinary string
+	glusterBinary   string
+	fuseDevice      string
+	pluginSocket    string
+	client          *admin.Client
+	servers         []string
+}
+
+// check is the outcome of a doctor check.
+type check struct {
+	name   string
+	err    error
+	detail string
+
+	// optional checks only warn
+	optional bool
+}
+
+// run performs the checks, prints one line per check and returns the
+// number of failed mandatory checks.
+func (d *doctor) run(ctx context.Context, w io.Writer) int {
+	var checks []check
+
+	for _, binary := range []struct {
+		name, path string
+		optional   bool
+	}{
+		{"glusterfs client", d.glusterfsBinary, false},
+		{"gluster CLI (quotas, provisioning, snapshots)", d.glusterBinary, true},
+	} {
+		path, err := exec.LookPath(binary.path)
+		checks = append(checks, check{name: binary.name, err: err, detail: path, optional: binary.optional})
+	}
+
+	for _, file := range []struct{ name, path string }{
+		{"FUSE device", d.fuseDevice},
+		{"plugin socket", d.pluginSocket},
+	} {
+		_, err := os.Stat(file.path)
+		checks = append(checks, check{name: file.name, err: err, detail: file.path})
+	}
+
+	mounts, err := d.client.Mounts(ctx)
+	checks = append(checks, check{
+		name:   "admin API",
+		err:    err,
+		detail: fmt.Sprintf("%s, %d active mount(s)", d.client.SocketPath, len(mounts)),
+	})
+
+	if len(d.servers) > 0 {
+		err := backend.NewGlusterfs().HealthCheck(ctx, d.servers)
+		checks = append(checks, check{name: "glusterd", err: err, detail: strings.Join(d.servers, ", ")})
+	} else {
+		checks = append(checks, check{name: "glusterd", err: fmt.Errorf("SERVERS is not set"), optional: true})
+	}
+
+	failed := 0
+	for _, c := range checks {
+		switch {
+		case c.err == nil:
+			fmt.Fprintf(w, "ok    %s: %s\n", c.name, c.detail)
+		case c.optional:
+			fmt.Fprintf(w, "warn  %s: %v\n", c.name, c.err)
+		default:
+			fmt.Fprintf(w, "FAIL  %s: %v\n", c.name, c.err)
+			failed++
+		}
+	}
+	return failed
+}
+
+// runDoctor checks the binaries, devices and sockets the plugin needs
+// and whether glusterd answers on the configured servers.
+func runDoctor(args []string, stdout io.Writer) error {
+	fs, socket := adminFlags("doctor", "doctor [-servers a,b]")
+	servers := fs.String("servers", envString("SERVERS", ""), "Comma separated list of GlusterFS servers (env SERVERS)")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	d := &doctor{
+		glusterfsBinary: "glusterfs",
+		glusterBinary:   "gluster",
+		fuseDevice:      "/dev/fuse",
+		pluginSocket:    utils.SocketPath,
+		client:          admin.NewClient(*socket),
+		servers:         backend.SplitList(*servers),
+	}
+
+	ctx, cancel := commandContext()
+	defer cancel()
+	if failed := d.run(ctx, stdout); failed > 0 {
+		return fmt.Errorf("%d check(s) failed", failed)
+	}
+	return nil
+}
+
+// printJSON prints value as indented JSON.
+func printJSON(w io.Writer, value interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(value)
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"glusterfs-plugin/internal/admin"
+	"glusterfs-plugin/internal/backend"
+	"glusterfs-plugin/internal/backend/backendtest"
+	"glusterfs-plugin/internal/driver"
+	"glusterfs-plugin/pkg/volume"
+)
+
+// serveAdmin serves the admin API of a driver with a fake backend and
+// points the admin commands at it through ADMIN_SOCKET.
+func serveAdmin(t *testing.T) (*driver.GFSDriver, *backendtest.Fake) {
+	t.Helper()
+	d := driver.NewDriver([]string{"server1"})
+	d.Root = filepath.Join(t.TempDir(), "mnt")
+	fake := backendtest.New("glusterfs")
+	d.RegisterBackend(fake)
+
+	dir, err := os.MkdirTemp("", "admin")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "admin.sock")
+	t.Setenv("ADMIN_SOCKET", path)
+
+	server := admin.NewServer(d)
+	server.Settings = func() map[string]string {
+		return map[string]string{"servers": "server1", "api-token": "secret"}
+	}
+	server.Reload = func(overrides map[string]string) (*admin.ReloadResult, error) {
+		result := &admin.ReloadResult{Applied: map[string]admin.Change{}}
+		if servers, ok := overrides["servers"]; ok {
+			old, err := d.SetServers(backend.SplitList(servers))
+			if err != nil {
+				return nil, err
+			}
+			result.Applied["servers"] = admin.Change{Old: strings.Join(old, ","), New: servers}
+		}
+		return result, nil
+	}
+	go server.ListenAndServe(path)
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	return d, fake
+}
+
+func TestRunCommand_Unknown(t *testing.T) {
+	err := runCommand("frobnicate", nil, &bytes.Buffer{})
+	assert.ErrorContains(t, err, `unknown command "frobnicate"`)
+	assert.ErrorContains(t, err, "serve, config, doctor, explain, inspect, ls")
+}
+
+func TestRunList(t *testing.T) {
+	d, _ := serveAdmin(t)
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{}}))
+
+	var out bytes.Buffer
+	require.NoError(t, runCommand("ls", nil, &out))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Equal(t, []string{"NAME", "MOUNTPOINT", "DISCOVERED"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"vol1", "false"}, strings.Fields(lines[1]))
+}
+
+func TestRunInspect(t *testing.T) {
+	d, _ := serveAdmin(t)
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{}}))
+
+	var out bytes.Buffer
+	require.NoError(t, runCommand("inspect", []string{"vol1"}, &out))
+	assert.Contains(t, out.String(), `"Name": "vol1"`)
+	assert.Contains(t, out.String(), `"mounted": false`)
+
+	assert.ErrorContains(t, runCommand("inspect", []string{"missing"}, &bytes.Buffer{}), "NOT_FOUND")
+	assert.ErrorContains(t, runCommand("inspect", nil, &bytes.Buffer{}), "takes 1 argument(s)")
+}
+
+func TestRunMountsAndUnmount(t *testing.T) {
+	d, fake := serveAdmin(t)
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{}}))
+	mountpoint, err := d.Mount(context.Background(), &volume.MountRequest{Name: "vol1", ID: "a"})
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, runCommand("mounts", nil, &out))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Equal(t, []string{"vol1", mountpoint, "1", "glusterfs", "server1"}, strings.Fields(lines[1]))
+
+	assert.ErrorContains(t, runCommand("unmount", []string{"vol1"}, &bytes.Buffer{}), "requires --force")
+	assert.Equal(t, 1, fake.MountCount())
+
+	out.Reset()
+	require.NoError(t, runCommand("unmount", []string{"vol1", "--force"}, &out))
+	assert.Equal(t, "unmounted vol1\n", out.String())
+	assert.Equal(t, 0, fake.MountCount())
+}
+
+func TestRunRemount(t *testing.T) {
+	d, fake := serveAdmin(t)
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{}}))
+	_, err := d.Mount(context.Background(), &volume.MountRequest{Name: "vol1", ID: "a"})
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, runCommand("remount", []string{"vol1"}, &out))
+	assert.Equal(t, "remounted vol1\n", out.String())
+	assert.Equal(t, 1, fake.MountCount())
+	assert.Len(t, fake.Calls(), 3)
+}
+
+func TestRunStale(t *testing.T) {
+	d, _ := serveAdmin(t)
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{}}))
+	_, err := d.Mount(context.Background(), &volume.MountRequest{Name: "vol1", ID: "a"})
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, runCommand("stale", nil, &out))
+	assert.Equal(t, "no mounts use stale servers\n", out.String())
+
+	_, err = d.SetServers([]string{"store2"})
+	require.NoError(t, err)
+	out.Reset()
+	require.NoError(t, runCommand("mounts", nil, &out))
+	assert.Contains(t, out.String(), "server1 (stale)\n")
+
+	out.Reset()
+	require.NoError(t, runCommand("stale", nil, &out))
+	assert.Equal(t, "NAME  REFS  SERVERS\nvol1  1     server1\n"+
+		"restart the containers using these volumes to mount them from the current servers\n", out.String())
+}
+
+func TestRunConfig(t *testing.T) {
+	serveAdmin(t)
+
+	var out bytes.Buffer
+	require.NoError(t, runCommand("config", nil, &out))
+	assert.Equal(t, "api-token  <redacted>\nservers    server1\n", out.String())
+}
+
+func TestRunValidate(t *testing.T) {
+	serveAdmin(t)
+
+	var out bytes.Buffer
+	require.NoError(t, runCommand("validate", []string{"vol1", "-o", "ro=true"}, &out))
+	assert.Equal(t, "valid\nvol1 ro=true servers=server1\n", out.String())
+
+	err := runCommand("validate", []string{"-o", "servers=a", "vol1"}, &bytes.Buffer{})
+	assert.ErrorContains(t, err, "invalid: VALIDATION")
+	assert.ErrorContains(t, runCommand("validate", []string{"vol1", "-o", "ro"}, &bytes.Buffer{}), "must be key=value")
+}
+
+func TestRunVersion(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, runCommand("version", nil, &out))
+	assert.Equal(t, version+"\n", out.String())
+}
+
+func TestDoctor(t *testing.T) {
+	serveAdmin(t)
+	dir := t.TempDir()
+	fuse := filepath.Join(dir, "fuse")
+	require.NoError(t, os.WriteFile(fuse, nil, 0600))
+
+	d := &doctor{
+		glusterfsBinary: "sh",
+		glusterBinary:   filepath.Join(dir, "gluster"),
+		fuseDevice:      fuse,
+		pluginSocket:    filepath.Join(dir, "gfs.sock"),
+		client:          admin.NewClient(os.Getenv("ADMIN_SOCKET")),
+	}
+
+	var out bytes.Buffer
+	assert.Equal(t, 1, d.run(context.Background(), &out))
+	assert.Contains(t, out.String(), "ok    glusterfs client")
+	assert.Contains(t, out.String(), "warn  gluster CLI")
+	assert.Contains(t, out.String(), "ok    FUSE device")
+	assert.Contains(t, out.String(), "FAIL  plugin socket")
+	assert.Contains(t, out.String(), "ok    admin API")
+	assert.Contains(t, out.String(), "warn  glusterd: SERVERS is not set")
+}
+
+func TestRunExplain(t *testing.T) {
+	serveAdmin(t)
+
+	var out bytes.Buffer
+	require.NoError(t, runCommand("explain", []string{"vol1/sub", "-o", "ro=true"}, &out))
+	assert.Contains(t, out.String(), "result:   valid\n")
+	assert.Contains(t, out.String(), "servers:  server1 (from SERVERS)\n")
+	assert.Contains(t, out.String(), "argv:\n  vol1/sub")
+
+	out.Reset()
+	err := runCommand("explain", []string{"vol1", "-o", "servers=a"}, &out)
+	assert.ErrorContains(t, err, "invalid: VALIDATION")
+	assert.Contains(t, out.String(), "rule:     servers-exclusive: if SERVERS is set")
+	assert.NotContains(t, out.String(), "argv:")
+
+	out.Reset()
+	require.NoError(t, runCommand("explain", []string{"-json", "vol1"}, &out))
+	assert.Contains(t, out.String(), `"serversSource": "SERVERS"`)
+}
@@ -2,32 +2,192 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
+	"os"
+	"strconv"
 	"strings"
+	"time"
 
+	"glusterfs-plugin/internal/admin"
+	"glusterfs-plugin/internal/backend"
 	"glusterfs-plugin/internal/driver"
 	"glusterfs-plugin/internal/utils"
 )
 
+// version is set at build time with -ldflags "-X main.version=...".
+var version = "dev"
+
 var (
-	servers = flag.String("servers", "", "Comma separated list of GlusterFS servers")
-	root    = flag.String("root", "/mnt/glusterfs", "Mount root of volume plugin")
+	servers        = flag.String("servers", envString("SERVERS", ""), "Comma separated list of GlusterFS servers, reloadable (env SERVERS)")
+	root           = flag.String("root", driver.DefaultRoot, "Mount root of volume plugin, the propagatedMount of config.json")
+	mountTimeout   = flag.Duration("mount-timeout", envDuration("MOUNT_TIMEOUT", driver.DefaultMountTimeout), "Maximum duration of a single mount (env MOUNT_TIMEOUT)")
+	mountMethod    = flag.String("mount-method", envString("MOUNT_METHOD", "fuse"), "How volumes are mounted: fuse (glusterfs client) or native (mount(2)) (env MOUNT_METHOD)")
+	unmountTimeout = flag.Duration("unmount-timeout", envDuration("UNMOUNT_TIMEOUT", driver.DefaultUnmountTimeout), "Maximum duration of a single unmount (env UNMOUNT_TIMEOUT)")
+
+	configFile  = flag.String("config", envString("CONFIG_FILE", ""), "File of KEY=VALUE settings re-read on SIGHUP and POST /reload of the admin API (env CONFIG_FILE)")
+	adminSocket = flag.String("admin-socket", envString("ADMIN_SOCKET", admin.DefaultSocketPath), "Socket of the admin API, disabled if empty (env ADMIN_SOCKET)")
+
+	discover = flag.Bool("discover", envBool("DISCOVER", false), "List the volumes of the cluster next to the created volumes (env DISCOVER)")
+
+	provisionBricks    = flag.String("provision-bricks", envString("PROVISION_BRICKS", ""), "Comma separated host:/path brick roots of provisioned volumes, provisioning is disabled if empty (env PROVISION_BRICKS)")
+	provisionReplica   = flag.Int("provision-replica", envInt("PROVISION_REPLICA", 0), "Replica count of provisioned volumes (env PROVISION_REPLICA)")
+	provisionTransport = flag.String("provision-transport", envString("PROVISION_TRANSPORT", "tcp"), "Transport of provisioned volumes: tcp, rdma or tcp,rdma (env PROVISION_TRANSPORT)")
+	provisionReclaim   = flag.String("provision-reclaim", envString("PROVISION_RECLAIM", string(driver.ReclaimRetain)), "What happens to provisioned volumes on remove: retain or delete (env PROVISION_RECLAIM)")
 )
 
+// envString reads a string from the environment, returning def if it is unset.
+func envString(name, def string) string {
+	if value := strings.TrimSpace(os.Getenv(name)); value != "" {
+		return value
+	}
+	return def
+}
+
+// envDuration reads a duration from the environment. Plain numbers are
+// taken as seconds. The default is returned if the variable is unset or invalid.
+func envDuration(name string, def time.Duration) time.Duration {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return def
+	}
+	d, err := parseDuration(value)
+	if err != nil {
+		log.Printf("warning: invalid %s %q, using %s", name, value, def)
+		return def
+	}
+	return d
+}
+
+// parseDuration parses a positive duration; plain numbers are taken as
+// seconds.
+func parseDuration(value string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		value = strconv.Itoa(seconds) + "s"
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("duration %s is not positive", value)
+	}
+	return d, nil
+}
+
+// envInt reads an integer from the environment. The default is returned
+// if the variable is unset or invalid.
+func envInt(name string, def int) int {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("warning: invalid %s %q, using %d", name, value, def)
+		return def
+	}
+	return n
+}
+
+// envBool reads a boolean from the environment. The default is returned
+// if the variable is unset or invalid.
+func envBool(name string, def bool) bool {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("warning: invalid %s %q, using %t", name, value, def)
+		return def
+	}
+	return b
+}
+
 func main() {
-	flag.Parse()
+	// Without a command, or with flags only, the plugin serves volumes
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") && os.Args[1] != "serve" {
+		if err := runCommand(os.Args[1], os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	if *servers == "" {
-		log.Fatal("servers parameter is required")
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "serve" {
+		args = args[1:]
 	}
+	flag.CommandLine.Parse(args)
 
-	serversList := strings.Split(*servers, ",")
-	for i, server := range serversList {
-		serversList[i] = strings.TrimSpace(server)
+	// SIGHUP reloads the configuration, even if it arrives during startup
+	hangup := notifyHangup()
+	reload := newReloader(flag.CommandLine, *configFile)
+	if err := reload.load(); err != nil {
+		log.Fatal(err)
 	}
 
-	d := driver.NewDriver(serversList)
-	if err := utils.StartUnixSocket(d, *root); err != nil {
+	d := driver.NewDriver(backend.SplitList(*servers))
+	d.Root = *root
+	d.MountTimeout = *mountTimeout
+	d.UnmountTimeout = *unmountTimeout
+	d.Discover = *discover
+
+	// New mounts use reloaded servers, existing mounts keep theirs
+	reload.handle("servers", func(value string) error {
+		_, err := d.SetServers(backend.SplitList(value))
+		return err
+	})
+	go reload.reloadOnSignal(hangup)
+
+	switch *mountMethod {
+	case "fuse":
+		g := backend.NewGlusterfs()
+		g.UnmountTimeout = *unmountTimeout
+		d.RegisterBackend(g)
+	case "native":
+		native := backend.NewGlusterfsNative()
+		if err := native.CheckSupport(); err != nil {
+			log.Fatalf("invalid mount method native: %v", err)
+		}
+		d.RegisterBackend(native)
+	default:
+		log.Fatalf("invalid mount method %q, must be fuse or native", *mountMethod)
+	}
+
+	if *provisionBricks != "" {
+		d.Provision = &driver.ProvisionProfile{
+			Bricks:    backend.SplitList(*provisionBricks),
+			Replica:   *provisionReplica,
+			Transport: *provisionTransport,
+			Reclaim:   driver.ReclaimPolicy(*provisionReclaim),
+		}
+		if err := d.Provision.Validate(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Receive glusterfs client logs in-process instead of running rsyslog
+	syslog := utils.NewSyslogServer(utils.SyslogSocketPath, d.Mounts, d.Logs)
+	if err := syslog.Start(); err != nil {
 		log.Fatal(err)
 	}
-} 
\ No newline at end of file
+
+	if *adminSocket != "" {
+		server := admin.NewServer(d)
+		server.Settings = reload.settings
+		server.Reload = reload.reload
+		go func() {
+			if err := server.ListenAndServe(*adminSocket); err != nil {
+				log.Printf("warning: admin API stopped: %v", err)
+			}
+		}()
+	}
+
+	// Serving only returns on failure; log.Fatal skips deferred calls, so
+	// the syslog socket is closed here
+	err := utils.StartUnixSocket(d)
+	syslog.Close()
+	log.Fatal(err)
+}
@@ -2,6 +2,9 @@ package main
 
 import (
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestMain(t *testing.T) {
@@ -10,4 +13,65 @@ func TestMain(t *testing.T) {
 	t.Run("package compiles", func(t *testing.T) {
 		// Si llegamos aquí, el paquete se compiló correctamente
 	})
-} 
\ No newline at end of file
+}
+
+func TestEnvDuration(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{name: "unset", value: "", want: time.Minute},
+		{name: "duration", value: "90s", want: 90 * time.Second},
+		{name: "plain seconds", value: "45", want: 45 * time.Second},
+		{name: "zero", value: "0", want: time.Minute},
+		{name: "negative", value: "-5s", want: time.Minute},
+		{name: "invalid", value: "soon", want: time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TEST_TIMEOUT", tt.value)
+			assert.Equal(t, tt.want, envDuration("TEST_TIMEOUT", time.Minute))
+		})
+	}
+}
+
+func TestEnvInt(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  int
+	}{
+		{name: "unset", value: "", want: 2},
+		{name: "number", value: "3", want: 3},
+		{name: "invalid", value: "three", want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TEST_REPLICA", tt.value)
+			assert.Equal(t, tt.want, envInt("TEST_REPLICA", 2))
+		})
+	}
+}
+
+func TestEnvBool(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  bool
+	}{
+		{name: "unset", value: "", want: false},
+		{name: "true", value: "true", want: true},
+		{name: "one", value: "1", want: true},
+		{name: "invalid", value: "sometimes", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TEST_DISCOVER", tt.value)
+			assert.Equal(t, tt.want, envBool("TEST_DISCOVER", false))
+		})
+	}
+}
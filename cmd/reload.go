@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"glusterfs-plugin/internal/admin"
+	"glusterfs-plugin/internal/backend"
+	"glusterfs-plugin/internal/errors"
+)
+
+// envUsage finds the environment variable named in a flag usage.
+var envUsage = regexp.MustCompile(`\(env ([A-Z_]+)\)`)
+
+// reloader applies configuration changes to a running driver. Settings
+// are the command line flags; the reloadable ones are applied at once,
+// the others are reported as pending until the plugin restarts.
+type reloader struct {
+	// mu serializes reloads and guards the flag values
+	mu sync.Mutex
+
+	// flags are the settings of the plugin
+	flags *flag.FlagSet
+
+	// path is the config file, empty if there is none
+	path string
+
+	// apply applies reloadable settings by flag name
+	apply map[string]func(value string) error
+}
+
+// newReloader creates a reloader of the settings in flags, re-reading
+// the config file at path.
+func newReloader(flags *flag.FlagSet, path string) *reloader {
+	return &reloader{
+		flags: flags,
+		path:  path,
+		apply: map[string]func(value string) error{},
+	}
+}
+
+// handle makes a setting reloadable; apply is called with its new
+// value whenever a reload changes it, and keeps the old value by
+// returning an error.
+func (r *reloader) handle(name string, apply func(value string) error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.apply[name] = apply
+}
+
+// load applies the settings of the config file to the flags when the
+// plugin starts, taking precedence over the environment.
+//
+// Returns:
+// - ValidationError if the file or a setting is invalid
+func (r *reloader) load() error {
+	if r.path == "" {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	values, err := r.readConfigFile()
+	if err != nil {
+		return err
+	}
+	for name, value := range values {
+		normalized, err := normalizeSetting(r.flags.Lookup(name), value)
+		if err != nil {
+			return errors.NewValidationError(fmt.Sprintf("invalid %s %q: %v", name, value, err))
+		}
+		if err := r.flags.Set(name, normalized); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// settings returns the current value of every setting by flag name,
+// and the version.
+func (r *reloader) settings() map[string]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	values := map[string]string{"version": version}
+	r.flags.VisitAll(func(f *flag.Flag) {
+		values[f.Name] = f.Value.String()
+	})
+	return values
+}
+
+// reload re-reads the config file, puts overrides on top and applies
+// the settings that changed. The changes are logged.
+//
+// Parameters:
+// - overrides: Settings by flag name, taking precedence over the file
+//
+// Returns:
+// - The applied and pending changes
+// - ValidationError if the file or a setting is invalid, nothing is applied
+// - The error of a setting rejected when applied; it keeps its value
+func (r *reloader) reload(overrides map[string]string) (*admin.ReloadResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	values := map[string]string{}
+	if r.path != "" {
+		fileValues, err := r.readConfigFile()
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range fileValues {
+			values[name] = value
+		}
+	}
+	for name, value := range overrides {
+		values[name] = value
+	}
+
+	// Check every setting before applying any of them
+	result := &admin.ReloadResult{Applied: map[string]admin.Change{}}
+	updates := map[string]string{}
+	for name, value := range values {
+		f := r.flags.Lookup(name)
+		if f == nil {
+			return nil, errors.NewValidationError(fmt.Sprintf("unknown setting %q", name))
+		}
+		normalized, err := normalizeSetting(f, value)
+		if err != nil {
+			return nil, errors.NewValidationError(fmt.Sprintf("invalid %s %q: %v", name, value, err))
+		}
+		current, _ := normalizeSetting(f, f.Value.String())
+		if normalized == current {
+			continue
+		}
+
+		change := admin.Change{Old: f.Value.String(), New: normalized}
+		if _, ok := r.apply[name]; ok {
+			result.Applied[name] = change
+			updates[name] = normalized
+			continue
+		}
+		if result.Pending == nil {
+			result.Pending = map[string]admin.Change{}
+		}
+		result.Pending[name] = change
+	}
+
+	for name, value := range updates {
+		if err := r.apply[name](value); err != nil {
+			return nil, err
+		}
+		if err := r.flags.Set(name, value); err != nil {
+			return nil, err
+		}
+	}
+	logReload(result)
+	return result, nil
+}
+
+// readConfigFile reads the config file: KEY=VALUE lines naming settings
+// by their environment variable, with # comments.
+//
+// Returns:
+// - The settings by flag name
+// - ValidationError if the file cannot be read or is malformed
+func (r *reloader) readConfigFile() (map[string]string, error) {
+	f, err := os.Open(r.path)
+	if err != nil {
+		return nil, errors.NewValidationError(fmt.Sprintf("cannot read config file: %v", err))
+	}
+	defer f.Close()
+
+	byEnv := map[string]string{}
+	r.flags.VisitAll(func(f *flag.Flag) {
+		if m := envUsage.FindStringSubmatch(f.Usage); m != nil {
+			byEnv[m[1]] = f.Name
+		}
+	})
+
+	values := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(text, "=")
+		if !ok {
+			return nil, errors.NewValidationError(fmt.Sprintf("%s:%d: expected KEY=VALUE", r.path, line))
+		}
+		key = strings.TrimSpace(key)
+		name, ok := byEnv[key]
+		if !ok {
+			return nil, errors.NewValidationError(fmt.Sprintf("%s:%d: unknown setting %s", r.path, line, key))
+		}
+		values[name] = strings.Trim(strings.TrimSpace(value), `"'`)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.NewValidationError(fmt.Sprintf("cannot read config file: %v", err))
+	}
+	return values, nil
+}
+
+// normalizeSetting returns a setting value in the form its flag prints
+// it, so that "60" and "1m0s" or "a, b" and "a,b" compare equal.
+func normalizeSetting(f *flag.Flag, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	getter, ok := f.Value.(flag.Getter)
+	if !ok {
+		return value, nil
+	}
+	switch getter.Get().(type) {
+	case time.Duration:
+		d, err := parseDuration(value)
+		if err != nil {
+			return "", err
+		}
+		return d.String(), nil
+	case bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatBool(b), nil
+	case int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return "", err
+		}
+		return strconv.Itoa(n), nil
+	}
+	if f.Name == "servers" || strings.HasSuffix(f.Name, "-bricks") {
+		return strings.Join(backend.SplitList(value), ","), nil
+	}
+	return value, nil
+}
+
+// notifyHangup routes SIGHUP to a channel for reloadOnSignal. It is
+// called before the plugin starts serving, since an unhandled SIGHUP
+// terminates the process.
+func notifyHangup() <-chan os.Signal {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	return signals
+}
+
+// reloadOnSignal reloads the configuration whenever a signal arrives on
+// signals, see notifyHangup.
+func (r *reloader) reloadOnSignal(signals <-chan os.Signal) {
+	for range signals {
+		if _, err := r.reload(nil); err != nil {
+			log.Printf("warning: failed to reload configuration: %v", err)
+		}
+	}
+}
+
+// logReload logs the changes of a reload.
+func logReload(result *admin.ReloadResult) {
+	if len(result.Applied) == 0 && len(result.Pending) == 0 {
+		log.Printf("reloaded configuration, nothing changed")
+		return
+	}
+	for _, name := range sortedNames(result.Applied) {
+		change := result.Applied[name]
+		log.Printf("reloaded configuration: %s changed from %q to %q", name, change.Old, change.New)
+	}
+	for _, name := range sortedNames(result.Pending) {
+		change := result.Pending[name]
+		log.Printf("warning: %s changed from %q to %q, restart the plugin to apply it", name, change.Old, change.New)
+	}
+}
+
+// sortedNames returns the setting names of changes, sorted.
+func sortedNames(changes map[string]admin.Change) []string {
+	names := make([]string, 0, len(changes))
+	for name := range changes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
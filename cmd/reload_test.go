@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"glusterfs-plugin/internal/admin"
+	"glusterfs-plugin/internal/backend"
+	"glusterfs-plugin/internal/errors"
+)
+
+// newTestReloader returns a reloader of a few settings read from a
+// config file in a temporary directory, recording the reloaded servers.
+func newTestReloader(t *testing.T, config string) (*reloader, *[]string) {
+	t.Helper()
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.String("servers", "store1,store2", "Servers, reloadable (env SERVERS)")
+	fs.Duration("mount-timeout", time.Minute, "Mount timeout (env MOUNT_TIMEOUT)")
+	fs.Bool("discover", false, "Discover volumes (env DISCOVER)")
+
+	path := filepath.Join(t.TempDir(), "plugin.env")
+	require.NoError(t, os.WriteFile(path, []byte(config), 0600))
+
+	var servers []string
+	r := newReloader(fs, path)
+	r.handle("servers", func(value string) error {
+		servers = backend.SplitList(value)
+		return nil
+	})
+	return r, &servers
+}
+
+func TestReloader_Reload(t *testing.T) {
+	r, servers := newTestReloader(t, "# plugin settings\nSERVERS=store1, store3\nMOUNT_TIMEOUT=60\nDISCOVER=\"true\"\n")
+
+	result, err := r.reload(nil)
+	require.NoError(t, err)
+	assert.Equal(t, &admin.ReloadResult{
+		Applied: map[string]admin.Change{"servers": {Old: "store1,store2", New: "store1,store3"}},
+		Pending: map[string]admin.Change{"discover": {Old: "false", New: "true"}},
+	}, result)
+	assert.Equal(t, []string{"store1", "store3"}, *servers)
+	assert.Equal(t, "store1,store3", r.settings()["servers"])
+	assert.Equal(t, "false", r.settings()["discover"])
+
+	// Reloading the same file changes nothing more
+	*servers = nil
+	result, err = r.reload(nil)
+	require.NoError(t, err)
+	assert.Empty(t, result.Applied)
+	assert.Nil(t, *servers)
+
+	// Overrides take precedence over the file
+	result, err = r.reload(map[string]string{"servers": "store4"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]admin.Change{"servers": {Old: "store1,store3", New: "store4"}}, result.Applied)
+	assert.Equal(t, []string{"store4"}, *servers)
+}
+
+func TestReloader_InvalidSettingsApplyNothing(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    string
+		overrides map[string]string
+		wantErr   string
+	}{
+		{name: "unknown variable", config: "SERVERS=store3\nPASSWORD=x\n", wantErr: "unknown setting PASSWORD"},
+		{name: "malformed line", config: "SERVERS store3\n", wantErr: "expected KEY=VALUE"},
+		{name: "invalid duration", config: "SERVERS=store3\nMOUNT_TIMEOUT=soon\n", wantErr: `invalid mount-timeout "soon"`},
+		{name: "unknown override", config: "SERVERS=store3\n", overrides: map[string]string{"root": "/mnt"}, wantErr: `unknown setting "root"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, servers := newTestReloader(t, tt.config)
+			_, err := r.reload(tt.overrides)
+			assert.ErrorIs(t, err, errors.ErrValidation)
+			assert.ErrorContains(t, err, tt.wantErr)
+			assert.Nil(t, *servers)
+			assert.Equal(t, "store1,store2", r.settings()["servers"])
+		})
+	}
+}
+
+func TestReloader_Load(t *testing.T) {
+	r, servers := newTestReloader(t, "SERVERS=store3\nDISCOVER=1\n")
+
+	// At startup every setting of the file is taken, none is pending
+	require.NoError(t, r.load())
+	assert.Equal(t, "store3", r.settings()["servers"])
+	assert.Equal(t, "true", r.settings()["discover"])
+	assert.Nil(t, *servers)
+}
+
+func TestReloader_ReloadRejectedValue(t *testing.T) {
+	r, servers := newTestReloader(t, "SERVERS=\nMOUNT_TIMEOUT=2m\n")
+	r.handle("servers", func(value string) error {
+		if value == "" {
+			return errors.NewValidationError("SERVERS cannot be emptied")
+		}
+		*servers = backend.SplitList(value)
+		return nil
+	})
+
+	// A rejected value applies nothing
+	_, err := r.reload(nil)
+	assert.ErrorIs(t, err, errors.ErrValidation)
+	assert.Nil(t, *servers)
+	assert.Equal(t, "store1,store2", r.settings()["servers"])
+	assert.Equal(t, "1m0s", r.settings()["mount-timeout"])
+
+	_, err = r.reload(map[string]string{"servers": "store3"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"store3"}, *servers)
+}
+
+func TestReloader_ReloadOnSignal(t *testing.T) {
+	r, _ := newTestReloader(t, "SERVERS=store3\n")
+
+	// A SIGHUP arriving before the reloader runs is kept for it
+	hangup := notifyHangup()
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+	go r.reloadOnSignal(hangup)
+
+	require.Eventually(t, func() bool {
+		return r.settings()["servers"] == "store3"
+	}, 5*time.Second, 20*time.Millisecond)
+}
+
+func TestRunReload(t *testing.T) {
+	d, _ := serveAdmin(t)
+
+	var out bytes.Buffer
+	require.NoError(t, runCommand("reload", nil, &out))
+	assert.Equal(t, "nothing changed\n", out.String())
+
+	out.Reset()
+	require.NoError(t, runCommand("reload", []string{"-set", "servers=store9"}, &out))
+	assert.Equal(t, "SETTING  OLD      NEW     STATUS\nservers  server1  store9  applied\n", out.String())
+	previous, err := d.SetServers([]string{"store9"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"store9"}, previous)
+
+	assert.ErrorContains(t, runCommand("reload", []string{"-set", "servers"}, &bytes.Buffer{}), "must be key=value")
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"glusterfs-plugin/internal/backend"
+	"glusterfs-plugin/internal/driver"
+	"glusterfs-plugin/internal/management"
+)
+
+// newManagementClient creates the management clients of the helper commands.
+var newManagementClient management.Factory = func(servers []string) management.Client {
+	return management.NewCLI(servers)
+}
+
+// runSnapshot takes a snapshot of the GlusterFS volume of a plugin volume
+// and prints its name, for use with driver_opts.from-snapshot.
+//
+// Usage: snapshot [-servers a,b] [-timeout 30s] <volume> [<snapshot>]
+//
+// Parameters:
+// - args: The command line arguments after "snapshot"
+// - stdout: Receives the snapshot name
+//
+// Returns:
+// - error if the arguments are invalid or the snapshot fails
+func runSnapshot(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("snapshot", flag.ContinueOnError)
+	servers := fs.String("servers", envString("SERVERS", ""), "Comma separated list of GlusterFS servers (env SERVERS)")
+	timeout := fs.Duration("timeout", driver.DefaultManagementTimeout, "Maximum duration of the snapshot")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: snapshot [-servers a,b] [-timeout 30s] <volume> [<snapshot>]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		return fmt.Errorf("snapshot takes a volume and an optional snapshot name")
+	}
+
+	// Snapshots are taken of whole volumes, also for subdirectory volumes
+	volume := strings.SplitN(fs.Arg(0), "/", 2)[0]
+	name := fs.Arg(1)
+	if name == "" {
+		name = management.SnapshotName(volume, time.Now())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	if err := newManagementClient(backend.SplitList(*servers)).CreateSnapshot(ctx, volume, name); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(stdout, name)
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"glusterfs-plugin/internal/management"
+	"glusterfs-plugin/internal/management/managementtest"
+)
+
+// useFakeManagement makes the helper commands use a fake management client.
+func useFakeManagement(t *testing.T) *managementtest.Fake {
+	t.Helper()
+	fake := managementtest.New()
+	fake.AddVolume(&management.Volume{Name: "shared", Status: "Started"})
+
+	prev := newManagementClient
+	newManagementClient = fake.Factory()
+	t.Cleanup(func() { newManagementClient = prev })
+	return fake
+}
+
+func TestRunSnapshot(t *testing.T) {
+	fake := useFakeManagement(t)
+
+	var out bytes.Buffer
+	require.NoError(t, runSnapshot([]string{"-servers", "store1", "shared/team-a", "before-upgrade"}, &out))
+	assert.Equal(t, "before-upgrade\n", out.String())
+
+	snap, err := fake.Snapshot(context.Background(), "before-upgrade")
+	require.NoError(t, err)
+	assert.Equal(t, "shared", snap.Volume)
+}
+
+func TestRunSnapshot_GeneratedName(t *testing.T) {
+	useFakeManagement(t)
+
+	var out bytes.Buffer
+	require.NoError(t, runSnapshot([]string{"shared"}, &out))
+	assert.Regexp(t, `^shared-\d{8}-\d{6}-[0-9a-f]{6}\n$`, out.String())
+}
+
+func TestRunSnapshot_Errors(t *testing.T) {
+	useFakeManagement(t)
+
+	assert.Error(t, runSnapshot([]string{}, &bytes.Buffer{}))
+	assert.Error(t, runSnapshot([]string{"a", "b", "c"}, &bytes.Buffer{}))
+	assert.ErrorContains(t, runSnapshot([]string{"media"}, &bytes.Buffer{}), "volume media does not exist")
+}
@@ -2,7 +2,10 @@ module glusterfs-plugin
 
 go 1.21
 
-require github.com/stretchr/testify v1.8.4
+require (
+	github.com/stretchr/testify v1.8.4
+	golang.org/x/sys v0.20.0
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
@@ -0,0 +1,184 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"glusterfs-plugin/internal/driver"
+	"glusterfs-plugin/pkg/volume"
+)
+
+// Client calls the admin API of a running plugin.
+type Client struct {
+	// SocketPath is the admin socket of the plugin.
+	SocketPath string
+
+	http *http.Client
+}
+
+// Error is an error returned by the admin API.
+type Error struct {
+	// Status is the HTTP status of the response
+	Status int
+
+	// Message is the error, in the "CODE: message" form for driver errors
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// NewClient creates a client for the admin socket at path.
+//
+// Parameters:
+// - path: The admin socket path
+//
+// Returns:
+// - A new Client
+func NewClient(path string) *Client {
+	return &Client{
+		SocketPath: path,
+		http: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", path)
+				},
+			},
+		},
+	}
+}
+
+// Volumes returns the registered volumes.
+func (c *Client) Volumes(ctx context.Context) ([]*volume.Volume, error) {
+	var volumes []*volume.Volume
+	return volumes, c.do(ctx, http.MethodGet, "/volumes", nil, &volumes)
+}
+
+// Volume returns a volume with its status.
+func (c *Client) Volume(ctx context.Context, name string) (*volume.Volume, error) {
+	var v volume.Volume
+	if err := c.do(ctx, http.MethodGet, volumePath(name), nil, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// Mounts returns the active mounts.
+func (c *Client) Mounts(ctx context.Context) ([]driver.MountInfo, error) {
+	var mounts []driver.MountInfo
+	return mounts, c.do(ctx, http.MethodGet, "/mounts", nil, &mounts)
+}
+
+// StaleMounts returns the mounts built from previous servers.
+func (c *Client) StaleMounts(ctx context.Context) ([]driver.StaleMount, error) {
+	var stale []driver.StaleMount
+	return stale, c.do(ctx, http.MethodGet, "/mounts/stale", nil, &stale)
+}
+
+// ForceUnmount unmounts a volume regardless of its mounts.
+func (c *Client) ForceUnmount(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodPost, volumePath(name)+"/force-unmount", nil, nil)
+}
+
+// Remount unmounts a volume and mounts it again, keeping its mounts.
+func (c *Client) Remount(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodPost, volumePath(name)+"/remount", nil, nil)
+}
+
+// Config returns the plugin settings, with secrets redacted.
+func (c *Client) Config(ctx context.Context) (map[string]string, error) {
+	var config map[string]string
+	return config, c.do(ctx, http.MethodGet, "/config", nil, &config)
+}
+
+// Reload re-reads the configuration of the plugin, with overrides on top.
+func (c *Client) Reload(ctx context.Context, overrides map[string]string) (*ReloadResult, error) {
+	var result ReloadResult
+	if err := c.do(ctx, http.MethodPost, "/reload", overrides, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Health reports whether the plugin serves requests.
+func (c *Client) Health(ctx context.Context) (*Health, error) {
+	var health Health
+	if err := c.do(ctx, http.MethodGet, "/healthz", nil, &health); err != nil {
+		return nil, err
+	}
+	return &health, nil
+}
+
+// Validate runs Validate and MountOptions on a create request without
+// creating the volume.
+func (c *Client) Validate(ctx context.Context, req *ValidateRequest) (*ValidateResponse, error) {
+	var resp ValidateResponse
+	if err := c.do(ctx, http.MethodPost, "/validate", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Explain explains how a create request would be handled, without
+// creating the volume.
+func (c *Client) Explain(ctx context.Context, req *ValidateRequest) (*driver.Explanation, error) {
+	var e driver.Explanation
+	if err := c.do(ctx, http.MethodPost, "/explain", req, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// do sends a request with an optional JSON body and decodes the
+// response into out, if out is not nil.
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, "http://admin"+path, reader)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot reach the plugin at %s: %w", c.SocketPath, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var e errorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&e); err != nil || e.Error == "" {
+			e.Error = resp.Status
+		}
+		return &Error{Status: resp.StatusCode, Message: e.Error}
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// volumePath returns the API path of a volume, keeping the slashes of
+// subdirectory volumes.
+func volumePath(name string) string {
+	parts := strings.Split(name, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return "/volumes/" + strings.Join(parts, "/")
+}
@@ -0,0 +1,67 @@
+package admin
+
+import (
+	"net/url"
+	"strings"
+)
+
+// redacted replaces secret setting values.
+const redacted = "<redacted>"
+
+// secretNames are the parts of setting names holding secrets.
+var secretNames = []string{"password", "passwd", "secret", "token", "credential", "key"}
+
+// redact returns a copy of settings with secrets replaced: the values of
+// settings whose names look secret, and the passwords of URLs.
+//
+// Parameters:
+// - settings: The plugin settings by name
+//
+// Returns:
+// - The settings safe to report
+func redact(settings map[string]string) map[string]string {
+	safe := make(map[string]string, len(settings))
+	for name, value := range settings {
+		safe[name] = redactValue(name, value)
+	}
+	return safe
+}
+
+// redactChanges returns a copy of changes with secrets replaced.
+func redactChanges(changes map[string]Change) map[string]Change {
+	if changes == nil {
+		return nil
+	}
+	safe := make(map[string]Change, len(changes))
+	for name, change := range changes {
+		safe[name] = Change{Old: redactValue(name, change.Old), New: redactValue(name, change.New)}
+	}
+	return safe
+}
+
+// redactValue returns the value of a setting, or a placeholder if the
+// setting is a secret.
+func redactValue(name, value string) string {
+	if value == "" {
+		return value
+	}
+	lower := strings.ToLower(name)
+	for _, secret := range secretNames {
+		if strings.Contains(lower, secret) {
+			return redacted
+		}
+	}
+
+	// Lists of URLs, such as servers, may carry credentials
+	parts := strings.Split(value, ",")
+	for i, part := range parts {
+		u, err := url.Parse(strings.TrimSpace(part))
+		if err != nil || u.User == nil {
+			continue
+		}
+		if _, ok := u.User.Password(); ok {
+			parts[i] = u.Redacted()
+		}
+	}
+	return strings.Join(parts, ",")
+}
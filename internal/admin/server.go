@@ -0,0 +1,402 @@
+// Package admin serves the administration API of the plugin on a Unix
+// socket of its own and provides the client used by the admin commands
+// of the plugin binary. Unlike the Docker plugin socket, the admin API
+// is meant for operators debugging a running plugin.
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"glusterfs-plugin/internal/driver"
+	"glusterfs-plugin/internal/errors"
+	"glusterfs-plugin/pkg/volume"
+)
+
+// DefaultSocketPath is where the plugin serves the admin API.
+const DefaultSocketPath = "/run/glusterfs-plugin/admin.sock"
+
+// Driver is the part of the volume driver exposed through the admin API.
+type Driver interface {
+	volume.Driver
+
+	// ActiveMounts returns the active mounts.
+	ActiveMounts() []driver.MountInfo
+
+	// ForceUnmount unmounts a volume regardless of its mounts.
+	ForceUnmount(ctx context.Context, name string) error
+
+	// Remount unmounts a volume and mounts it again, keeping its mounts.
+	Remount(ctx context.Context, name string) error
+
+	// StaleMounts returns the mounts built from previous servers.
+	StaleMounts() []driver.StaleMount
+
+	// Explain explains how a create request would be handled.
+	Explain(req *volume.CreateRequest) (*driver.Explanation, error)
+}
+
+// ValidateRequest is the body of POST /validate and POST /explain.
+type ValidateRequest struct {
+	Name    string            `json:"name"`
+	Options map[string]string `json:"options"`
+}
+
+// ValidateResponse is the response to POST /validate.
+type ValidateResponse struct {
+	// Error is the validation error, empty if the request is valid
+	Error string `json:"error,omitempty"`
+
+	// Args are the mount arguments of a valid request
+	Args []string `json:"args,omitempty"`
+}
+
+// errorResponse is the body of failed requests.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Health is the response to GET /healthz.
+type Health struct {
+	// Status is "ok" while the plugin serves requests
+	Status string `json:"status"`
+
+	// Volumes counts the registered volumes
+	Volumes int `json:"volumes"`
+
+	// Mounts counts the active mounts
+	Mounts int `json:"mounts"`
+}
+
+// Change is the old and new value of a setting.
+type Change struct {
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// ReloadResult reports the outcome of a configuration reload.
+type ReloadResult struct {
+	// Applied are the settings changed by the reload, by name
+	Applied map[string]Change `json:"applied"`
+
+	// Pending are changed settings that only take effect after a
+	// restart of the plugin, by name
+	Pending map[string]Change `json:"pending,omitempty"`
+}
+
+// Server serves the admin API of a volume driver.
+type Server struct {
+	// Driver is the volume driver
+	Driver Driver
+
+	// Settings returns the plugin settings by name for GET /config, nil
+	// if there are none to report. Secrets are redacted by the server.
+	Settings func() map[string]string
+
+	// Reload re-reads the configuration of the plugin with the given
+	// settings on top, by name, for POST /reload; reloading is not
+	// supported if nil. Secrets in the result are redacted by the server.
+	Reload func(overrides map[string]string) (*ReloadResult, error)
+}
+
+// NewServer creates an admin server for a volume driver.
+//
+// Parameters:
+// - d: The volume driver
+//
+// Returns:
+// - A new Server
+func NewServer(d Driver) *Server {
+	return &Server{Driver: d}
+}
+
+// Handler returns an http.Handler serving the admin API:
+//
+//	GET  /volumes                      registered volumes
+//	GET  /volumes/{name}               a volume with its status
+//	POST /volumes/{name}/remount       unmount and mount again
+//	POST /volumes/{name}/force-unmount unmount regardless of mounts
+//	GET  /mounts                       active mounts
+//	GET  /mounts/stale                 mounts built from previous
+//	                                   servers
+//	POST /validate                     dry run of Validate and MountOptions
+//	POST /explain                      validation rule, servers and mount
+//	                                   arguments of a create request
+//	GET  /config                       plugin settings, secrets redacted
+//	POST /reload                       re-read the configuration, with
+//	                                   optional settings in the body
+//	GET  /healthz                      liveness of the plugin
+//
+// Volume names may contain slashes.
+//
+// Returns:
+// - The admin API handler
+func (s *Server) Handler() http.Handler {
+	d := s.Driver
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/volumes", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		volumes, err := d.List()
+		writeResult(w, volumes, err)
+	})
+
+	actions := map[string]func(ctx context.Context, name string) error{
+		"remount":       d.Remount,
+		"force-unmount": d.ForceUnmount,
+	}
+	mux.HandleFunc("/volumes/", func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/volumes/")
+		if r.Method == http.MethodPost {
+			if i := strings.LastIndex(name, "/"); i > 0 {
+				if action, ok := actions[name[i+1:]]; ok {
+					writeResult(w, nil, action(r.Context(), name[:i]))
+					return
+				}
+			}
+			writeError(w, http.StatusNotFound, fmt.Sprintf("unknown action %s", r.URL.Path))
+			return
+		}
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		v, err := d.Get(name)
+		writeResult(w, v, err)
+	})
+
+	mux.HandleFunc("/mounts", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		mounts := d.ActiveMounts()
+		if mounts == nil {
+			mounts = []driver.MountInfo{}
+		}
+		writeResult(w, mounts, nil)
+	})
+
+	mux.HandleFunc("/mounts/stale", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		stale := d.StaleMounts()
+		if stale == nil {
+			stale = []driver.StaleMount{}
+		}
+		writeResult(w, stale, nil)
+	})
+
+	mux.HandleFunc("/validate", func(w http.ResponseWriter, r *http.Request) {
+		create, ok := readCreateRequest(w, r)
+		if !ok {
+			return
+		}
+		if err := d.Validate(create); err != nil {
+			writeResult(w, ValidateResponse{Error: errors.Response(err)}, nil)
+			return
+		}
+		writeResult(w, ValidateResponse{Args: d.MountOptions(create)}, nil)
+	})
+
+	mux.HandleFunc("/explain", func(w http.ResponseWriter, r *http.Request) {
+		create, ok := readCreateRequest(w, r)
+		if !ok {
+			return
+		}
+		explanation, err := d.Explain(create)
+		writeResult(w, explanation, err)
+	})
+
+	mux.HandleFunc("/config", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		settings := map[string]string{}
+		if s.Settings != nil {
+			settings = redact(s.Settings())
+		}
+		writeResult(w, settings, nil)
+	})
+
+	mux.HandleFunc("/reload", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodPost) {
+			return
+		}
+		if s.Reload == nil {
+			writeError(w, http.StatusNotImplemented, "reloading the configuration is not supported")
+			return
+		}
+		var overrides map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&overrides); err != nil && err != io.EOF {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+			return
+		}
+		result, err := s.Reload(overrides)
+		if err != nil {
+			writeResult(w, nil, err)
+			return
+		}
+		writeResult(w, &ReloadResult{Applied: redactChanges(result.Applied), Pending: redactChanges(result.Pending)}, nil)
+	})
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		volumes, err := d.List()
+		if err != nil {
+			writeResult(w, nil, err)
+			return
+		}
+		writeResult(w, Health{Status: "ok", Volumes: len(volumes), Mounts: len(d.ActiveMounts())}, nil)
+	})
+
+	return mux
+}
+
+// ListenAndServe serves the admin API on a Unix socket only accessible
+// to the owner of the plugin process.
+//
+// Parameters:
+// - path: The socket path; its directory is created if needed
+//
+// Returns:
+// - error if the socket directory belongs to another user, the socket
+// cannot be created or serving fails
+func (s *Server) ListenAndServe(path string) error {
+	if err := privateDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(path); err != nil {
+		return fmt.Errorf("failed to remove existing admin socket: %v", err)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("failed to create admin socket: %v", err)
+	}
+	defer listener.Close()
+
+	// The directory is private, so the socket is not reachable by other
+	// users before its permissions are restricted
+	if err := os.Chmod(path, 0600); err != nil {
+		return fmt.Errorf("failed to set admin socket permissions: %v", err)
+	}
+
+	log.Printf("Starting admin API at %s", path)
+	return http.Serve(listener, s.Handler())
+}
+
+// privateDir makes dir a directory only accessible to the plugin user:
+// it is created if needed, and an existing directory is checked to be a
+// directory owned by the plugin user and restricted to 0700.
+//
+// Parameters:
+// - dir: The directory of the admin socket
+//
+// Returns:
+// - error if the directory cannot be created, is not a directory or
+// belongs to another user
+func privateDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create admin socket directory: %v", err)
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return fmt.Errorf("failed to check admin socket directory: %v", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("admin socket directory %s is not a directory", dir)
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && int(stat.Uid) != os.Geteuid() {
+		return fmt.Errorf("admin socket directory %s belongs to uid %d, not to the plugin user %d", dir, stat.Uid, os.Geteuid())
+	}
+	if err := os.Chmod(dir, 0700); err != nil {
+		return fmt.Errorf("failed to set admin socket directory permissions: %v", err)
+	}
+	return nil
+}
+
+// readCreateRequest reads the create request posted to /validate and
+// /explain, writing an error response if it cannot.
+func readCreateRequest(w http.ResponseWriter, r *http.Request) (*volume.CreateRequest, bool) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return nil, false
+	}
+	var req ValidateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return nil, false
+	}
+	if req.Options == nil {
+		req.Options = map[string]string{}
+	}
+	return &volume.CreateRequest{Name: req.Name, Options: req.Options}, true
+}
+
+// allowMethod rejects requests with another method than method.
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeError(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed", r.Method))
+	return false
+}
+
+// writeResult writes value as JSON, or err with the status of its code.
+func writeResult(w http.ResponseWriter, value interface{}, err error) {
+	if err != nil {
+		writeError(w, statusOf(err), errors.Response(err))
+		return
+	}
+	if value == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON(w, http.StatusOK, value)
+}
+
+// writeError writes an error response.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Printf("failed to write admin response: %v", err)
+	}
+}
+
+// statusOf maps the code of an error to an HTTP status.
+func statusOf(err error) int {
+	switch errors.CodeOf(err) {
+	case errors.CodeValidation:
+		return http.StatusBadRequest
+	case errors.CodeNotFound:
+		return http.StatusNotFound
+	case errors.CodeAlreadyExists, errors.CodeInUse:
+		return http.StatusConflict
+	case errors.CodePermissionDenied:
+		return http.StatusForbidden
+	case errors.CodeTimeout:
+		return http.StatusGatewayTimeout
+	case errors.CodeServerUnreachable:
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,78 @@
+// Package backend defines the filesystem backends the volume driver can
+// mount. Each backend knows how to build the mount arguments for a volume,
+// mount and unmount it, and check that its servers are reachable.
+// GlusterFS is the default backend; volumes select another one with
+// driver_opts.type.
+package backend
+
+import (
+	"context"
+	"sort"
+)
+
+// TypeOption is the driver option selecting the backend of a volume.
+const TypeOption = "type"
+
+// Request describes the volume a backend builds mount arguments for.
+type Request struct {
+	// Name is the Docker volume name
+	Name string
+
+	// Options are the driver_opts the volume was created with
+	Options map[string]string
+
+	// Servers are the servers configured for the plugin (SERVERS),
+	// empty if volumes must name their own
+	Servers []string
+}
+
+// Observer receives information about the client process of a mount.
+// Both callbacks are optional.
+type Observer struct {
+	// Started is called with the PID of a started client process
+	Started func(pid int)
+
+	// Output is called for each line printed by the client
+	Output func(line string)
+}
+
+// Backend mounts volumes of one filesystem type.
+type Backend interface {
+	// Type returns the driver_opts.type value selecting this backend.
+	Type() string
+
+	// Validate checks that the backend can mount the volume.
+	Validate(req *Request) error
+
+	// MountArgs builds the arguments used to mount the volume.
+	MountArgs(req *Request) []string
+
+	// Mount mounts a volume with the given arguments.
+	// If the mount fails or the context is cancelled, nothing is left
+	// mounted at the mount point.
+	Mount(ctx context.Context, args []string, mountpoint string, obs Observer) error
+
+	// Unmount unmounts the given mount point.
+	Unmount(ctx context.Context, mountpoint string) error
+
+	// HealthCheck verifies that at least one of the servers is reachable.
+	HealthCheck(ctx context.Context, servers []string) error
+}
+
+// Registry holds the available backends by type.
+type Registry map[string]Backend
+
+// Register adds a backend to the registry, replacing any backend of the same type.
+func (r Registry) Register(b Backend) {
+	r[b.Type()] = b
+}
+
+// Types returns the registered backend types, sorted.
+func (r Registry) Types() []string {
+	types := make([]string, 0, len(r))
+	for t := range r {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
@@ -0,0 +1,14 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	r := Registry{}
+	r.Register(NewGlusterfs())
+	assert.Equal(t, []string{GlusterfsType}, r.Types())
+	assert.Equal(t, GlusterfsType, r[GlusterfsType].Type())
+}
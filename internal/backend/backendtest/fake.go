@@ -0,0 +1,204 @@
+// Package backendtest provides a fake backend for tests of code that
+// mounts volumes through the backend interface.
+package backendtest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"glusterfs-plugin/internal/backend"
+)
+
+// Fake is an in-memory backend.Backend.
+// It records every call, keeps track of mounted mount points and fails
+// with ErrOverlap if two operations ever run at the same time.
+type Fake struct {
+	// TypeName is returned by Type, "fake" if empty
+	TypeName string
+
+	// ValidateErr, MountErr, UnmountErr and HealthErr are returned by
+	// the matching calls
+	ValidateErr error
+	MountErr    error
+	UnmountErr  error
+	HealthErr   error
+
+	// Output lines are reported to the observer on every mount
+	Output []string
+
+	// Delay makes Mount and Unmount take this long, honouring cancellation
+	Delay time.Duration
+
+	// OnMount is called with the mount point after every successful
+	// mount, like a filesystem appearing there
+	OnMount func(mountpoint string)
+
+	mu      sync.Mutex
+	active  int
+	overlap bool
+	mounted map[string][]string
+	calls   []string
+}
+
+var _ backend.Backend = (*Fake)(nil)
+
+// New creates a fake backend of the given type.
+func New(typeName string) *Fake {
+	return &Fake{TypeName: typeName}
+}
+
+// Type returns the configured type name.
+func (f *Fake) Type() string {
+	if f.TypeName == "" {
+		return "fake"
+	}
+	return f.TypeName
+}
+
+// Validate returns ValidateErr.
+func (f *Fake) Validate(req *backend.Request) error {
+	return f.ValidateErr
+}
+
+// MountArgs returns the volume name followed by its sorted options.
+func (f *Fake) MountArgs(req *backend.Request) []string {
+	args := []string{req.Name}
+	for _, key := range sortedKeys(req.Options) {
+		args = append(args, key+"="+req.Options[key])
+	}
+	if len(req.Servers) > 0 {
+		args = append(args, "servers="+strings.Join(req.Servers, ","))
+	}
+	return args
+}
+
+// Mount records the mount and reports Output to the observer.
+func (f *Fake) Mount(ctx context.Context, args []string, mountpoint string, obs backend.Observer) error {
+	f.enter("mount " + mountpoint)
+	defer f.leave()
+
+	if obs.Started != nil {
+		obs.Started(4242)
+	}
+	for _, line := range f.Output {
+		if obs.Output != nil {
+			obs.Output(line)
+		}
+	}
+	if err := f.wait(ctx); err != nil {
+		return err
+	}
+	if f.MountErr != nil {
+		return f.MountErr
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.mounted == nil {
+		f.mounted = make(map[string][]string)
+	}
+	f.mounted[mountpoint] = append([]string{}, args...)
+	if f.OnMount != nil {
+		f.OnMount(mountpoint)
+	}
+	return nil
+}
+
+// Unmount records the unmount.
+func (f *Fake) Unmount(ctx context.Context, mountpoint string) error {
+	f.enter("unmount " + mountpoint)
+	defer f.leave()
+
+	if err := f.wait(ctx); err != nil {
+		return err
+	}
+	if f.UnmountErr != nil {
+		return f.UnmountErr
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.mounted[mountpoint]; !ok {
+		return fmt.Errorf("%s is not mounted", mountpoint)
+	}
+	delete(f.mounted, mountpoint)
+	return nil
+}
+
+// HealthCheck returns HealthErr.
+func (f *Fake) HealthCheck(ctx context.Context, servers []string) error {
+	f.mu.Lock()
+	f.calls = append(f.calls, "health "+strings.Join(servers, ","))
+	f.mu.Unlock()
+	return f.HealthErr
+}
+
+// Mounted returns the arguments of the mount at mountpoint, if mounted.
+func (f *Fake) Mounted(mountpoint string) ([]string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	args, ok := f.mounted[mountpoint]
+	return args, ok
+}
+
+// MountCount returns the number of active mounts.
+func (f *Fake) MountCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.mounted)
+}
+
+// Calls returns the recorded calls, such as "mount /mnt/vol1".
+func (f *Fake) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string{}, f.calls...)
+}
+
+// Overlapped reports whether two operations ever ran at the same time.
+func (f *Fake) Overlapped() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.overlap
+}
+
+func (f *Fake) enter(call string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.active++
+	if f.active > 1 {
+		f.overlap = true
+	}
+	f.calls = append(f.calls, call)
+}
+
+func (f *Fake) leave() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.active--
+}
+
+func (f *Fake) wait(ctx context.Context) error {
+	if f.Delay <= 0 {
+		return ctx.Err()
+	}
+	select {
+	case <-time.After(f.Delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,45 @@
+package backend
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// BindMount makes the directory source visible at target.
+// A read-only bind mount needs a second remount, since the kernel
+// ignores MS_RDONLY when the bind mount is created.
+//
+// Parameters:
+// - source: The directory to expose, usually the mount point of another volume
+// - target: The directory to bind on
+// - readOnly: Whether writes through target are refused
+//
+// Returns:
+// - error if either mount call fails, nil otherwise
+func BindMount(source, target string, readOnly bool) error {
+	if err := syscall.Mount(source, target, "", syscall.MS_BIND, ""); err != nil {
+		return fmt.Errorf("bind %s on %s: %w", source, target, err)
+	}
+	if !readOnly {
+		return nil
+	}
+	if err := syscall.Mount("", target, "", syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY, ""); err != nil {
+		syscall.Unmount(target, syscall.MNT_DETACH)
+		return fmt.Errorf("remount %s read-only: %w", target, err)
+	}
+	return nil
+}
+
+// Unbind removes a bind mount created by BindMount.
+//
+// Parameters:
+// - target: The bind mount to remove
+//
+// Returns:
+// - error if the unmount fails, nil otherwise
+func Unbind(target string) error {
+	if err := syscall.Unmount(target, 0); err != nil {
+		return fmt.Errorf("umount %s: %w", target, err)
+	}
+	return nil
+}
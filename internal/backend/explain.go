@@ -0,0 +1,171 @@
+package backend
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Sources of the servers of a volume, as reported by ServersSource.
+const (
+	// ServersFromPlugin means the servers configured for the plugin
+	ServersFromPlugin = "SERVERS"
+
+	// ServersFromOptions means driver_opts.servers
+	ServersFromOptions = "driver_opts.servers"
+
+	// ServersFromGlusteropts means the -s arguments of driver_opts.glusteropts
+	ServersFromGlusteropts = "driver_opts.glusteropts"
+)
+
+// Argument is a mount argument with the reason it is passed.
+type Argument struct {
+	// Value is the argument as passed to the mount
+	Value string `json:"value"`
+
+	// Reason explains which setting produced the argument
+	Reason string `json:"reason"`
+}
+
+// Explainer is implemented by backends that can tell why they pass each
+// of their mount arguments.
+type Explainer interface {
+	// ExplainArgs returns the arguments of MountArgs with their reasons.
+	ExplainArgs(req *Request) []Argument
+}
+
+// Values returns the values of the arguments.
+//
+// Parameters:
+// - args: The explained arguments
+//
+// Returns:
+// - The argument values, in order
+func Values(args []Argument) []string {
+	if args == nil {
+		return nil
+	}
+	values := make([]string, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return values
+}
+
+// ServersSource reports where the servers of a volume come from.
+// Servers configured for the plugin take precedence over the options.
+//
+// Parameters:
+// - req: The volume to mount
+//
+// Returns:
+// - One of the ServersFrom constants, empty if no servers are set
+func ServersSource(req *Request) string {
+	if len(req.Servers) > 0 {
+		return ServersFromPlugin
+	}
+	if _, ok := req.Options["servers"]; ok {
+		return ServersFromOptions
+	}
+	if _, ok := req.Options["glusteropts"]; ok {
+		return ServersFromGlusteropts
+	}
+	return ""
+}
+
+// pathSource tells where the volume and subdirectory of a volume come
+// from.
+func pathSource(req *Request) string {
+	if _, ok := req.Options[SubdirOption]; ok {
+		return "driver_opts." + SubdirOption
+	}
+	return "the volume name"
+}
+
+// explainClientArgs returns the arguments of ClientArgs with their
+// reasons; source is where the servers come from, path where the volume
+// and subdirectory come from.
+func (s *Spec) explainClientArgs(source, path string) []Argument {
+	var args []Argument
+	for i, server := range s.Servers {
+		reason := "backup volfile server, from " + source
+		if i == 0 {
+			reason = "volfile server, from " + source
+		}
+		args = append(args, Argument{"-s", reason}, Argument{server, reason})
+	}
+
+	args = append(args, Argument{"--volfile-id=" + s.Volume, "GlusterFS volume, " + path + " up to the first slash"})
+	if s.Subdir != "" {
+		args = append(args, Argument{"--subdir-mount=/" + s.Subdir, "subdirectory, " + path + " after the first slash"})
+	}
+
+	if s.LogLevel != "" {
+		args = append(args, Argument{"--log-level=" + s.LogLevel, "driver_opts.log-level"})
+	}
+	if s.ReadOnly {
+		args = append(args, Argument{"--read-only", "driver_opts.ro=true"})
+	}
+	return append(args, Argument{"--logger=syslog", "client logs are collected by the plugin"})
+}
+
+// ExplainArgs returns the glusterfs client arguments of MountArgs with
+// their reasons.
+//
+// Parameters:
+// - req: The volume to mount
+//
+// Returns:
+// - The explained arguments, nil if the volume is invalid
+func (g *Glusterfs) ExplainArgs(req *Request) []Argument {
+	if glusteropts, ok := req.Options["glusteropts"]; ok {
+		var args []Argument
+		for _, arg := range strings.Fields(glusteropts) {
+			args = append(args, Argument{arg, "driver_opts.glusteropts, passed verbatim"})
+		}
+		if readOnly, _ := ReadOnly(req.Options); readOnly {
+			args = append(args, Argument{"--read-only", "driver_opts.ro=true"})
+		}
+		return append(args, Argument{"--logger=syslog", "client logs are collected by the plugin"})
+	}
+
+	spec, err := NewSpec(req)
+	if err != nil {
+		return nil
+	}
+	return spec.explainClientArgs(ServersSource(req), pathSource(req))
+}
+
+// ExplainArgs returns the mount(2) source and option string of
+// MountArgs with their reasons.
+//
+// Parameters:
+// - req: The volume to mount
+//
+// Returns:
+// - The explained arguments, nil if the volume is invalid
+func (n *GlusterfsNative) ExplainArgs(req *Request) []Argument {
+	spec, err := NewSpec(req)
+	if err != nil {
+		return nil
+	}
+
+	var reasons []string
+	if spec.ReadOnly {
+		reasons = append(reasons, "ro from driver_opts.ro=true")
+	}
+	if len(spec.Servers) > 1 {
+		reasons = append(reasons, "backup-volfile-servers from the other servers")
+	}
+	if spec.LogLevel != "" {
+		reasons = append(reasons, "log-level from driver_opts.log-level")
+	}
+	options := "mount options: none"
+	if len(reasons) > 0 {
+		options = "mount options: " + strings.Join(reasons, ", ")
+	}
+
+	return []Argument{
+		{spec.Source(), fmt.Sprintf("mount source: first server from %s, volume and subdirectory from %s", ServersSource(req), pathSource(req))},
+		{spec.OptionString(), options},
+	}
+}
@@ -0,0 +1,336 @@
+package backend
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"glusterfs-plugin/internal/errors"
+)
+
+const (
+	// GlusterfsType is the type of the GlusterFS backend.
+	GlusterfsType = "glusterfs"
+
+	// glusterfsBinary is the FUSE client used to mount volumes.
+	glusterfsBinary = "glusterfs"
+
+	// umountBinary is used to unmount volumes.
+	umountBinary = "umount"
+
+	// mountInfoFile lists the mounts visible to the plugin.
+	mountInfoFile = "/proc/self/mountinfo"
+
+	// glusterdPort is the management port used for health checks.
+	glusterdPort = "24007"
+
+	// defaultUnmountTimeout bounds the cleanup of an aborted mount when
+	// UnmountTimeout is not set, as the default UNMOUNT_TIMEOUT.
+	defaultUnmountTimeout = 30 * time.Second
+
+	// waitDelay is how long to wait for client output after it was killed.
+	waitDelay = time.Second
+)
+
+// Glusterfs mounts GlusterFS volumes with the glusterfs FUSE client.
+type Glusterfs struct {
+	// Binary is the glusterfs client executable.
+	Binary string
+
+	// UmountBinary is the umount executable.
+	UmountBinary string
+
+	// MountInfo is the mountinfo file used to detect partial mounts.
+	MountInfo string
+
+	// UnmountTimeout bounds the cleanup of an aborted mount, like the
+	// UNMOUNT_TIMEOUT of other unmounts.
+	UnmountTimeout time.Duration
+}
+
+// NewGlusterfs creates a GlusterFS backend using the system binaries.
+//
+// Returns:
+// - A new Glusterfs backend
+func NewGlusterfs() *Glusterfs {
+	return &Glusterfs{
+		Binary:         glusterfsBinary,
+		UmountBinary:   umountBinary,
+		MountInfo:      mountInfoFile,
+		UnmountTimeout: defaultUnmountTimeout,
+	}
+}
+
+// Type returns GlusterfsType.
+func (g *Glusterfs) Type() string {
+	return GlusterfsType
+}
+
+// Validate checks that the volume options can be turned into client
+// arguments. Volumes using glusteropts are passed through unchecked.
+//
+// Parameters:
+// - req: The volume to mount
+//
+// Returns:
+// - ValidationError if the options are invalid, nil otherwise
+func (g *Glusterfs) Validate(req *Request) error {
+	if _, ok := req.Options["glusteropts"]; ok {
+		_, err := ReadOnly(req.Options)
+		return err
+	}
+	_, err := NewSpec(req)
+	return err
+}
+
+// MountArgs returns the glusterfs client arguments for the volume.
+//
+// The arguments include:
+// - Server addresses (-s option)
+// - Volume ID (--volfile-id)
+// - Subdirectory mount point (--subdir-mount) if specified
+// - Client log level (--log-level) if specified
+// - Read-only mode (--read-only) if driver_opts.ro is true
+// - Logger configuration (--logger=syslog)
+//
+// Servers configured for the plugin take precedence over
+// driver_opts.servers; driver_opts.glusteropts is used verbatim, split
+// on runs of whitespace like the driver compares it.
+//
+// Parameters:
+// - req: The volume to mount
+//
+// Returns:
+// - List of glusterfs client arguments, without the mount point
+func (g *Glusterfs) MountArgs(req *Request) []string {
+	if glusteropts, ok := req.Options["glusteropts"]; ok {
+		args := strings.Fields(glusteropts)
+		if readOnly, _ := ReadOnly(req.Options); readOnly {
+			args = append(args, "--read-only")
+		}
+		return append(args, "--logger=syslog")
+	}
+
+	spec, err := NewSpec(req)
+	if err != nil {
+		log.Printf("warning: cannot build mount arguments for volume %s: %v", req.Name, err)
+		return nil
+	}
+	return spec.ClientArgs()
+}
+
+// Mount runs the glusterfs client for the given mount point.
+// The client runs in its own process group, which is killed as a whole
+// when the context is cancelled; a mount it may have completed is then
+// lazily unmounted.
+//
+// Parameters:
+// - ctx: Bounds the lifetime of the client
+// - args: The client arguments from MountArgs
+// - mountpoint: The directory to mount on
+// - obs: Receives the client PID and output
+//
+// Returns:
+// - error if the client fails or is cancelled, nil otherwise
+func (g *Glusterfs) Mount(ctx context.Context, args []string, mountpoint string, obs Observer) error {
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, g.Binary, append(append([]string{}, args...), mountpoint)...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = waitDelay
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if obs.Started != nil {
+		obs.Started(cmd.Process.Pid)
+	}
+
+	err := cmd.Wait()
+
+	if obs.Output != nil {
+		scanner := bufio.NewScanner(&output)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				obs.Output(line)
+			}
+		}
+	}
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		g.cleanup(mountpoint)
+		return ctxErr
+	}
+	return err
+}
+
+// Unmount unmounts the given mount point.
+//
+// Parameters:
+// - ctx: Bounds the lifetime of the umount command
+// - mountpoint: The directory to unmount
+//
+// Returns:
+// - error if the unmount fails or is cancelled, nil otherwise
+func (g *Glusterfs) Unmount(ctx context.Context, mountpoint string) error {
+	return g.umount(ctx, mountpoint)
+}
+
+// HealthCheck verifies that glusterd answers on at least one server.
+//
+// Parameters:
+// - ctx: Bounds the connection attempts
+// - servers: The servers to check, with an optional port
+//
+// Returns:
+// - ServerUnreachableError if no server answers, nil otherwise
+func (g *Glusterfs) HealthCheck(ctx context.Context, servers []string) error {
+	return checkServers(ctx, servers)
+}
+
+// checkServers verifies that glusterd answers on at least one server.
+func checkServers(ctx context.Context, servers []string) error {
+	if len(servers) == 0 {
+		return errors.NewValidationError("no servers to check")
+	}
+
+	var dialer net.Dialer
+	var lastErr error
+	for _, server := range servers {
+		address := server
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			address = net.JoinHostPort(server, glusterdPort)
+		}
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		lastErr = err
+	}
+	return errors.NewServerUnreachableError(
+		fmt.Sprintf("none of %s is reachable", strings.Join(servers, ",")),
+		lastErr,
+	)
+}
+
+// umount runs the umount binary with optional extra flags.
+func (g *Glusterfs) umount(ctx context.Context, mountpoint string, extraArgs ...string) error {
+	cmd := exec.CommandContext(ctx, g.UmountBinary, append(extraArgs, mountpoint)...)
+	cmd.WaitDelay = waitDelay
+
+	output, err := cmd.CombinedOutput()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	if err != nil && len(output) > 0 {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return err
+}
+
+// cleanup lazily unmounts a mount point left behind by an aborted
+// mount. It is a no-op if nothing is mounted there.
+func (g *Glusterfs) cleanup(mountpoint string) {
+	mounted, err := IsMounted(g.MountInfo, mountpoint)
+	if err != nil {
+		log.Printf("warning: cannot check mount state of %s: %v", mountpoint, err)
+		return
+	}
+	if !mounted {
+		return
+	}
+
+	// The mount context is already done, use a fresh bounded one
+	ctx, cancel := context.WithTimeout(context.Background(), g.UnmountTimeout)
+	defer cancel()
+	if err := g.umount(ctx, mountpoint, "-l"); err != nil {
+		log.Printf("error: failed to clean up aborted mount at %s: %v", mountpoint, err)
+		return
+	}
+	log.Printf("cleaned up aborted mount at %s", mountpoint)
+}
+
+// appendVolumeOptionsByVolumeName appends the command line arguments for volume mounting.
+// It handles both simple volume names and subdirectory mounts.
+//
+// The function adds:
+// - --volfile-id for the volume name
+// - --subdir-mount for any subdirectory path
+//
+// The subdirectory is normalized by ParseVolumePath; invalid names add
+// no arguments.
+//
+// Parameters:
+// - args: The existing command line arguments
+// - volumeName: The name of the volume, optionally including a subdirectory path
+//
+// Returns:
+// - Updated list of command line arguments
+func appendVolumeOptionsByVolumeName(args []string, volumeName string) []string {
+	if volumeName == "" {
+		log.Printf("warning: appendVolumeOptionsByVolumeName called with empty volume name")
+		return args
+	}
+
+	volume, subdir, err := ParseVolumePath(volumeName)
+	if err != nil {
+		log.Printf("warning: appendVolumeOptionsByVolumeName called with invalid volume name: %v", err)
+		return args
+	}
+	ret := append(args, "--volfile-id="+volume)
+	if subdir != "" {
+		ret = append(ret, "--subdir-mount=/"+subdir)
+	}
+	return ret
+}
+
+// IsMounted reports whether mountpoint appears in the given mountinfo file.
+//
+// Parameters:
+// - mountInfo: Path of a file in /proc/self/mountinfo format
+// - mountpoint: The directory to look for
+//
+// Returns:
+// - true if the directory is a mount point
+// - error if the file cannot be read
+func IsMounted(mountInfo, mountpoint string) (bool, error) {
+	f, err := os.Open(mountInfo)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	mountpoint = filepath.Clean(mountpoint)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// The fifth field is the mount point, with spaces escaped as \040
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 4 && unescapeMountPath(fields[4]) == mountpoint {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// unescapeMountPath decodes the octal escapes used in mountinfo paths.
+func unescapeMountPath(path string) string {
+	if !strings.Contains(path, `\`) {
+		return path
+	}
+	replacer := strings.NewReplacer(`\040`, " ", `\011`, "\t", `\012`, "\n", `\134`, `\`)
+	return replacer.Replace(path)
+}
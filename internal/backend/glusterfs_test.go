@@ -0,0 +1,275 @@
+package backend
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"glusterfs-plugin/internal/errors"
+)
+
+// writeScript creates an executable shell script in dir and returns its path.
+func writeScript(t *testing.T, dir, name, body string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0755))
+	return path
+}
+
+// newTestGlusterfs returns a backend running the given fake client script.
+// The fake umount records its arguments in UmountBinary + ".calls".
+func newTestGlusterfs(t *testing.T, mountScript string) *Glusterfs {
+	t.Helper()
+	dir := t.TempDir()
+	g := NewGlusterfs()
+	g.Binary = writeScript(t, dir, "glusterfs", mountScript)
+	g.UmountBinary = writeScript(t, dir, "umount", `echo "$@" >> "$0.calls"`)
+	g.MountInfo = filepath.Join(dir, "mountinfo")
+	require.NoError(t, os.WriteFile(g.MountInfo, nil, 0644))
+	return g
+}
+
+func TestGlusterfs_MountArgs(t *testing.T) {
+	g := NewGlusterfs()
+
+	tests := []struct {
+		name string
+		req  *Request
+		want []string
+	}{
+		{
+			name: "servers from plugin",
+			req:  &Request{Name: "test/sub", Options: map[string]string{}, Servers: []string{"server1", "server2"}},
+			want: []string{"-s", "server1", "-s", "server2", "--volfile-id=test", "--subdir-mount=/sub", "--logger=syslog"},
+		},
+		{
+			name: "servers from options",
+			req:  &Request{Name: "test", Options: map[string]string{"servers": "server1,server2"}},
+			want: []string{"-s", "server1", "-s", "server2", "--volfile-id=test", "--logger=syslog"},
+		},
+		{
+			name: "glusteropts",
+			req:  &Request{Name: "whatever", Options: map[string]string{"glusteropts": "-s server1 --volfile-id=test"}},
+			want: []string{"-s", "server1", "--volfile-id=test", "--logger=syslog"},
+		},
+		{
+			name: "glusteropts with repeated spaces",
+			req:  &Request{Name: "whatever", Options: map[string]string{"glusteropts": " -s  server1\t--volfile-id=test "}},
+			want: []string{"-s", "server1", "--volfile-id=test", "--logger=syslog"},
+		},
+		{
+			name: "read-only",
+			req:  &Request{Name: "test", Options: map[string]string{"servers": "server1", "ro": "true"}},
+			want: []string{"-s", "server1", "--volfile-id=test", "--read-only", "--logger=syslog"},
+		},
+		{
+			name: "read-only glusteropts",
+			req:  &Request{Name: "whatever", Options: map[string]string{"glusteropts": "-s server1 --volfile-id=test", "ro": "1"}},
+			want: []string{"-s", "server1", "--volfile-id=test", "--read-only", "--logger=syslog"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, g.MountArgs(tt.req))
+			assert.Equal(t, tt.want, Values(g.ExplainArgs(tt.req)))
+		})
+	}
+}
+
+func TestGlusterfs_Mount(t *testing.T) {
+	g := newTestGlusterfs(t, `echo "mounting $*"; echo "warning" >&2`)
+
+	var pid int
+	var lines []string
+	obs := Observer{
+		Started: func(p int) { pid = p },
+		Output:  func(line string) { lines = append(lines, line) },
+	}
+	require.NoError(t, g.Mount(context.Background(), []string{"-s", "server1"}, "/mnt/vol1", obs))
+	assert.NotZero(t, pid)
+	assert.ElementsMatch(t, []string{"mounting -s server1 /mnt/vol1", "warning"}, lines)
+
+	require.NoError(t, g.Unmount(context.Background(), "/mnt/vol1"))
+	calls, err := os.ReadFile(g.UmountBinary + ".calls")
+	require.NoError(t, err)
+	assert.Equal(t, "/mnt/vol1\n", string(calls))
+}
+
+func TestGlusterfs_MountFailure(t *testing.T) {
+	g := newTestGlusterfs(t, "exit 3")
+	err := g.Mount(context.Background(), nil, "/mnt/vol1", Observer{})
+	assert.Error(t, err)
+
+	g.UmountBinary = writeScript(t, t.TempDir(), "umount", `echo "not mounted" >&2; exit 32`)
+	err = g.Unmount(context.Background(), "/mnt/vol1")
+	assert.EqualError(t, err, "exit status 32: not mounted")
+}
+
+func TestGlusterfs_MountTimeoutKillsClientAndCleansUp(t *testing.T) {
+	// The client forks a child that would keep running if only the
+	// direct child process was killed
+	g := newTestGlusterfs(t, "sleep 30 & wait")
+
+	// Pretend the client managed to mount before hanging
+	mountpoint := "/mnt/vol1"
+	line := "36 35 0:42 / " + mountpoint + " rw,relatime - fuse.glusterfs server1:vol1 rw\n"
+	require.NoError(t, os.WriteFile(g.MountInfo, []byte(line), 0644))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := g.Mount(ctx, nil, mountpoint, Observer{})
+	assert.Less(t, time.Since(start), 10*time.Second, "client should be killed")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	calls, readErr := os.ReadFile(g.UmountBinary + ".calls")
+	require.NoError(t, readErr)
+	assert.Equal(t, "-l "+mountpoint+"\n", string(calls))
+}
+
+func TestGlusterfs_CleanupHonoursUnmountTimeout(t *testing.T) {
+	g := newTestGlusterfs(t, "sleep 30")
+	g.UmountBinary = writeScript(t, t.TempDir(), "umount", "sleep 30")
+	g.UnmountTimeout = 100 * time.Millisecond
+
+	mountpoint := "/mnt/vol1"
+	line := "36 35 0:42 / " + mountpoint + " rw,relatime - fuse.glusterfs server1:vol1 rw\n"
+	require.NoError(t, os.WriteFile(g.MountInfo, []byte(line), 0644))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	assert.ErrorIs(t, g.Mount(ctx, nil, mountpoint, Observer{}), context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 10*time.Second, "cleanup should give up after UnmountTimeout")
+}
+
+func TestGlusterfs_MountCancelledWithoutPartialMount(t *testing.T) {
+	g := newTestGlusterfs(t, "sleep 30")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	err := g.Mount(ctx, nil, "/mnt/vol1", Observer{})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// Nothing was mounted, so there is nothing to clean up
+	_, statErr := os.Stat(g.UmountBinary + ".calls")
+	assert.True(t, os.IsNotExist(statErr))
+}
+
+func TestGlusterfs_UnmountTimeout(t *testing.T) {
+	g := newTestGlusterfs(t, "exit 0")
+	g.UmountBinary = writeScript(t, t.TempDir(), "umount", "sleep 30")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, g.Unmount(ctx, "/mnt/vol1"), context.DeadlineExceeded)
+}
+
+func TestGlusterfs_HealthCheck(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	g := NewGlusterfs()
+	ctx := context.Background()
+
+	assert.NoError(t, g.HealthCheck(ctx, []string{closedAddr, listener.Addr().String()}))
+	assert.ErrorIs(t, g.HealthCheck(ctx, []string{closedAddr}), errors.ErrServerUnreachable)
+	assert.ErrorIs(t, g.HealthCheck(ctx, nil), errors.ErrValidation)
+}
+
+func TestIsMounted(t *testing.T) {
+	mountInfo := filepath.Join(t.TempDir(), "mountinfo")
+	content := "22 1 8:1 / / rw - ext4 /dev/sda1 rw\n" +
+		"36 22 0:42 / /mnt/with\\040space rw - fuse.glusterfs server1:vol1 rw\n"
+	require.NoError(t, os.WriteFile(mountInfo, []byte(content), 0644))
+
+	tests := []struct {
+		mountpoint string
+		want       bool
+	}{
+		{mountpoint: "/", want: true},
+		{mountpoint: "/mnt/with space", want: true},
+		{mountpoint: "/mnt/with space/", want: true},
+		{mountpoint: "/mnt/other", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mountpoint, func(t *testing.T) {
+			got, err := IsMounted(mountInfo, tt.mountpoint)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := IsMounted(filepath.Join(t.TempDir(), "missing"), "/")
+	assert.Error(t, err)
+}
+
+func TestAppendVolumeOptionsByVolumeName(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		volumeName string
+		want       []string
+	}{
+		{
+			name:       "empty volume name",
+			args:       []string{"mount"},
+			volumeName: "",
+			want:       []string{"mount"},
+		},
+		{
+			name:       "simple volume",
+			args:       []string{"mount"},
+			volumeName: "simplevolume",
+			want:       []string{"mount", "--volfile-id=simplevolume"},
+		},
+		{
+			name:       "one level subdir",
+			args:       []string{"mount"},
+			volumeName: "simplevolume/levelone",
+			want:       []string{"mount", "--volfile-id=simplevolume", "--subdir-mount=/levelone"},
+		},
+		{
+			name:       "two levels subdir",
+			args:       []string{"mount"},
+			volumeName: "simplevolume/levelone/level2",
+			want:       []string{"mount", "--volfile-id=simplevolume", "--subdir-mount=/levelone/level2"},
+		},
+		{
+			name:       "slashes normalized",
+			args:       []string{"mount"},
+			volumeName: "simplevolume//levelone/",
+			want:       []string{"mount", "--volfile-id=simplevolume", "--subdir-mount=/levelone"},
+		},
+		{
+			name:       "parent directory",
+			args:       []string{"mount"},
+			volumeName: "simplevolume/../../etc",
+			want:       []string{"mount"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := appendVolumeOptionsByVolumeName(tt.args, tt.volumeName)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
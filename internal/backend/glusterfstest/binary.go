@@ -0,0 +1,175 @@
+// Package glusterfstest provides fake glusterfs and umount binaries for
+// tests that run the real GlusterFS backend without root or FUSE.
+// The fakes are the test binary itself, executed under another name:
+// tests install them with Install and call Main from TestMain.
+package glusterfstest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const (
+	// Glusterfs is the name of the fake glusterfs client.
+	Glusterfs = "glusterfs"
+
+	// Umount is the name of the fake umount.
+	Umount = "umount"
+
+	// dirEnv points the fakes at the directory of their behaviors and
+	// calls.
+	dirEnv = "GLUSTERFSTEST_DIR"
+)
+
+// Behavior is what a fake binary does when it runs.
+type Behavior struct {
+	// Hang makes the binary block until it is killed
+	Hang bool `json:"hang,omitempty"`
+
+	// Stderr is written to the standard error
+	Stderr string `json:"stderr,omitempty"`
+
+	// ExitCode is the exit status, 0 for success
+	ExitCode int `json:"exitCode,omitempty"`
+}
+
+// Succeed makes a fake exit successfully, the default.
+var Succeed = Behavior{}
+
+// Hang makes a fake block until it is killed.
+var Hang = Behavior{Hang: true}
+
+// Fail makes a fake exit with status 1 after writing stderr.
+func Fail(stderr string) Behavior {
+	return Behavior{Stderr: stderr, ExitCode: 1}
+}
+
+// Binaries are installed fake binaries.
+type Binaries struct {
+	// Dir holds the fakes, their behaviors and their recorded calls
+	Dir string
+}
+
+// Install installs fake glusterfs and umount binaries in a temporary
+// directory for the duration of a test. The fakes succeed until Set
+// changes their behavior. They find their directory through the
+// environment, so the test must not run in parallel.
+//
+// Parameters:
+// - t: The test
+//
+// Returns:
+// - The installed binaries
+func Install(t testing.TB) *Binaries {
+	t.Helper()
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatalf("cannot find the test binary: %v", err)
+	}
+
+	dir := t.TempDir()
+	for _, name := range []string{Glusterfs, Umount} {
+		if err := os.Symlink(executable, filepath.Join(dir, name)); err != nil {
+			t.Fatalf("cannot install fake %s: %v", name, err)
+		}
+	}
+	t.Setenv(dirEnv, dir)
+	return &Binaries{Dir: dir}
+}
+
+// Path returns the path of the fake binary name.
+func (b *Binaries) Path(name string) string {
+	return filepath.Join(b.Dir, name)
+}
+
+// Set changes the behavior of the fake binary name for its next runs.
+func (b *Binaries) Set(name string, behavior Behavior) error {
+	data, err := json.Marshal(behavior)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(b.Dir, name+".behavior"), data, 0600)
+}
+
+// Calls returns the arguments of every run of the fake binary name, in
+// order, without the binary itself.
+func (b *Binaries) Calls(name string) ([][]string, error) {
+	f, err := os.Open(filepath.Join(b.Dir, name+".calls"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var calls [][]string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var args []string
+		if err := json.Unmarshal(scanner.Bytes(), &args); err != nil {
+			return nil, err
+		}
+		calls = append(calls, args)
+	}
+	return calls, scanner.Err()
+}
+
+// Main runs a fake binary and exits if the test binary was executed as
+// one; otherwise it returns. Call it from TestMain before m.Run.
+func Main() {
+	name := filepath.Base(os.Args[0])
+	dir := os.Getenv(dirEnv)
+	if (name != Glusterfs && name != Umount) || dir == "" {
+		return
+	}
+	os.Exit(run(dir, name, os.Args[1:]))
+}
+
+// run records the arguments of a fake binary and acts out its behavior.
+func run(dir, name string, args []string) int {
+	if err := record(filepath.Join(dir, name+".calls"), args); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: cannot record call: %v\n", name, err)
+		return 2
+	}
+
+	var behavior Behavior
+	data, err := os.ReadFile(filepath.Join(dir, name+".behavior"))
+	if err == nil {
+		err = json.Unmarshal(data, &behavior)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "%s: invalid behavior: %v\n", name, err)
+		return 2
+	}
+
+	if behavior.Stderr != "" {
+		fmt.Fprintln(os.Stderr, behavior.Stderr)
+	}
+	for behavior.Hang {
+		time.Sleep(time.Hour)
+	}
+	return behavior.ExitCode
+}
+
+// record appends the arguments of a run to the calls file.
+func record(path string, args []string) error {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
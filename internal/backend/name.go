@@ -0,0 +1,135 @@
+package backend
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"glusterfs-plugin/internal/errors"
+)
+
+// SubdirOption names the GlusterFS volume and subdirectory to mount, in
+// the "volume[/subdir]" form of volume names, for tools whose volume
+// names cannot contain slashes. The volume name is then only an alias.
+const SubdirOption = "subdir"
+
+// maxVolumeNameLength is the longest volume name the gluster CLI accepts.
+const maxVolumeNameLength = 64
+
+// maxSnapshotNameLength is the longest snapshot name the gluster CLI
+// accepts.
+const maxSnapshotNameLength = 255
+
+// reservedVolumeNames are words of the gluster CLI and volume files that
+// cannot name a volume.
+var reservedVolumeNames = []string{
+	"all", "volume", "type", "subvolumes", "option", "end-volume", "description", "force",
+	"snap-max-hard-limit", "snap-max-soft-limit", "auto-delete", "activate-on-create",
+}
+
+// VolumePath returns the GlusterFS volume and subdirectory a volume
+// mounts: those of driver_opts.subdir if set, of the volume name
+// otherwise.
+//
+// Parameters:
+// - req: The volume to mount
+//
+// Returns:
+//   - The GlusterFS volume and the normalized subdirectory, see ParseVolumePath
+//   - ValidationError if the path is invalid, or if both the volume name
+//     and driver_opts.subdir name a subdirectory
+func VolumePath(req *Request) (string, string, error) {
+	path, ok := req.Options[SubdirOption]
+	if !ok {
+		return ParseVolumePath(req.Name)
+	}
+	if strings.Contains(req.Name, "/") {
+		return "", "", errors.NewValidationError(fmt.Sprintf(
+			"volume name %s contains a slash, subdir is only for volume names without one", req.Name))
+	}
+	return ParseVolumePath(path)
+}
+
+// ParseVolumePath splits a "volume[/subdir]" path into the GlusterFS
+// volume and the subdirectory, without leading slash. Empty and "."
+// segments of the subdirectory are dropped, so "vol//a/./b/" is "vol"
+// and "a/b".
+//
+// Parameters:
+// - path: The path, such as a volume name
+//
+// Returns:
+//   - The GlusterFS volume and the normalized subdirectory, empty for the
+//     whole volume
+//   - ValidationError if the volume name breaks the GlusterFS naming
+//     rules, or the subdirectory has ".." segments or control characters
+func ParseVolumePath(path string) (string, string, error) {
+	volume, rest, _ := strings.Cut(path, "/")
+	if err := CheckVolumeName(volume); err != nil {
+		return "", "", err
+	}
+
+	var segments []string
+	for _, segment := range strings.Split(rest, "/") {
+		switch segment {
+		case "", ".":
+			continue
+		case "..":
+			return "", "", errors.NewValidationError(fmt.Sprintf("subdirectory of %s cannot contain ..", path))
+		}
+		if strings.IndexFunc(segment, unicode.IsControl) >= 0 {
+			return "", "", errors.NewValidationError(fmt.Sprintf("subdirectory of %q cannot contain control characters", path))
+		}
+		segments = append(segments, segment)
+	}
+	return volume, strings.Join(segments, "/"), nil
+}
+
+// CheckVolumeName checks a GlusterFS volume name against the rules of
+// the gluster CLI: letters, digits, '-' and '_', not starting with '-',
+// at most 64 characters and not a reserved word.
+//
+// Parameters:
+// - name: The GlusterFS volume name
+//
+// Returns:
+// - ValidationError if the name is invalid, nil otherwise
+func CheckVolumeName(name string) error {
+	if Contains(reservedVolumeNames, strings.ToLower(name)) {
+		return errors.NewValidationError(fmt.Sprintf("volume name %s is reserved by GlusterFS", name))
+	}
+	return checkName("volume", name, maxVolumeNameLength)
+}
+
+// CheckSnapshotName checks a GlusterFS snapshot name, which follows the
+// rules of volume names except that it may be up to 255 characters.
+// Snapshot names reach the gluster CLI as arguments.
+//
+// Parameters:
+// - name: The GlusterFS snapshot name
+//
+// Returns:
+// - ValidationError if the name is invalid, nil otherwise
+func CheckSnapshotName(name string) error {
+	return checkName("snapshot", name, maxSnapshotNameLength)
+}
+
+// checkName checks the characters and length of a volume or snapshot
+// name.
+func checkName(kind, name string, maxLength int) error {
+	switch {
+	case name == "":
+		return errors.NewValidationError(fmt.Sprintf("%s name cannot be empty", kind))
+	case len(name) > maxLength:
+		return errors.NewValidationError(fmt.Sprintf("%s name %s is longer than %d characters", kind, name, maxLength))
+	case strings.HasPrefix(name, "-"):
+		return errors.NewValidationError(fmt.Sprintf("%s name %s cannot start with -", kind, name))
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return errors.NewValidationError(fmt.Sprintf(
+				"%s name %q can only contain letters, digits, - and _", kind, name))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package backend
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"glusterfs-plugin/internal/errors"
+)
+
+func TestParseVolumePath(t *testing.T) {
+	tests := []struct {
+		path       string
+		wantVolume string
+		wantSubdir string
+	}{
+		{path: "vol1", wantVolume: "vol1"},
+		{path: "vol1/", wantVolume: "vol1"},
+		{path: "vol1/apps", wantVolume: "vol1", wantSubdir: "apps"},
+		{path: "vol1//apps///web/", wantVolume: "vol1", wantSubdir: "apps/web"},
+		{path: "vol1/./apps/.", wantVolume: "vol1", wantSubdir: "apps"},
+		{path: "data_2-b/my app", wantVolume: "data_2-b", wantSubdir: "my app"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			volume, subdir, err := ParseVolumePath(tt.path)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantVolume, volume)
+			assert.Equal(t, tt.wantSubdir, subdir)
+		})
+	}
+}
+
+func TestParseVolumePath_Invalid(t *testing.T) {
+	tests := []struct {
+		path    string
+		wantErr string
+	}{
+		{path: "", wantErr: "cannot be empty"},
+		{path: "/apps", wantErr: "cannot be empty"},
+		{path: "vol/../../etc", wantErr: "cannot contain .."},
+		{path: "vol1/apps/..", wantErr: "cannot contain .."},
+		{path: "vol1/a\nb", wantErr: "control characters"},
+		{path: "vol.1", wantErr: "can only contain letters, digits, - and _"},
+		{path: "vól1", wantErr: "can only contain letters, digits, - and _"},
+		{path: "-vol1", wantErr: "cannot start with -"},
+		{path: "all/apps", wantErr: "reserved by GlusterFS"},
+		{path: strings.Repeat("v", 65), wantErr: "longer than 64 characters"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			_, _, err := ParseVolumePath(tt.path)
+			assert.ErrorIs(t, err, errors.ErrValidation)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestVolumePath_SubdirOption(t *testing.T) {
+	volume, subdir, err := VolumePath(&Request{Name: "web-data", Options: map[string]string{"subdir": "vol1/apps/web/"}})
+	require.NoError(t, err)
+	assert.Equal(t, "vol1", volume)
+	assert.Equal(t, "apps/web", subdir)
+
+	// The alias may name a whole volume, whatever the volume name is
+	volume, subdir, err = VolumePath(&Request{Name: "data.v2", Options: map[string]string{"subdir": "vol1"}})
+	require.NoError(t, err)
+	assert.Equal(t, "vol1", volume)
+	assert.Empty(t, subdir)
+
+	_, _, err = VolumePath(&Request{Name: "vol1/apps", Options: map[string]string{"subdir": "vol1/web"}})
+	assert.ErrorContains(t, err, "subdir is only for volume names without one")
+	_, _, err = VolumePath(&Request{Name: "web-data", Options: map[string]string{"subdir": "vol1/../etc"}})
+	assert.ErrorContains(t, err, "cannot contain ..")
+}
+
+func TestCheckSnapshotName(t *testing.T) {
+	assert.NoError(t, CheckSnapshotName("shared-20261019-101500-3fa2c1"))
+	assert.NoError(t, CheckSnapshotName(strings.Repeat("s", 255)), "snapshot names may be longer than volume names")
+	assert.NoError(t, CheckSnapshotName("all"), "volume words are not reserved for snapshots")
+
+	assert.ErrorContains(t, CheckSnapshotName(""), "snapshot name cannot be empty")
+	assert.ErrorContains(t, CheckSnapshotName("--help"), "cannot start with -")
+	assert.ErrorContains(t, CheckSnapshotName("nightly;rm"), "can only contain letters, digits, - and _")
+	assert.ErrorContains(t, CheckSnapshotName(strings.Repeat("s", 256)), "longer than 255 characters")
+}
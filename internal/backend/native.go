@@ -0,0 +1,218 @@
+package backend
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// filesystemsFile lists the filesystem types the kernel supports.
+const filesystemsFile = "/proc/filesystems"
+
+// helperOptions are the mount -t glusterfs options that the mount.glusterfs
+// helper turns into client arguments. The kernel does not know them, so
+// they are not passed as mount(2) data.
+var helperOptions = []string{"backup-volfile-servers", "log-level", "log-file", "logger", "volfile-id", "subdir-mount"}
+
+// GlusterfsNative mounts GlusterFS volumes with the mount(2) system call
+// instead of running the glusterfs client binary.
+// It uses the same option model as the FUSE client, rendered as a
+// mount -t glusterfs source and option string. The kernel must provide
+// the glusterfs filesystem type, which mainline kernels do not: there
+// GlusterFS is a FUSE filesystem and mount.glusterfs a userspace helper.
+// CheckSupport tells whether this backend can mount at all. Options only
+// the mount.glusterfs helper understands, such as the backup servers,
+// are not passed to the kernel.
+type GlusterfsNative struct {
+	// Filesystems is the file listing the filesystem types of the kernel.
+	Filesystems string
+
+	// mount and unmount are the system calls, replaceable in tests
+	mount   func(source, target, fstype string, flags uintptr, data string) error
+	unmount func(target string, flags int) error
+}
+
+// NewGlusterfsNative creates a GlusterFS backend using mount(2).
+//
+// Returns:
+// - A new GlusterfsNative backend
+func NewGlusterfsNative() *GlusterfsNative {
+	return &GlusterfsNative{
+		Filesystems: filesystemsFile,
+		mount:       unix.Mount,
+		unmount:     unix.Unmount,
+	}
+}
+
+// CheckSupport checks that the kernel provides the glusterfs filesystem
+// type, without which every mount(2) fails with ENODEV.
+//
+// Returns:
+//   - error if the type is not listed in Filesystems or the list cannot
+//     be read, nil otherwise
+func (n *GlusterfsNative) CheckSupport() error {
+	f, err := os.Open(n.Filesystems)
+	if err != nil {
+		return fmt.Errorf("cannot read the filesystem types of the kernel: %w", err)
+	}
+	defer f.Close()
+
+	// Lines are "[nodev]<TAB>type"
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 0 && fields[len(fields)-1] == GlusterfsType {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("cannot read the filesystem types of the kernel: %w", err)
+	}
+	return fmt.Errorf("the kernel has no %s filesystem type (not in %s); GlusterFS mounts through FUSE, use MOUNT_METHOD=fuse",
+		GlusterfsType, n.Filesystems)
+}
+
+// Type returns GlusterfsType, the native path replaces the FUSE client.
+func (n *GlusterfsNative) Type() string {
+	return GlusterfsType
+}
+
+// Validate checks that the volume can be described by the option model.
+// Volumes using glusteropts are rejected, since raw client arguments have
+// no mount(2) equivalent.
+//
+// Parameters:
+// - req: The volume to mount
+//
+// Returns:
+// - ValidationError if the options are invalid, nil otherwise
+func (n *GlusterfsNative) Validate(req *Request) error {
+	_, err := NewSpec(req)
+	return err
+}
+
+// MountArgs returns the mount source followed by the option string.
+//
+// Parameters:
+// - req: The volume to mount
+//
+// Returns:
+// - The source and option string, nil if the volume is invalid
+func (n *GlusterfsNative) MountArgs(req *Request) []string {
+	spec, err := NewSpec(req)
+	if err != nil {
+		log.Printf("warning: cannot build mount options for volume %s: %v", req.Name, err)
+		return nil
+	}
+	return []string{spec.Source(), spec.OptionString()}
+}
+
+// Mount calls mount(2) with the source and options from MountArgs,
+// leaving out the options of the mount.glusterfs helper. The system call cannot be interrupted; if the context is done first,
+// Mount returns and a mount completing later is detached again.
+//
+// Parameters:
+// - ctx: Bounds the wait for the system call
+// - args: The source and option string from MountArgs
+// - mountpoint: The directory to mount on
+// - obs: Unused, there is no client process
+//
+// Returns:
+// - error if the mount fails or the context is done, nil otherwise
+func (n *GlusterfsNative) Mount(ctx context.Context, args []string, mountpoint string, obs Observer) error {
+	if len(args) != 2 {
+		return fmt.Errorf("expected source and options, got %d arguments", len(args))
+	}
+	flags, data, ignored := mountFlags(args[1])
+	if len(ignored) > 0 {
+		log.Printf("warning: mount(2) of %s ignores the mount.glusterfs options %s", args[0], strings.Join(ignored, ","))
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- n.mount(args[0], mountpoint, GlusterfsType, flags, data)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("mount %s on %s: %w", args[0], mountpoint, err)
+		}
+		return nil
+	case <-ctx.Done():
+		go func() {
+			if err := <-done; err == nil {
+				if err := n.unmount(mountpoint, unix.MNT_DETACH); err != nil {
+					log.Printf("error: failed to clean up aborted mount at %s: %v", mountpoint, err)
+				}
+			}
+		}()
+		return ctx.Err()
+	}
+}
+
+// Unmount calls umount(2) on the mount point.
+// Like mount(2), the system call can block on unreachable servers and
+// cannot be interrupted; if the context is done first, Unmount returns
+// and the system call is left to finish in the background.
+//
+// Parameters:
+// - ctx: Bounds the wait for the system call
+// - mountpoint: The directory to unmount
+//
+// Returns:
+// - error if the unmount fails or the context is done, nil otherwise
+func (n *GlusterfsNative) Unmount(ctx context.Context, mountpoint string) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- n.unmount(mountpoint, 0)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("umount %s: %w", mountpoint, err)
+		}
+		return nil
+	case <-ctx.Done():
+		go func() {
+			if err := <-done; err != nil {
+				log.Printf("error: abandoned unmount of %s failed: %v", mountpoint, err)
+			}
+		}()
+		return ctx.Err()
+	}
+}
+
+// HealthCheck verifies that glusterd answers on at least one server.
+func (n *GlusterfsNative) HealthCheck(ctx context.Context, servers []string) error {
+	return checkServers(ctx, servers)
+}
+
+// mountFlags splits an option string into mount(2) flags and the
+// filesystem specific data, as mount(8) does. Options of the
+// mount.glusterfs helper are returned apart, they are not kernel data.
+func mountFlags(options string) (uintptr, string, []string) {
+	var flags uintptr
+	var data, ignored []string
+	for _, opt := range strings.Split(options, ",") {
+		key, _, _ := strings.Cut(opt, "=")
+		switch {
+		case opt == "":
+		case opt == "ro":
+			flags |= unix.MS_RDONLY
+		case opt == "rw":
+			flags &^= unix.MS_RDONLY
+		case Contains(helperOptions, key):
+			ignored = append(ignored, opt)
+		default:
+			data = append(data, opt)
+		}
+	}
+	return flags, strings.Join(data, ","), ignored
+}
@@ -0,0 +1,162 @@
+package backend
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+
+	"glusterfs-plugin/internal/errors"
+)
+
+// mountCall records the arguments of a mount(2) call.
+type mountCall struct {
+	source, target, fstype string
+	flags                  uintptr
+	data                   string
+}
+
+func TestGlusterfsNative_Mount(t *testing.T) {
+	var calls []mountCall
+	var unmounted []string
+	n := NewGlusterfsNative()
+	n.mount = func(source, target, fstype string, flags uintptr, data string) error {
+		calls = append(calls, mountCall{source, target, fstype, flags, data})
+		return nil
+	}
+	n.unmount = func(target string, flags int) error {
+		unmounted = append(unmounted, target)
+		return nil
+	}
+
+	req := &Request{Name: "vol1/sub", Options: map[string]string{"log-level": "warning"}, Servers: []string{"store1", "store2"}}
+	require.NoError(t, n.Validate(req))
+	args := n.MountArgs(req)
+	assert.Equal(t, []string{"store1:/vol1/sub", "backup-volfile-servers=store2,log-level=WARNING"}, args)
+
+	require.NoError(t, n.Mount(context.Background(), args, "/mnt/vol1", Observer{}))
+	// The kernel gets no options of the mount.glusterfs helper
+	assert.Equal(t, []mountCall{{
+		source: "store1:/vol1/sub",
+		target: "/mnt/vol1",
+		fstype: "glusterfs",
+	}}, calls)
+
+	require.NoError(t, n.Unmount(context.Background(), "/mnt/vol1"))
+	assert.Equal(t, []string{"/mnt/vol1"}, unmounted)
+}
+
+func TestGlusterfsNative_RejectsGlusteropts(t *testing.T) {
+	n := NewGlusterfsNative()
+	req := &Request{Name: "vol1", Options: map[string]string{"glusteropts": "-s store1"}}
+	assert.ErrorIs(t, n.Validate(req), errors.ErrValidation)
+	assert.Nil(t, n.MountArgs(req))
+	assert.Error(t, n.Mount(context.Background(), nil, "/mnt/vol1", Observer{}))
+}
+
+func TestGlusterfsNative_MountError(t *testing.T) {
+	n := NewGlusterfsNative()
+	n.mount = func(source, target, fstype string, flags uintptr, data string) error {
+		return unix.ENODEV
+	}
+	err := n.Mount(context.Background(), []string{"store1:/vol1", ""}, "/mnt/vol1", Observer{})
+	assert.ErrorIs(t, err, unix.ENODEV)
+}
+
+func TestGlusterfsNative_CancelDetachesLateMount(t *testing.T) {
+	release := make(chan struct{})
+	detached := make(chan int, 1)
+	n := NewGlusterfsNative()
+	n.mount = func(source, target, fstype string, flags uintptr, data string) error {
+		<-release
+		return nil
+	}
+	n.unmount = func(target string, flags int) error {
+		detached <- flags
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := n.Mount(ctx, []string{"store1:/vol1", ""}, "/mnt/vol1", Observer{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// The mount completes after the caller gave up and is detached
+	close(release)
+	select {
+	case flags := <-detached:
+		assert.Equal(t, unix.MNT_DETACH, flags)
+	case <-time.After(time.Second):
+		t.Fatal("late mount was not detached")
+	}
+}
+
+func TestGlusterfsNative_UnmountTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	n := NewGlusterfsNative()
+	n.unmount = func(target string, flags int) error {
+		<-release
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, n.Unmount(ctx, "/mnt/vol1"), context.DeadlineExceeded)
+}
+
+func TestGlusterfsNative_CheckSupport(t *testing.T) {
+	n := NewGlusterfsNative()
+	n.Filesystems = filepath.Join(t.TempDir(), "filesystems")
+
+	require.NoError(t, os.WriteFile(n.Filesystems, []byte("nodev\tproc\n\text4\nnodev\tfuse\n\tfuseblk\n"), 0644))
+	err := n.CheckSupport()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "use MOUNT_METHOD=fuse")
+
+	require.NoError(t, os.WriteFile(n.Filesystems, []byte("nodev\tproc\nnodev\tglusterfs\n"), 0644))
+	assert.NoError(t, n.CheckSupport())
+
+	n.Filesystems = filepath.Join(t.TempDir(), "missing")
+	assert.Error(t, n.CheckSupport())
+}
+
+func TestMountFlags(t *testing.T) {
+	tests := []struct {
+		options     string
+		wantFlags   uintptr
+		wantData    string
+		wantIgnored []string
+	}{
+		{options: "", wantFlags: 0, wantData: ""},
+		{options: "ro", wantFlags: unix.MS_RDONLY, wantData: ""},
+		{options: "ro,backup-volfile-servers=b:c,log-level=INFO", wantFlags: unix.MS_RDONLY, wantData: "", wantIgnored: []string{"backup-volfile-servers=b:c", "log-level=INFO"}},
+		{options: "ro,rw,log-level=INFO", wantFlags: 0, wantData: "", wantIgnored: []string{"log-level=INFO"}},
+		{options: "rw,volfile-id=vol1,logger=syslog,direct-io-mode=disable", wantFlags: 0, wantData: "direct-io-mode=disable", wantIgnored: []string{"volfile-id=vol1", "logger=syslog"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.options, func(t *testing.T) {
+			flags, data, ignored := mountFlags(tt.options)
+			assert.Equal(t, tt.wantFlags, flags)
+			assert.Equal(t, tt.wantData, data)
+			assert.Equal(t, tt.wantIgnored, ignored)
+		})
+	}
+}
+
+func TestGlusterfsNative_ExplainArgs(t *testing.T) {
+	n := NewGlusterfsNative()
+	req := &Request{Name: "vol1/data", Options: map[string]string{"ro": "true"}, Servers: []string{"store1", "store2"}}
+
+	explained := n.ExplainArgs(req)
+	assert.Equal(t, n.MountArgs(req), Values(explained))
+	assert.Contains(t, explained[0].Reason, "first server from SERVERS")
+	assert.Equal(t, "mount options: ro from driver_opts.ro=true, backup-volfile-servers from the other servers", explained[1].Reason)
+	assert.Nil(t, n.ExplainArgs(&Request{Name: "vol1", Options: map[string]string{}}))
+}
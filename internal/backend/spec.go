@@ -0,0 +1,178 @@
+package backend
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"glusterfs-plugin/internal/errors"
+)
+
+// Spec is the option model of a GlusterFS mount.
+// Both mount paths are built from it: the glusterfs FUSE client takes it
+// as command line arguments, mount(2) as a source and an option string in
+// the format of mount -t glusterfs.
+type Spec struct {
+	// Servers are the volfile servers; the first one is used to fetch
+	// the volume file and the others are backups
+	Servers []string
+
+	// Volume is the GlusterFS volume name
+	Volume string
+
+	// Subdir is the subdirectory of the volume to mount, without leading
+	// slash and with normalized slashes
+	Subdir string
+
+	// LogLevel is the client log level, empty for the default
+	LogLevel string
+
+	// ReadOnly mounts the volume read-only
+	ReadOnly bool
+}
+
+// logLevels are the log levels accepted by the glusterfs client.
+var logLevels = []string{"CRITICAL", "ERROR", "WARNING", "INFO", "DEBUG", "TRACE", "NONE"}
+
+// NewSpec builds the mount option model of a volume.
+// Servers configured for the plugin take precedence over driver_opts.servers.
+// The volume and subdirectory come from driver_opts.subdir or the volume
+// name, see VolumePath.
+//
+// Parameters:
+// - req: The volume to mount
+//
+// Returns:
+//   - The mount spec
+//   - ValidationError if the volume cannot be described by the model,
+//     such as volumes using driver_opts.glusteropts
+func NewSpec(req *Request) (*Spec, error) {
+	if _, ok := req.Options["glusteropts"]; ok {
+		return nil, errors.NewValidationError("glusteropts cannot be converted to mount options")
+	}
+
+	readOnly, err := ReadOnly(req.Options)
+	if err != nil {
+		return nil, err
+	}
+	spec := &Spec{
+		LogLevel: strings.ToUpper(req.Options["log-level"]),
+		ReadOnly: readOnly,
+	}
+
+	if len(req.Servers) > 0 {
+		spec.Servers = append(spec.Servers, req.Servers...)
+	} else {
+		spec.Servers = SplitList(req.Options["servers"])
+	}
+	if len(spec.Servers) == 0 {
+		return nil, errors.NewValidationError("no servers to mount from")
+	}
+
+	spec.Volume, spec.Subdir, err = VolumePath(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if spec.LogLevel != "" && !Contains(logLevels, spec.LogLevel) {
+		return nil, errors.NewValidationError(fmt.Sprintf(
+			"invalid log-level %q, must be one of %s", spec.LogLevel, strings.Join(logLevels, ", ")))
+	}
+
+	return spec, nil
+}
+
+// ClientArgs returns the glusterfs client arguments, without the mount point.
+func (s *Spec) ClientArgs() []string {
+	var args []string
+	for _, server := range s.Servers {
+		args = append(args, "-s", server)
+	}
+
+	name := s.Volume
+	if s.Subdir != "" {
+		name += "/" + s.Subdir
+	}
+	args = appendVolumeOptionsByVolumeName(args, name)
+
+	if s.LogLevel != "" {
+		args = append(args, "--log-level="+s.LogLevel)
+	}
+	if s.ReadOnly {
+		args = append(args, "--read-only")
+	}
+	return append(args, "--logger=syslog")
+}
+
+// Source returns the mount source in the "server:/volume[/subdir]" form.
+func (s *Spec) Source() string {
+	source := s.Servers[0] + ":/" + s.Volume
+	if s.Subdir != "" {
+		source += "/" + s.Subdir
+	}
+	return source
+}
+
+// OptionString returns the comma separated mount options, as passed to
+// mount -t glusterfs -o.
+func (s *Spec) OptionString() string {
+	var opts []string
+	if s.ReadOnly {
+		opts = append(opts, "ro")
+	}
+	if len(s.Servers) > 1 {
+		opts = append(opts, "backup-volfile-servers="+strings.Join(s.Servers[1:], ":"))
+	}
+	if s.LogLevel != "" {
+		opts = append(opts, "log-level="+s.LogLevel)
+	}
+	return strings.Join(opts, ",")
+}
+
+// ReadOnly reports whether driver_opts.ro requests a read-only mount.
+//
+// Parameters:
+// - options: The volume driver_opts
+//
+// Returns:
+// - true if the volume is read-only
+// - ValidationError if ro is not a boolean
+func ReadOnly(options map[string]string) (bool, error) {
+	value, ok := options["ro"]
+	if !ok {
+		return false, nil
+	}
+	readOnly, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, errors.NewValidationError(fmt.Sprintf("invalid ro %q, must be true or false", value))
+	}
+	return readOnly, nil
+}
+
+// SplitList splits a comma separated list, such as a server list,
+// trimming the items and dropping empty ones.
+//
+// Parameters:
+// - value: The comma separated list
+//
+// Returns:
+// - The items, nil if there are none
+func SplitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Contains reports whether values contains value.
+func Contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
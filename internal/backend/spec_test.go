@@ -0,0 +1,105 @@
+package backend
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"glusterfs-plugin/internal/errors"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// assertGolden compares got with testdata/<name>, rewriting it with -update.
+func assertGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(got), 0644))
+	}
+	want, err := os.ReadFile(path)
+	require.NoError(t, err, "run go test -update to create golden files")
+	assert.Equal(t, string(want), got)
+}
+
+func TestSpec_Golden(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *Request
+	}{
+		{
+			name: "plugin_servers",
+			req:  &Request{Name: "vol1", Options: map[string]string{}, Servers: []string{"store1", "store2", "store3"}},
+		},
+		{
+			name: "option_servers_subdir",
+			req:  &Request{Name: "vol1/apps/web", Options: map[string]string{"servers": "store1, store2"}},
+		},
+		{
+			name: "single_server_log_level",
+			req:  &Request{Name: "vol1", Options: map[string]string{"servers": "store1", "log-level": "debug"}},
+		},
+		{
+			name: "subdir_alias",
+			req:  &Request{Name: "web-data", Options: map[string]string{"subdir": "vol1//apps/web/"}, Servers: []string{"store1"}},
+		},
+		{
+			name: "read_only",
+			req:  &Request{Name: "vol1/data", Options: map[string]string{"ro": "true"}, Servers: []string{"store1", "store2"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := NewSpec(tt.req)
+			require.NoError(t, err)
+
+			// The FUSE client and mount(2) render the same spec
+			assertGolden(t, filepath.Join("spec", tt.name+".argv"), strings.Join(spec.ClientArgs(), "\n")+"\n")
+			assertGolden(t, filepath.Join("spec", tt.name+".opts"), spec.Source()+" "+spec.OptionString()+"\n")
+
+			// Explanations cover every argument, in order
+			explained := NewGlusterfs().ExplainArgs(tt.req)
+			assert.Equal(t, spec.ClientArgs(), Values(explained))
+			var lines []string
+			for _, arg := range explained {
+				lines = append(lines, arg.Value+"\t"+arg.Reason)
+			}
+			assertGolden(t, filepath.Join("spec", tt.name+".explain"), strings.Join(lines, "\n")+"\n")
+		})
+	}
+}
+
+func TestNewSpec_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *Request
+	}{
+		{name: "glusteropts", req: &Request{Name: "vol1", Options: map[string]string{"glusteropts": "-s a"}}},
+		{name: "no servers", req: &Request{Name: "vol1", Options: map[string]string{}}},
+		{name: "empty server list", req: &Request{Name: "vol1", Options: map[string]string{"servers": " , "}}},
+		{name: "empty volume", req: &Request{Name: "/sub", Options: map[string]string{"servers": "a"}}},
+		{name: "parent directory", req: &Request{Name: "vol1/../etc", Options: map[string]string{"servers": "a"}}},
+		{name: "invalid volume name", req: &Request{Name: "vol:1", Options: map[string]string{"servers": "a"}}},
+		{name: "invalid ro", req: &Request{Name: "vol1", Options: map[string]string{"servers": "a", "ro": "yes please"}}},
+		{name: "unknown log level", req: &Request{Name: "vol1", Options: map[string]string{"servers": "a", "log-level": "loud"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSpec(tt.req)
+			assert.ErrorIs(t, err, errors.ErrValidation)
+		})
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	assert.Equal(t, []string{"store1:/bricks", "store2:/bricks"}, SplitList(" store1:/bricks, ,store2:/bricks "))
+	assert.Nil(t, SplitList(""))
+}
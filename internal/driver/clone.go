@@ -0,0 +1,192 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"glusterfs-plugin/internal/backend"
+	"glusterfs-plugin/internal/errors"
+	"glusterfs-plugin/internal/management"
+	"glusterfs-plugin/pkg/volume"
+)
+
+const (
+	// fromSnapshotOption clones the GlusterFS volume from a snapshot.
+	fromSnapshotOption = "from-snapshot"
+
+	// cloneOfOption clones the GlusterFS volume from a fresh snapshot of
+	// another volume.
+	cloneOfOption = "clone-of"
+)
+
+// lineage records where a cloned volume comes from.
+type lineage struct {
+	// parent is the GlusterFS volume the snapshot was taken of
+	parent string
+
+	// snapshot is the snapshot the volume was cloned from
+	snapshot string
+
+	// created is when the snapshot was taken
+	created time.Time
+}
+
+// status renders the lineage for the volume status.
+func (l *lineage) status() map[string]interface{} {
+	return map[string]interface{}{
+		"parent":   l.parent,
+		"snapshot": l.snapshot,
+		"time":     l.created.UTC().Format(time.RFC3339),
+	}
+}
+
+// parseClone reads the from-snapshot and clone-of options of a volume.
+//
+// Parameters:
+// - req: The volume described to its backend
+//
+// Returns:
+//   - The spec of the volume to clone, nil if neither option is set
+//   - ValidationError if both options are set, they name an invalid
+//     snapshot or volume, they are combined with provision or
+//     glusteropts, or the volume is not a whole GlusterFS volume
+func parseClone(req *backend.Request) (*backend.Spec, error) {
+	snapshot, fromSnapshot := req.Options[fromSnapshotOption]
+	source, cloneOf := req.Options[cloneOfOption]
+	if !fromSnapshot && !cloneOf {
+		return nil, nil
+	}
+
+	switch {
+	case fromSnapshot && cloneOf:
+		return nil, errors.NewValidationError("from-snapshot and clone-of cannot be combined")
+	case fromSnapshot && snapshot == "":
+		return nil, errors.NewValidationError("from-snapshot cannot be empty")
+	case cloneOf && source == "":
+		return nil, errors.NewValidationError("clone-of cannot be empty")
+	}
+	if _, ok := req.Options[provisionOption]; ok {
+		return nil, errors.NewValidationError("provision cannot be combined with from-snapshot or clone-of")
+	}
+	if _, ok := req.Options["glusteropts"]; ok {
+		return nil, errors.NewValidationError("glusteropts cannot be combined with from-snapshot or clone-of")
+	}
+
+	// Both names are passed to the gluster CLI
+	if fromSnapshot {
+		if err := backend.CheckSnapshotName(snapshot); err != nil {
+			return nil, err
+		}
+	}
+	if cloneOf {
+		if err := backend.CheckVolumeName(source); err != nil {
+			return nil, err
+		}
+	}
+
+	spec, err := backend.NewSpec(req)
+	if err != nil {
+		return nil, err
+	}
+	if spec.Subdir != "" {
+		return nil, errors.NewValidationError("clones are whole volumes, subdirectories are not allowed")
+	}
+	if cloneOf && source == spec.Volume {
+		return nil, errors.NewValidationError("a volume cannot be a clone of itself")
+	}
+	return spec, nil
+}
+
+// validateClone checks that a volume can be cloned.
+func (p *GFSDriver) validateClone(req *volume.CreateRequest) error {
+	spec, err := parseClone(p.backendRequest(req))
+	if err != nil || spec == nil {
+		return err
+	}
+	if p.Management == nil {
+		return errors.NewValidationError("from-snapshot and clone-of are not supported, no management client is configured")
+	}
+	return nil
+}
+
+// cloneVolume creates and starts the GlusterFS volume of a volume being
+// created from a snapshot. For clone-of the snapshot is taken first and
+// deleted again if the clone cannot be made. A clone that cannot be
+// started is deleted again. Each management operation is bounded by
+// ManagementTimeout.
+//
+// Returns:
+// - The lineage of the clone, nil if the volume is not a clone
+// - error if the snapshot or the clone cannot be created
+func (p *GFSDriver) cloneVolume(req *volume.CreateRequest) (*lineage, error) {
+	spec, err := parseClone(p.backendRequest(req))
+	if err != nil || spec == nil {
+		return nil, err
+	}
+	client := p.Management(spec.Servers)
+
+	name := req.Options[fromSnapshotOption]
+	if source := req.Options[cloneOfOption]; source != "" {
+		name = management.SnapshotName(source, time.Now())
+		if err := p.manage(func(ctx context.Context) error { return client.CreateSnapshot(ctx, source, name) }); err != nil {
+			return nil, fmt.Errorf("failed to snapshot volume %s for %s: %w", source, req.Name, err)
+		}
+	}
+
+	origin, err := p.cloneSnapshot(client, spec.Volume, name, req.Name)
+	if err != nil && req.Options[cloneOfOption] != "" {
+		if deleteErr := p.manage(func(ctx context.Context) error { return client.DeleteSnapshot(ctx, name) }); deleteErr != nil {
+			log.Printf("warning: failed to delete snapshot %s after cloning %s failed: %v", name, req.Name, deleteErr)
+		}
+	}
+	return origin, err
+}
+
+// cloneSnapshot activates a snapshot if needed, clones it into a volume
+// and starts the clone, deleting a clone that does not start.
+//
+// Parameters:
+// - client: Manages the cluster of the clone
+// - clone: The GlusterFS volume to create
+// - snapshot: The snapshot to clone
+// - name: The name of the Docker volume, for errors
+//
+// Returns:
+// - The lineage of the clone
+// - error if any step fails
+func (p *GFSDriver) cloneSnapshot(client management.Client, clone, snapshot, name string) (*lineage, error) {
+	var snap *management.Snapshot
+	err := p.manage(func(ctx context.Context) (err error) {
+		snap, err = client.Snapshot(ctx, snapshot)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to clone volume %s: %w", name, err)
+	}
+	if !snap.Active {
+		if err := p.manage(func(ctx context.Context) error { return client.ActivateSnapshot(ctx, snapshot) }); err != nil {
+			return nil, fmt.Errorf("failed to activate snapshot %s for %s: %w", snapshot, name, err)
+		}
+	}
+	if err := p.manage(func(ctx context.Context) error { return client.CloneSnapshot(ctx, clone, snapshot) }); err != nil {
+		return nil, fmt.Errorf("failed to clone volume %s: %w", name, err)
+	}
+	if err := p.manage(func(ctx context.Context) error { return client.StartVolume(ctx, clone) }); err != nil {
+		if deleteErr := p.manage(func(ctx context.Context) error { return client.DeleteVolume(ctx, clone) }); deleteErr != nil {
+			log.Printf("warning: failed to delete clone %s after it did not start: %v", clone, deleteErr)
+		}
+		return nil, fmt.Errorf("failed to start clone %s: %w", name, err)
+	}
+
+	log.Printf("cloned volume %s from snapshot %s of %s", clone, snapshot, snap.Volume)
+	return &lineage{parent: snap.Volume, snapshot: snapshot, created: snap.Created}, nil
+}
+
+// manage runs a management operation bounded by ManagementTimeout.
+func (p *GFSDriver) manage(op func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.ManagementTimeout)
+	defer cancel()
+	return op(ctx)
+}
@@ -0,0 +1,169 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"glusterfs-plugin/internal/errors"
+	"glusterfs-plugin/internal/management"
+	"glusterfs-plugin/internal/management/managementtest"
+	"glusterfs-plugin/pkg/volume"
+)
+
+// newCloningDriver returns a test driver with a fake cluster holding the
+// started volume "shared" and its active snapshot "nightly".
+func newCloningDriver(t *testing.T) (*GFSDriver, *managementtest.Fake) {
+	t.Helper()
+	d, _ := newTestDriver(t)
+	mgmt := managementtest.New()
+	mgmt.AddVolume(&management.Volume{Name: "shared", Status: "Started"})
+	mgmt.AddSnapshot(&management.Snapshot{
+		Name:    "nightly",
+		Volume:  "shared",
+		Created: time.Date(2026, 10, 18, 2, 0, 5, 0, time.UTC),
+		Active:  true,
+	})
+	d.Management = mgmt.Factory()
+	return d, mgmt
+}
+
+func TestValidate_Clone(t *testing.T) {
+	d, _ := newCloningDriver(t)
+
+	tests := []struct {
+		name    string
+		volume  string
+		options map[string]string
+		wantErr string
+	}{
+		{name: "from snapshot", volume: "fixture", options: map[string]string{"from-snapshot": "nightly"}},
+		{name: "clone of", volume: "fixture", options: map[string]string{"clone-of": "shared"}},
+		{name: "both", volume: "fixture", options: map[string]string{"from-snapshot": "nightly", "clone-of": "shared"}, wantErr: "cannot be combined"},
+		{name: "empty snapshot", volume: "fixture", options: map[string]string{"from-snapshot": ""}, wantErr: "cannot be empty"},
+		{name: "with provision", volume: "fixture", options: map[string]string{"clone-of": "shared", "provision": "true"}, wantErr: "provision cannot be combined"},
+		{name: "subdirectory", volume: "fixture/sub", options: map[string]string{"clone-of": "shared"}, wantErr: "subdirectories are not allowed"},
+		{name: "itself", volume: "shared", options: map[string]string{"clone-of": "shared"}, wantErr: "clone of itself"},
+		{name: "invalid snapshot", volume: "fixture", options: map[string]string{"from-snapshot": "--help"}, wantErr: "snapshot name --help cannot start with -"},
+		{name: "invalid source", volume: "fixture", options: map[string]string{"clone-of": "shared vol"}, wantErr: "can only contain letters"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := d.Validate(&volume.CreateRequest{Name: tt.volume, Options: tt.options})
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, errors.ErrValidation)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestCreate_FromSnapshot(t *testing.T) {
+	d, mgmt := newCloningDriver(t)
+
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "fixture", Options: map[string]string{"from-snapshot": "nightly"}}))
+	assert.Equal(t, []string{"snapshot-info nightly", "clone fixture nightly", "start fixture"}, mgmt.Calls())
+
+	v, err := d.Get("fixture")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"parent":   "shared",
+		"snapshot": "nightly",
+		"time":     "2026-10-18T02:00:05Z",
+	}, v.Status["lineage"])
+	assert.Equal(t, true, v.Status["provisioned"])
+
+	_, err = d.Mount(context.Background(), &volume.MountRequest{Name: "fixture"})
+	assert.NoError(t, err)
+}
+
+func TestCreate_CloneOf(t *testing.T) {
+	d, mgmt := newCloningDriver(t)
+
+	before := time.Now().UTC().Truncate(time.Second)
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "fixture", Options: map[string]string{"clone-of": "shared"}}))
+
+	calls := mgmt.Calls()
+	require.Len(t, calls, 5)
+	snapshot := calls[0][len("snapshot shared "):]
+	assert.Regexp(t, `^shared-\d{8}-\d{6}-[0-9a-f]{6}$`, snapshot)
+	assert.Equal(t, []string{
+		"snapshot-info " + snapshot,
+		"activate " + snapshot,
+		"clone fixture " + snapshot,
+		"start fixture",
+	}, calls[1:])
+
+	v, err := d.Get("fixture")
+	require.NoError(t, err)
+	lineage := v.Status["lineage"].(map[string]interface{})
+	assert.Equal(t, "shared", lineage["parent"])
+	assert.Equal(t, snapshot, lineage["snapshot"])
+	created, err := time.Parse(time.RFC3339, lineage["time"].(string))
+	require.NoError(t, err)
+	assert.False(t, created.Before(before))
+}
+
+func TestCreate_CloneFailures(t *testing.T) {
+	d, mgmt := newCloningDriver(t)
+
+	err := d.Create(&volume.CreateRequest{Name: "fixture", Options: map[string]string{"from-snapshot": "weekly"}})
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+	assert.ErrorContains(t, err, "failed to clone volume fixture")
+
+	err = d.Create(&volume.CreateRequest{Name: "fixture", Options: map[string]string{"clone-of": "media"}})
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+	assert.ErrorContains(t, err, "failed to snapshot volume media for fixture")
+
+	mgmt.StartErr = fmt.Errorf("brick store1:/bricks/fixture is not connected")
+	err = d.Create(&volume.CreateRequest{Name: "fixture", Options: map[string]string{"from-snapshot": "nightly"}})
+	assert.ErrorContains(t, err, "failed to start clone fixture")
+
+	// The clone that did not start is deleted and the volume not registered
+	_, err = mgmt.Volume(context.Background(), "fixture")
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+	_, err = d.Get("fixture")
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+}
+
+func TestCreate_CloneOfFailureDeletesSnapshot(t *testing.T) {
+	d, mgmt := newCloningDriver(t)
+	mgmt.StartErr = fmt.Errorf("brick store1:/bricks/fixture is not connected")
+
+	err := d.Create(&volume.CreateRequest{Name: "fixture", Options: map[string]string{"clone-of": "shared"}})
+	assert.ErrorContains(t, err, "failed to start clone fixture")
+
+	calls := mgmt.Calls()
+	snapshot := calls[0][len("snapshot shared "):]
+	assert.Equal(t, "delete-snapshot "+snapshot, calls[len(calls)-1])
+	_, err = mgmt.Snapshot(context.Background(), snapshot)
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+}
+
+func TestCreate_CloneOfTwiceInOneSecond(t *testing.T) {
+	d, _ := newCloningDriver(t)
+
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "fixture1", Options: map[string]string{"clone-of": "shared"}}))
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "fixture2", Options: map[string]string{"clone-of": "shared"}}))
+}
+
+func TestRemove_ReclaimsClone(t *testing.T) {
+	d, mgmt := newCloningDriver(t)
+	d.Provision = &ProvisionProfile{Bricks: []string{"store1:/bricks"}, Reclaim: ReclaimDelete}
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "fixture", Options: map[string]string{"from-snapshot": "nightly"}}))
+
+	require.NoError(t, d.Remove("fixture"))
+	_, err := mgmt.Volume(context.Background(), "fixture")
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+
+	// The parent is untouched
+	_, err = mgmt.Volume(context.Background(), "shared")
+	assert.NoError(t, err)
+}
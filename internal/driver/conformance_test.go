@@ -0,0 +1,43 @@
+package driver
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"glusterfs-plugin/internal/backend"
+	"glusterfs-plugin/internal/backend/backendtest"
+	"glusterfs-plugin/internal/backend/glusterfstest"
+	"glusterfs-plugin/internal/utils"
+	"glusterfs-plugin/pkg/volume/volumetest"
+)
+
+func TestConformance_Fake(t *testing.T) {
+	volumetest.Run(t, volumetest.Plugin{
+		New: func(t *testing.T) http.Handler {
+			d := NewDriver([]string{"server1"})
+			d.Root = filepath.Join(t.TempDir(), "volumes")
+			d.RegisterBackend(backendtest.New(backend.GlusterfsType))
+			return utils.NewHandler(d)
+		},
+		Options:     map[string]string{},
+		Conflicting: map[string]string{"ro": "true"},
+	})
+}
+
+func TestConformance_Glusterfs(t *testing.T) {
+	volumetest.Run(t, volumetest.Plugin{
+		New: func(t *testing.T) http.Handler {
+			bins := glusterfstest.Install(t)
+			d := NewDriver([]string{"store1", "store2"})
+			d.Root = filepath.Join(t.TempDir(), "volumes")
+			g := backend.NewGlusterfs()
+			g.Binary = bins.Path(glusterfstest.Glusterfs)
+			g.UmountBinary = bins.Path(glusterfstest.Umount)
+			d.RegisterBackend(g)
+			return utils.NewHandler(d)
+		},
+		Options:     map[string]string{"ro": "true"},
+		Conflicting: map[string]string{"ro": "false"},
+	})
+}
@@ -0,0 +1,155 @@
+package driver
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"glusterfs-plugin/internal/backend"
+	"glusterfs-plugin/internal/errors"
+	"glusterfs-plugin/pkg/volume"
+)
+
+// discoveredStatus marks volumes found on the cluster but never created
+// through the plugin.
+const discoveredStatus = "discovered"
+
+// discoverVolumes returns the volumes of the cluster that are not
+// registered, marked as discovered. Discovery failures are logged and
+// yield no volumes, so that listing never fails because of the cluster.
+//
+// Parameters:
+// - registered: The names of the registered volumes
+//
+// Returns:
+// - The discovered volumes, nil if discovery is disabled
+func (p *GFSDriver) discoverVolumes(registered map[string]bool) []*volume.Volume {
+	servers := p.servers()
+	if !p.Discover || p.Management == nil || len(servers) == 0 {
+		return nil
+	}
+
+	names, err := p.discoveredNames(servers)
+	if err != nil {
+		log.Printf("warning: failed to discover volumes: %v", err)
+		return nil
+	}
+
+	var volumes []*volume.Volume
+	for _, name := range names {
+		if registered[name] {
+			continue
+		}
+		volumes = append(volumes, &volume.Volume{
+			Name:   name,
+			Status: map[string]interface{}{discoveredStatus: true},
+		})
+	}
+	return volumes
+}
+
+// getDiscovered returns a volume of the cluster that is not registered.
+//
+// Parameters:
+// - name: The name of the volume
+//
+// Returns:
+// - The volume, with its cluster status and type
+// - NotFoundError if discovery is disabled or the volume does not exist
+// - TimeoutError if the cluster does not answer within StatusTimeout
+// - ServerUnreachableError if the cluster cannot be read
+func (p *GFSDriver) getDiscovered(name string) (*volume.Volume, error) {
+	notFound := errors.NewNotFoundError(fmt.Sprintf("volume %s does not exist", name), nil)
+	servers := p.servers()
+	if !p.Discover || p.Management == nil || len(servers) == 0 {
+		return nil, notFound
+	}
+
+	// Unknown names are answered from the cached list, Docker asks for
+	// every volume name it sees
+	names, err := p.discoveredNames(servers)
+	if err != nil {
+		return nil, lookupError(name, err)
+	}
+	if !backend.Contains(names, name) {
+		return nil, notFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.StatusTimeout)
+	defer cancel()
+
+	v, err := p.Management(servers).Volume(ctx, name)
+	if err != nil {
+		if stderrors.Is(err, errors.ErrNotFound) {
+			return nil, notFound
+		}
+		return nil, lookupError(name, err)
+	}
+
+	return &volume.Volume{
+		Name: name,
+		Status: map[string]interface{}{
+			discoveredStatus: true,
+			"clusterStatus":  v.Status,
+			"type":           v.Type,
+		},
+	}, nil
+}
+
+// discoveredResult is a volume list read from the cluster.
+type discoveredResult struct {
+	names []string
+	err   error
+}
+
+// discoveredNames returns the names of the volumes of the cluster.
+// Docker lists volumes at daemon start and looks up every volume name it
+// sees, so the list is read within StatusTimeout and reused for
+// statusCacheTTL, failures included.
+//
+// Parameters:
+// - servers: The servers to ask
+//
+// Returns:
+// - The volume names
+// - error if the cluster cannot be listed
+func (p *GFSDriver) discoveredNames(servers []string) ([]string, error) {
+	key := discoverStatusKey(servers)
+	if cached, ok := p.status.Get(key); ok {
+		result := cached.(discoveredResult)
+		return result.names, result.err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.StatusTimeout)
+	defer cancel()
+	var result discoveredResult
+	result.names, result.err = p.Management(servers).Volumes(ctx)
+	p.status.Set(key, result)
+	return result.names, result.err
+}
+
+// discoverStatusKey is the status cache key of the volume list of a
+// cluster.
+func discoverStatusKey(servers []string) string {
+	return "discover/" + strings.Join(servers, ",")
+}
+
+// lookupError reports a failure to look up a volume on the cluster.
+func lookupError(name string, err error) error {
+	message := fmt.Sprintf("failed to look up volume %s on the cluster", name)
+	if stderrors.Is(err, context.DeadlineExceeded) {
+		return errors.NewTimeoutError(message, err)
+	}
+	return errors.NewServerUnreachableError(message, err)
+}
+
+// discoveredMountError explains why a discovered volume is not mounted.
+func (p *GFSDriver) discoveredMountError(name string) error {
+	if _, err := p.getDiscovered(name); err != nil {
+		return errors.NewNotFoundError(fmt.Sprintf("volume %s does not exist", name), nil)
+	}
+	return errors.NewNotFoundError(fmt.Sprintf(
+		"volume %s was discovered on the cluster and is not mounted automatically, create it with docker volume create first", name), nil)
+}
@@ -0,0 +1,140 @@
+package driver
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"glusterfs-plugin/internal/errors"
+	"glusterfs-plugin/internal/management"
+	"glusterfs-plugin/internal/management/managementtest"
+	"glusterfs-plugin/pkg/volume"
+)
+
+// newDiscoveringDriver returns a test driver discovering the volumes
+// "media" and "shared" on a fake cluster.
+func newDiscoveringDriver(t *testing.T) (*GFSDriver, *managementtest.Fake) {
+	t.Helper()
+	d, _ := newTestDriver(t)
+	mgmt := managementtest.New()
+	mgmt.AddVolume(&management.Volume{Name: "media", Status: "Started", Type: "Replicate"})
+	mgmt.AddVolume(&management.Volume{Name: "shared", Status: "Started", Type: "Distribute"})
+	d.Management = mgmt.Factory()
+	d.Discover = true
+	return d, mgmt
+}
+
+func TestList_Discover(t *testing.T) {
+	d, _ := newDiscoveringDriver(t)
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "shared", Options: map[string]string{}}))
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "shared/team-a", Options: map[string]string{}}))
+
+	volumes, err := d.List()
+	require.NoError(t, err)
+	assert.Equal(t, []*volume.Volume{
+		{Name: "media", Status: map[string]interface{}{"discovered": true}},
+		{Name: "shared"},
+		{Name: "shared/team-a"},
+	}, volumes)
+}
+
+func TestList_DiscoverDisabled(t *testing.T) {
+	d, mgmt := newDiscoveringDriver(t)
+	d.Discover = false
+
+	volumes, err := d.List()
+	require.NoError(t, err)
+	assert.Empty(t, volumes)
+	assert.Empty(t, mgmt.Calls())
+}
+
+func TestList_DiscoverFailure(t *testing.T) {
+	d, mgmt := newDiscoveringDriver(t)
+	mgmt.VolumeErr = errors.NewServerUnreachableError("glusterd on server1", nil)
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{}}))
+
+	// The registered volumes are still listed
+	volumes, err := d.List()
+	require.NoError(t, err)
+	assert.Equal(t, []*volume.Volume{{Name: "vol1"}}, volumes)
+}
+
+func TestGet_Discovered(t *testing.T) {
+	d, _ := newDiscoveringDriver(t)
+
+	v, err := d.Get("media")
+	require.NoError(t, err)
+	assert.Equal(t, &volume.Volume{
+		Name: "media",
+		Status: map[string]interface{}{
+			"discovered":    true,
+			"clusterStatus": "Started",
+			"type":          "Replicate",
+		},
+	}, v)
+
+	_, err = d.Get("unknown")
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+}
+
+func TestMount_DiscoveredIsNotMounted(t *testing.T) {
+	d, _ := newDiscoveringDriver(t)
+
+	_, err := d.Mount(context.Background(), &volume.MountRequest{Name: "media"})
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+	assert.ErrorContains(t, err, "not mounted automatically")
+
+	_, err = d.Mount(context.Background(), &volume.MountRequest{Name: "unknown"})
+	assert.EqualError(t, err, "not found error: volume unknown does not exist")
+
+	// Once created, the volume mounts like any other
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "media", Options: map[string]string{}}))
+	_, err = d.Mount(context.Background(), &volume.MountRequest{Name: "media"})
+	assert.NoError(t, err)
+}
+
+func TestGet_DiscoverCached(t *testing.T) {
+	d, mgmt := newDiscoveringDriver(t)
+
+	_, err := d.List()
+	require.NoError(t, err)
+	_, err = d.Get("unknown")
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+	_, err = d.Get("other")
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+	_, err = d.List()
+	require.NoError(t, err)
+
+	// The cluster is listed once, unknown names are not looked up
+	assert.Equal(t, []string{"volumes"}, mgmt.Calls())
+}
+
+func TestList_DiscoverTimeout(t *testing.T) {
+	d, mgmt := newDiscoveringDriver(t)
+	d.ManagementTimeout = time.Minute
+	d.StatusTimeout = 20 * time.Millisecond
+	mgmt.Delay = time.Minute
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{}}))
+
+	start := time.Now()
+	volumes, err := d.List()
+	require.NoError(t, err)
+	assert.Equal(t, []*volume.Volume{{Name: "vol1"}}, volumes)
+	_, err = d.Get("unknown")
+	assert.ErrorIs(t, err, errors.ErrTimeout)
+	assert.ErrorContains(t, err, "failed to look up volume unknown")
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestGet_DiscoverUnreachable(t *testing.T) {
+	d, mgmt := newDiscoveringDriver(t)
+	mgmt.VolumeErr = stderrors.New("connection refused")
+
+	_, err := d.Get("media")
+	assert.ErrorIs(t, err, errors.ErrServerUnreachable)
+	assert.Equal(t, errors.CodeServerUnreachable, errors.CodeOf(err))
+}
@@ -7,18 +7,86 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"sort"
 	"strings"
+	"sync"
+	"time"
+
+	"glusterfs-plugin/internal/backend"
 	"glusterfs-plugin/internal/errors"
+	"glusterfs-plugin/internal/management"
+	"glusterfs-plugin/internal/utils"
 	"glusterfs-plugin/pkg/types"
 	"glusterfs-plugin/pkg/volume"
 )
 
+// shareOption names the volume whose mount a volume shares read-only.
+const shareOption = "share"
+
 // GFSDriver implements the Driver interface for GlusterFS volumes.
 // It wraps the base GFSDriver from pkg/types and adds Docker-specific functionality.
 type GFSDriver struct {
 	*types.GFSDriver
+
+	// Root is the directory under which volumes are mounted.
+	Root string
+
+	// Mounts records the active mounts so that glusterfs client log
+	// messages can be attributed to their volume.
+	Mounts *utils.MountTable
+
+	// Logs keeps the recent glusterfs client log lines of each volume.
+	Logs *utils.ClientLogs
+
+	// MountTimeout bounds how long a single mount may take.
+	MountTimeout time.Duration
+
+	// UnmountTimeout bounds how long a single unmount may take.
+	UnmountTimeout time.Duration
+
+	// Management creates the clients used to manage quotas on the cluster.
+	Management management.Factory
+
+	// ManagementTimeout bounds a single management operation.
+	ManagementTimeout time.Duration
+
+	// StatusTimeout bounds the cluster queries made to report the status
+	// of volumes in Get and List, which Docker calls on hot paths.
+	StatusTimeout time.Duration
+
+	// Discover lists the volumes of the cluster reachable through
+	// Servers next to the registered volumes.
+	Discover bool
+
+	// Provision describes the GlusterFS volumes created for volumes with
+	// driver_opts.provision=true; provisioning is disabled if nil.
+	Provision *ProvisionProfile
+
+	// locks serializes operations on the same volume
+	locks *utils.KeyedMutex
+
+	// status caches the cluster queries of Get and List for
+	// statusCacheTTL
+	status *utils.TTLCache
+
+	// serversMu guards Servers, which SetServers replaces while requests
+	// are served
+	serversMu sync.RWMutex
+
+	mu      sync.Mutex
+	volumes map[string]*volumeState
+
+	// backends holds the filesystem backends volumes can select
+	backends backend.Registry
+
+	// bindMount and unbind manage the bind mounts of shared volumes
+	bindMount func(source, target string, readOnly bool) error
+	unbind    func(target string) error
 }
 
+// GFSDriver must implement the complete volume lifecycle
+var _ volume.Driver = (*GFSDriver)(nil)
+
 // NewDriver creates a new instance of the GlusterFS driver.
 // It initializes the driver with the provided list of GlusterFS servers.
 //
@@ -30,17 +98,164 @@ type GFSDriver struct {
 func NewDriver(servers []string) *GFSDriver {
 	return &GFSDriver{
 		GFSDriver: types.NewGFSDriver(servers),
+		Root:      DefaultRoot,
+		Mounts:    utils.NewMountTable(),
+		Logs:      utils.NewClientLogs(utils.DefaultLogBufferLines),
+		locks:     utils.NewKeyedMutex(),
+		status:    utils.NewTTLCache(statusCacheTTL),
+		volumes:   make(map[string]*volumeState),
+
+		MountTimeout:      DefaultMountTimeout,
+		UnmountTimeout:    DefaultUnmountTimeout,
+		ManagementTimeout: DefaultManagementTimeout,
+		StatusTimeout:     DefaultStatusTimeout,
+
+		Management: func(servers []string) management.Client { return management.NewCLI(servers) },
+
+		backends: backend.Registry{backend.GlusterfsType: backend.NewGlusterfs()},
+
+		bindMount: backend.BindMount,
+		unbind:    backend.Unbind,
+	}
+}
+
+// RegisterBackend makes a backend available to volumes created with
+// driver_opts.type set to its type.
+//
+// Parameters:
+// - b: The backend to register
+func (p *GFSDriver) RegisterBackend(b backend.Backend) {
+	p.backends.Register(b)
+}
+
+// servers returns the servers configured for the plugin. The slice is
+// replaced, never modified, so callers may keep it.
+func (p *GFSDriver) servers() []string {
+	p.serversMu.RLock()
+	defer p.serversMu.RUnlock()
+	return p.Servers
+}
+
+// SetServers replaces the servers configured for the plugin. Volumes
+// mounted afterwards use the new servers, existing mounts keep theirs
+// until the containers using them release them. Volumes naming
+// their own servers are not affected. The servers are checked and
+// replaced under one lock, so no other reload slips in between.
+//
+// Parameters:
+// - servers: The new servers, empty if volumes must name their own
+//
+// Returns:
+// - The previous servers
+// - ValidationError if registered volumes would have no servers left
+func (p *GFSDriver) SetServers(servers []string) ([]string, error) {
+	servers = append([]string(nil), servers...)
+
+	p.serversMu.Lock()
+	if err := p.checkServers(servers); err != nil {
+		p.serversMu.Unlock()
+		return nil, err
+	}
+	previous := p.Servers
+	p.Servers = servers
+	p.serversMu.Unlock()
+
+	if stale := p.StaleMounts(); len(stale) > 0 {
+		names := make([]string, len(stale))
+		for i, m := range stale {
+			names[i] = m.Name
+		}
+		log.Printf("warning: volumes %v are mounted from the previous servers %v until the containers using them are restarted", names, previous)
 	}
+	return previous, nil
+}
+
+// checkServers checks that the registered volumes can still be mounted
+// with other servers configured for the plugin: SERVERS cannot become
+// empty while volumes take their servers from it. The caller holds
+// p.serversMu.
+func (p *GFSDriver) checkServers(servers []string) error {
+	if len(servers) > 0 {
+		return nil
+	}
+
+	p.mu.Lock()
+	var names []string
+	for name, state := range p.volumes {
+		if _, shared := state.request.Options[shareOption]; shared {
+			continue
+		}
+		if usesPluginServers(state.request.Options) {
+			names = append(names, name)
+		}
+	}
+	p.mu.Unlock()
+
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+	return errors.NewValidationError(fmt.Sprintf(
+		"SERVERS cannot be emptied, volumes %s are mounted from it; remove them first",
+		strings.Join(names, ", ")))
+}
+
+// backendFor returns the backend selected by the volume options.
+// Volumes without driver_opts.type use GlusterFS.
+func (p *GFSDriver) backendFor(options map[string]string) (backend.Backend, error) {
+	typ := options[backend.TypeOption]
+	if typ == "" {
+		typ = backend.GlusterfsType
+	}
+	b, ok := p.backends[typ]
+	if !ok {
+		return nil, errors.NewValidationError(fmt.Sprintf(
+			"unsupported type %q, must be one of %s", typ, strings.Join(p.backends.Types(), ", ")))
+	}
+	return b, nil
+}
+
+// validationRule is a rule a create request must satisfy.
+type validationRule struct {
+	// name identifies the rule in explanations
+	name string
+
+	// description states what the rule requires
+	description string
+
+	check func(p *GFSDriver, req *volume.CreateRequest) error
+}
+
+// createRules are the rules Validate applies to volumes, in order.
+var createRules = []validationRule{
+	{"name", "the volume name is required; it, or subdir if set, must be a GlusterFS volume name optionally followed by a subdirectory without .. segments; subdir excludes glusteropts", (*GFSDriver).validateName},
+	{"ownership", "uid, gid and mode must be valid and cannot be combined with ro=true", (*GFSDriver).validateOwnership},
+	{"servers-exclusive", "if SERVERS is set, servers and glusteropts options are not allowed, except for volumes registered with them", (*GFSDriver).validateServersExclusive},
+	{"servers-glusteropts", "if servers is set in options, glusteropts are not allowed", (*GFSDriver).validateServersGlusteropts},
+	{"servers-required", "at least one of SERVERS, servers or glusteropts must be specified", (*GFSDriver).validateServersRequired},
+	{"type", "the type option, if set, must name a registered backend", (*GFSDriver).validateType},
+	{"backend", "the backend must accept the volume options", (*GFSDriver).validateBackend},
+	{"clone", "from-snapshot and clone-of exclude each other and provision, and require a whole volume", (*GFSDriver).validateClone},
+	{"provision", "provision requires a provisioning profile and a whole volume", (*GFSDriver).validateProvision},
+	{"quota", "size must be a valid size and requires a subdirectory volume, strict requires size", (*GFSDriver).validateQuota},
+}
+
+// shareRules are the rules Validate applies to volumes sharing the mount
+// of another volume through a read-only bind mount, in order.
+var shareRules = []validationRule{
+	{"share-name", "the volume name cannot be empty or contain . or .. segments", (*GFSDriver).validateShareName},
+	{"share-ro", "ro must be true, sharing is only useful for read-only consumers", (*GFSDriver).validateShareReadOnly},
+	{"share-options", "servers, glusteropts, subdir, type, uid, gid, mode, size, strict, provision, from-snapshot and clone-of are not allowed, the shared volume defines them", (*GFSDriver).validateShareOptions},
+	{"share-source", "the shared volume must exist and must not share a volume itself", (*GFSDriver).validateShareSource},
 }
 
 // Validate validates the create request.
 // It ensures that the request is valid and that the server configuration
 // is consistent with the provided options.
 //
-// The validation rules are:
-// 1. If SERVERS is set, no options are allowed
-// 2. If servers is set in options, glusteropts are not allowed
-// 3. At least one of SERVERS, servers, or glusteropts must be specified
+// The rules of createRules are applied in order, or those of shareRules
+// for volumes sharing another volume (driver_opts.share); the first rule
+// that fails rejects the request.
 //
 // Parameters:
 // - req: The create request to validate
@@ -51,28 +266,176 @@ func (p *GFSDriver) Validate(req *volume.CreateRequest) error {
 	if req == nil {
 		return errors.NewValidationError("create request cannot be nil")
 	}
+	_, err := p.validate(req)
+	return err
+}
 
-	_, serversDefinedInOpts := req.Options["servers"]
-	_, glusteroptsInOpts := req.Options["glusteropts"]
+// validate applies the validation rules of a request.
+//
+// Returns:
+// - The rule that failed, nil if the request is valid
+// - error of the failed rule
+func (p *GFSDriver) validate(req *volume.CreateRequest) (*validationRule, error) {
+	rules := createRules
+	if _, ok := req.Options[shareOption]; ok {
+		rules = shareRules
+	}
+	for i := range rules {
+		if err := rules[i].check(p, req); err != nil {
+			return &rules[i], err
+		}
+	}
+	return nil, nil
+}
+
+func (p *GFSDriver) validateName(req *volume.CreateRequest) error {
+	// Without a name the volume has no mount point of its own, whatever
+	// subdir or glusteropts mount
+	if req.Name == "" {
+		return errors.NewValidationError("volume name cannot be empty")
+	}
+	_, subdir := req.Options[backend.SubdirOption]
+	if _, ok := req.Options["glusteropts"]; ok {
+		if subdir {
+			return errors.NewValidationError("glusteropts is set, subdir is not allowed")
+		}
+		// glusteropts name the GlusterFS volume, the volume name is free
+		return checkNameSegments(req.Name)
+	}
+	_, _, err := backend.VolumePath(p.backendRequest(req))
+	return err
+}
+
+func (p *GFSDriver) validateShareName(req *volume.CreateRequest) error {
+	return checkNameSegments(req.Name)
+}
 
-	if len(p.Servers) > 0 && (serversDefinedInOpts || glusteroptsInOpts) {
-		return errors.NewValidationError("SERVERS is set, options are not allowed")
+// checkNameSegments rejects empty volume names and names with . or ..
+// segments, which would resolve to other paths below the mount root.
+func checkNameSegments(name string) error {
+	if name == "" {
+		return errors.NewValidationError("volume name cannot be empty")
 	}
+	for _, segment := range strings.Split(name, "/") {
+		if segment == "." || segment == ".." {
+			return errors.NewValidationError(fmt.Sprintf("volume name %s cannot contain %s", name, segment))
+		}
+	}
+	return nil
+}
+
+func (p *GFSDriver) validateOwnership(req *volume.CreateRequest) error {
+	owner, err := parseOwnership(req.Options)
+	if err != nil {
+		return err
+	}
+	if readOnly, _ := backend.ReadOnly(req.Options); readOnly && owner != nil {
+		return errors.NewValidationError("uid, gid and mode cannot be applied to a read-only volume")
+	}
+	return nil
+}
+
+// validateServersExclusive refuses servers in the options while SERVERS
+// is set. Volumes registered with their own servers before SERVERS was
+// set keep them, so that a redeploy can create them again.
+func (p *GFSDriver) validateServersExclusive(req *volume.CreateRequest) error {
+	if len(p.servers()) == 0 || usesPluginServers(req.Options) {
+		return nil
+	}
+
+	p.mu.Lock()
+	state, registered := p.volumes[req.Name]
+	p.mu.Unlock()
+	if registered && !usesPluginServers(state.request.Options) {
+		return nil
+	}
+	return errors.NewValidationError("SERVERS is set, options are not allowed")
+}
+
+func (p *GFSDriver) validateServersGlusteropts(req *volume.CreateRequest) error {
+	_, serversDefinedInOpts := req.Options["servers"]
+	_, glusteroptsInOpts := req.Options["glusteropts"]
 	if serversDefinedInOpts && glusteroptsInOpts {
 		return errors.NewValidationError("servers is set, glusteropts are not allowed")
 	}
-	if len(p.Servers) == 0 && !serversDefinedInOpts && !glusteroptsInOpts {
+	return nil
+}
+
+func (p *GFSDriver) validateServersRequired(req *volume.CreateRequest) error {
+	_, serversDefinedInOpts := req.Options["servers"]
+	_, glusteroptsInOpts := req.Options["glusteropts"]
+	if len(p.servers()) == 0 && !serversDefinedInOpts && !glusteroptsInOpts {
 		return errors.NewValidationError("One of SERVERS, driver_opts.servers or driver_opts.glusteropts must be specified")
 	}
+	return nil
+}
+
+func (p *GFSDriver) validateType(req *volume.CreateRequest) error {
+	_, err := p.backendFor(req.Options)
+	return err
+}
+
+func (p *GFSDriver) validateBackend(req *volume.CreateRequest) error {
+	b, err := p.backendFor(req.Options)
+	if err != nil {
+		return err
+	}
+	return b.Validate(p.backendRequest(req))
+}
 
+func (p *GFSDriver) validateQuota(req *volume.CreateRequest) error {
+	q, err := parseQuota(p.backendRequest(req))
+	if err != nil || q == nil {
+		return err
+	}
+	_, err = p.managementClient(q)
+	return err
+}
+
+func (p *GFSDriver) validateShareReadOnly(req *volume.CreateRequest) error {
+	readOnly, err := backend.ReadOnly(req.Options)
+	if err != nil {
+		return err
+	}
+	if !readOnly {
+		return errors.NewValidationError("share requires ro=true")
+	}
+	return nil
+}
+
+func (p *GFSDriver) validateShareOptions(req *volume.CreateRequest) error {
+	for _, option := range []string{"servers", "glusteropts", backend.SubdirOption, backend.TypeOption, "uid", "gid", "mode", sizeOption, strictOption, provisionOption, fromSnapshotOption, cloneOfOption} {
+		if _, ok := req.Options[option]; ok {
+			return errors.NewValidationError(fmt.Sprintf("share is set, %s is not allowed", option))
+		}
+	}
+	return nil
+}
+
+func (p *GFSDriver) validateShareSource(req *volume.CreateRequest) error {
+	source := req.Options[shareOption]
+	if source == "" || source == req.Name {
+		return errors.NewValidationError(fmt.Sprintf("invalid share %q", source))
+	}
+
+	p.mu.Lock()
+	state, ok := p.volumes[source]
+	p.mu.Unlock()
+
+	if !ok {
+		return errors.NewValidationError(fmt.Sprintf("shared volume %s does not exist", source))
+	}
+	if _, nested := state.request.Options[shareOption]; nested {
+		return errors.NewValidationError(fmt.Sprintf("volume %s shares a volume itself and cannot be shared", source))
+	}
 	return nil
 }
 
 // MountOptions returns the mount options for the volume.
-// It constructs the appropriate mount options based on the server configuration
-// and volume options.
+// The options are built by the backend selected with driver_opts.type,
+// using the servers configured for the plugin if there are any.
 //
-// The mount options include:
+// For GlusterFS the mount options include:
 // - Server addresses (-s option)
 // - Volume ID (--volfile-id)
 // - Subdirectory mount point (--subdir-mount) if specified
@@ -82,38 +445,54 @@ func (p *GFSDriver) Validate(req *volume.CreateRequest) error {
 // - req: The create request containing volume options
 //
 // Returns:
-// - List of mount options to use when mounting the volume
+//   - List of mount options to use when mounting the volume,
+//     nil if the request is nil, selects an unknown backend or shares
+//     another volume
 func (p *GFSDriver) MountOptions(req *volume.CreateRequest) []string {
 	if req == nil {
 		log.Printf("warning: MountOptions called with nil request")
 		return nil
 	}
+	if _, ok := req.Options[shareOption]; ok {
+		return nil
+	}
 
-	servers, serversDefinedInOpts := req.Options["servers"]
-	glusteropts, _ := req.Options["glusteropts"]
+	b, err := p.backendFor(req.Options)
+	if err != nil {
+		log.Printf("warning: MountOptions called for volume %s: %v", req.Name, err)
+		return nil
+	}
 
-	var args []string
+	return b.MountArgs(p.backendRequest(req))
+}
 
-	if len(p.Servers) > 0 {
-		for _, server := range p.Servers {
-			args = append(args, "-s", server)
-		}
-		args = appendVolumeOptionsByVolumeName(args, req.Name)
-	} else if serversDefinedInOpts {
-		for _, server := range strings.Split(servers, ",") {
-			args = append(args, "-s", server)
-		}
-		args = appendVolumeOptionsByVolumeName(args, req.Name)
-	} else {
-		args = strings.Split(glusteropts, " ")
+// backendRequest describes a volume to its backend. The servers
+// configured for the plugin are passed only to volumes that name no
+// servers of their own: a volume created with driver_opts.servers or
+// glusteropts while SERVERS was empty keeps mounting from them after
+// SERVERS is set.
+func (p *GFSDriver) backendRequest(req *volume.CreateRequest) *backend.Request {
+	breq := &backend.Request{
+		Name:    req.Name,
+		Options: req.Options,
+	}
+	if usesPluginServers(req.Options) {
+		breq.Servers = p.servers()
 	}
+	return breq
+}
 
-	args = append(args, "--logger=syslog")
-	return args
+// usesPluginServers reports whether a volume with the given options is
+// mounted from the servers configured for the plugin.
+func usesPluginServers(options map[string]string) bool {
+	_, servers := options["servers"]
+	_, glusteropts := options["glusteropts"]
+	return !servers && !glusteropts
 }
 
 // PreMount performs pre-mount operations.
-// It verifies that the mount point exists and is accessible.
+// It verifies that the mount point is a directory and not a symbolic
+// link, which could point the mount outside of the propagated mount root.
 //
 // Parameters:
 // - req: The mount request containing the mount point
@@ -126,59 +505,60 @@ func (p *GFSDriver) PreMount(req *volume.MountRequest) error {
 	}
 
 	// Verify that the mount point exists and is accessible
-	if _, err := os.Stat(req.Mountpoint); err != nil {
+	info, err := os.Lstat(req.Mountpoint)
+	if err != nil {
 		return errors.NewMountError(
 			fmt.Sprintf("mount point %s is not accessible", req.Mountpoint),
 			err,
 		)
 	}
+	if !info.IsDir() {
+		return errors.NewMountError(fmt.Sprintf("mount point %s is not a directory", req.Mountpoint), nil)
+	}
 
 	return nil
 }
 
 // PostMount performs post-mount operations.
-// It verifies that the mount was successful and logs the result.
+// It verifies that the mount was successful, applies the uid, gid and
+// mode options to the root of the mounted volume and logs the result.
+// Ownership is only changed where it differs, so remounts are idempotent.
 //
 // Parameters:
 // - req: The mount request containing the mount point
-func (p *GFSDriver) PostMount(req *volume.MountRequest) {
+//
+// Returns:
+//   - error if the mount point is not accessible or its ownership
+//     cannot be applied, nil otherwise
+func (p *GFSDriver) PostMount(req *volume.MountRequest) error {
 	if req == nil {
-		log.Printf("warning: PostMount called with nil request")
-		return
+		return errors.NewMountError("mount request cannot be nil", nil)
 	}
 
 	// Verify that the mount was successful
 	if _, err := os.Stat(req.Mountpoint); err != nil {
-		log.Printf("error: Mount point %s is not accessible after mount: %v", req.Mountpoint, err)
-		return
+		return errors.NewMountError(
+			fmt.Sprintf("mount point %s is not accessible after mount", req.Mountpoint),
+			err,
+		)
 	}
 
-	log.Printf("successfully mounted volume %s at %s", req.Name, req.Mountpoint)
-}
+	p.mu.Lock()
+	state, ok := p.volumes[req.Name]
+	p.mu.Unlock()
 
-// appendVolumeOptionsByVolumeName appends the command line arguments for volume mounting.
-// It handles both simple volume names and subdirectory mounts.
-//
-// The function adds:
-// - --volfile-id for the volume name
-// - --subdir-mount for any subdirectory path
-//
-// Parameters:
-// - args: The existing command line arguments
-// - volumeName: The name of the volume, optionally including a subdirectory path
-//
-// Returns:
-// - Updated list of command line arguments
-func appendVolumeOptionsByVolumeName(args []string, volumeName string) []string {
-	if volumeName == "" {
-		log.Printf("warning: appendVolumeOptionsByVolumeName called with empty volume name")
-		return args
+	if ok {
+		owner, err := parseOwnership(state.request.Options)
+		if err != nil {
+			return err
+		}
+		if owner != nil {
+			if err := owner.apply(req.Mountpoint); err != nil {
+				return err
+			}
+		}
 	}
 
-	parts := strings.SplitN(volumeName, "/", 2)
-	ret := append(args, "--volfile-id="+parts[0])
-	if len(parts) == 2 {
-		ret = append(ret, "--subdir-mount=/"+parts[1])
-	}
-	return ret
-} 
\ No newline at end of file
+	log.Printf("successfully mounted volume %s at %s", req.Name, req.Mountpoint)
+	return nil
+}
@@ -91,6 +91,33 @@ func TestValidate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name:   "valid read-only",
+			driver: NewDriver([]string{"server1"}),
+			req: &volume.CreateRequest{
+				Name:    "test",
+				Options: map[string]string{"ro": "true"},
+			},
+			wantErr: false,
+		},
+		{
+			name:   "invalid: ro is not a boolean",
+			driver: NewDriver([]string{"server1"}),
+			req: &volume.CreateRequest{
+				Name:    "test",
+				Options: map[string]string{"ro": "maybe"},
+			},
+			wantErr: true,
+		},
+		{
+			name:   "invalid: share of unknown volume",
+			driver: NewDriver([]string{"server1"}),
+			req: &volume.CreateRequest{
+				Name:    "test-ro",
+				Options: map[string]string{"share": "test", "ro": "true"},
+			},
+			wantErr: true,
+		},
 		{
 			name:   "invalid: no servers specified",
 			driver: NewDriver([]string{}),
@@ -154,6 +181,15 @@ func TestMountOptions(t *testing.T) {
 			},
 			want: []string{"-s", "server1", "--volfile-id=test", "--logger=syslog"},
 		},
+		{
+			name:   "read-only",
+			driver: NewDriver([]string{"server1"}),
+			req: &volume.CreateRequest{
+				Name:    "test",
+				Options: map[string]string{"ro": "true"},
+			},
+			want: []string{"-s", "server1", "--volfile-id=test", "--read-only", "--logger=syslog"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -213,44 +249,3 @@ func TestPreMount(t *testing.T) {
 		})
 	}
 }
-
-func TestAppendVolumeOptionsByVolumeName(t *testing.T) {
-	tests := []struct {
-		name      string
-		args      []string
-		volumeName string
-		want      []string
-	}{
-		{
-			name:      "empty volume name",
-			args:      []string{"mount"},
-			volumeName: "",
-			want:      []string{"mount"},
-		},
-		{
-			name:      "simple volume",
-			args:      []string{"mount"},
-			volumeName: "simplevolume",
-			want:      []string{"mount", "--volfile-id=simplevolume"},
-		},
-		{
-			name:      "one level subdir",
-			args:      []string{"mount"},
-			volumeName: "simplevolume/levelone",
-			want:      []string{"mount", "--volfile-id=simplevolume", "--subdir-mount=/levelone"},
-		},
-		{
-			name:      "two levels subdir",
-			args:      []string{"mount"},
-			volumeName: "simplevolume/levelone/level2",
-			want:      []string{"mount", "--volfile-id=simplevolume", "--subdir-mount=/levelone/level2"},
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := appendVolumeOptionsByVolumeName(tt.args, tt.volumeName)
-			assert.Equal(t, tt.want, got)
-		})
-	}
-} 
\ No newline at end of file
@@ -0,0 +1,141 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"glusterfs-plugin/internal/backend"
+	"glusterfs-plugin/internal/backend/glusterfstest"
+	"glusterfs-plugin/internal/utils"
+)
+
+func TestMain(m *testing.M) {
+	glusterfstest.Main()
+	os.Exit(m.Run())
+}
+
+// pluginClient speaks the Docker volume plugin protocol to a plugin
+// socket.
+type pluginClient struct {
+	t    *testing.T
+	http *http.Client
+}
+
+// call posts a request to the plugin and decodes the response.
+func (c *pluginClient) call(method string, req interface{}) map[string]interface{} {
+	c.t.Helper()
+	body, err := json.Marshal(req)
+	require.NoError(c.t, err)
+	resp, err := c.http.Post("http://plugin/"+method, "application/vnd.docker.plugins.v1.2+json", bytes.NewReader(body))
+	require.NoError(c.t, err)
+	defer resp.Body.Close()
+
+	var out map[string]interface{}
+	require.NoError(c.t, json.NewDecoder(resp.Body).Decode(&out))
+	return out
+}
+
+// servePlugin serves a driver mounting through the real GlusterFS backend
+// with fake binaries on a plugin socket in a temporary directory.
+func servePlugin(t *testing.T) (*GFSDriver, *glusterfstest.Binaries, *pluginClient) {
+	t.Helper()
+	bins := glusterfstest.Install(t)
+
+	d := NewDriver([]string{"store1", "store2"})
+	d.Root = filepath.Join(t.TempDir(), "volumes")
+	g := backend.NewGlusterfs()
+	g.Binary = bins.Path(glusterfstest.Glusterfs)
+	g.UmountBinary = bins.Path(glusterfstest.Umount)
+	d.RegisterBackend(g)
+
+	// Unix socket paths are limited to about 100 bytes, shorter than
+	// some test temp directories
+	dir, err := os.MkdirTemp("", "plugin")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "glusterfs.sock")
+
+	go utils.ServeUnixSocket(d, path)
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	client := &pluginClient{t: t, http: &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", path)
+		},
+	}}}
+	return d, bins, client
+}
+
+func TestE2E_Lifecycle(t *testing.T) {
+	_, bins, plugin := servePlugin(t)
+
+	assert.Equal(t, map[string]interface{}{"Implements": []interface{}{"VolumeDriver"}}, plugin.call("Plugin.Activate", nil))
+	assert.Equal(t, "", plugin.call("VolumeDriver.Create", map[string]interface{}{"Name": "vol1/apps", "Opts": map[string]string{"ro": "true"}})["Err"])
+
+	mounted := plugin.call("VolumeDriver.Mount", map[string]string{"Name": "vol1/apps", "ID": "c1"})
+	require.Equal(t, "", mounted["Err"])
+	mountpoint := mounted["Mountpoint"].(string)
+	assert.DirExists(t, mountpoint)
+	assert.Equal(t, mountpoint, plugin.call("VolumeDriver.Path", map[string]string{"Name": "vol1/apps"})["Mountpoint"])
+
+	calls, err := bins.Calls(glusterfstest.Glusterfs)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{
+		"-s", "store1", "-s", "store2", "--volfile-id=vol1", "--subdir-mount=/apps",
+		"--read-only", "--logger=syslog", mountpoint,
+	}}, calls)
+
+	// A mounted volume cannot be removed
+	assert.Contains(t, plugin.call("VolumeDriver.Remove", map[string]string{"Name": "vol1/apps"})["Err"], "IN_USE")
+
+	assert.Equal(t, "", plugin.call("VolumeDriver.Unmount", map[string]string{"Name": "vol1/apps", "ID": "c1"})["Err"])
+	calls, err = bins.Calls(glusterfstest.Umount)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{mountpoint}}, calls)
+	assert.NoDirExists(t, mountpoint)
+
+	assert.Equal(t, "", plugin.call("VolumeDriver.Remove", map[string]string{"Name": "vol1/apps"})["Err"])
+	assert.Equal(t, map[string]interface{}{"Volumes": []interface{}{}, "Err": ""}, plugin.call("VolumeDriver.List", nil))
+}
+
+func TestE2E_MountFailure(t *testing.T) {
+	d, bins, plugin := servePlugin(t)
+	require.NoError(t, bins.Set(glusterfstest.Glusterfs, glusterfstest.Fail("E [MSGID: 101075] DNS resolution failed on host store1")))
+	require.Equal(t, "", plugin.call("VolumeDriver.Create", map[string]interface{}{"Name": "vol1", "Opts": map[string]string{}})["Err"])
+
+	mounted := plugin.call("VolumeDriver.Mount", map[string]string{"Name": "vol1", "ID": "c1"})
+	assert.Contains(t, mounted["Err"], "MOUNT")
+	assert.Contains(t, mounted["Err"], "DNS resolution failed on host store1")
+	assert.Empty(t, d.ActiveMounts())
+
+	// The next mount succeeds once the client does
+	require.NoError(t, bins.Set(glusterfstest.Glusterfs, glusterfstest.Succeed))
+	assert.Equal(t, "", plugin.call("VolumeDriver.Mount", map[string]string{"Name": "vol1", "ID": "c2"})["Err"])
+}
+
+func TestE2E_MountTimeout(t *testing.T) {
+	d, bins, plugin := servePlugin(t)
+	d.MountTimeout = 200 * time.Millisecond
+	require.NoError(t, bins.Set(glusterfstest.Glusterfs, glusterfstest.Hang))
+	require.Equal(t, "", plugin.call("VolumeDriver.Create", map[string]interface{}{"Name": "vol1", "Opts": map[string]string{}})["Err"])
+
+	start := time.Now()
+	mounted := plugin.call("VolumeDriver.Mount", map[string]string{"Name": "vol1", "ID": "c1"})
+	assert.Contains(t, mounted["Err"], "TIMEOUT")
+	assert.Less(t, time.Since(start), 10*time.Second)
+	assert.Empty(t, d.ActiveMounts())
+}
@@ -0,0 +1,141 @@
+package driver
+
+import (
+	"strings"
+
+	"glusterfs-plugin/internal/backend"
+	"glusterfs-plugin/internal/errors"
+	"glusterfs-plugin/pkg/volume"
+)
+
+// Explanation tells how the plugin would handle a create request: which
+// validation rule rejects it, or which servers and mount arguments it
+// would use.
+type Explanation struct {
+	// Name is the volume name
+	Name string `json:"name"`
+
+	// Options are the driver_opts of the request
+	Options map[string]string `json:"options"`
+
+	// Valid reports whether Validate accepts the request
+	Valid bool `json:"valid"`
+
+	// Error is the validation error in the "CODE: message" form
+	Error string `json:"error,omitempty"`
+
+	// Rule names the validation rule that rejected the request
+	Rule string `json:"rule,omitempty"`
+
+	// RuleDescription states what the rule requires
+	RuleDescription string `json:"ruleDescription,omitempty"`
+
+	// Backend is the backend type mounting the volume, or "share"
+	Backend string `json:"backend,omitempty"`
+
+	// Servers are the servers the volume is mounted from
+	Servers []string `json:"servers,omitempty"`
+
+	// ServersSource tells where Servers come from
+	ServersSource string `json:"serversSource,omitempty"`
+
+	// SharedFrom is the volume whose mount a sharing volume uses
+	SharedFrom string `json:"sharedFrom,omitempty"`
+
+	// Args are the mount arguments of a valid request with the reason
+	// of each
+	Args []backend.Argument `json:"args,omitempty"`
+}
+
+// Explain runs the validation of a create request without creating the
+// volume and explains the outcome.
+//
+// Parameters:
+// - req: The create request to explain
+//
+// Returns:
+// - The explanation
+// - ValidationError if the request is nil
+func (p *GFSDriver) Explain(req *volume.CreateRequest) (*Explanation, error) {
+	if req == nil {
+		return nil, errors.NewValidationError("create request cannot be nil")
+	}
+	if req.Options == nil {
+		req = &volume.CreateRequest{Name: req.Name, Options: map[string]string{}}
+	}
+
+	e := &Explanation{Name: req.Name, Options: req.Options}
+	rule, err := p.validate(req)
+	if err != nil {
+		e.Error = errors.Response(err)
+		e.Rule, e.RuleDescription = rule.name, rule.description
+	} else {
+		e.Valid = true
+	}
+
+	if source, ok := req.Options[shareOption]; ok {
+		e.Backend, e.SharedFrom = shareOption, source
+		p.mu.Lock()
+		state, ok := p.volumes[source]
+		p.mu.Unlock()
+		if ok {
+			e.Servers, e.ServersSource = p.resolveServers(state.request)
+			if e.ServersSource != "" {
+				e.ServersSource += " of shared volume " + source
+			}
+		}
+		return e, nil
+	}
+
+	e.Servers, e.ServersSource = p.resolveServers(req)
+	b, err := p.backendFor(req.Options)
+	if err != nil {
+		return e, nil
+	}
+	e.Backend = b.Type()
+	if !e.Valid {
+		return e, nil
+	}
+
+	breq := p.backendRequest(req)
+	if explainer, ok := b.(backend.Explainer); ok {
+		e.Args = explainer.ExplainArgs(breq)
+	} else {
+		for _, arg := range b.MountArgs(breq) {
+			e.Args = append(e.Args, backend.Argument{Value: arg, Reason: "built by the " + b.Type() + " backend"})
+		}
+	}
+	return e, nil
+}
+
+// resolveServers returns the servers a volume is mounted from and where
+// they come from.
+func (p *GFSDriver) resolveServers(req *volume.CreateRequest) ([]string, string) {
+	breq := p.backendRequest(req)
+	source := backend.ServersSource(breq)
+	switch source {
+	case backend.ServersFromPlugin:
+		return append([]string{}, breq.Servers...), source
+	case backend.ServersFromOptions:
+		return backend.SplitList(req.Options["servers"]), source
+	case backend.ServersFromGlusteropts:
+		return glusteroptsServers(req.Options["glusteropts"]), source
+	}
+	return nil, ""
+}
+
+// glusteroptsServers returns the servers named by -s, --volfile-server
+// and --volfile-server= arguments in glusteropts.
+func glusteroptsServers(glusteropts string) []string {
+	var servers []string
+	args := strings.Fields(glusteropts)
+	for i, arg := range args {
+		switch {
+		case (arg == "-s" || arg == "--volfile-server") && i+1 < len(args):
+			servers = append(servers, args[i+1])
+		case strings.HasPrefix(arg, "--volfile-server="):
+			servers = append(servers, strings.TrimPrefix(arg, "--volfile-server="))
+		}
+	}
+	return servers
+}
@@ -0,0 +1,99 @@
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"glusterfs-plugin/internal/backend"
+	"glusterfs-plugin/pkg/volume"
+)
+
+func TestExplain_Rules(t *testing.T) {
+	withServers, _ := newTestDriver(t)
+	withoutServers, _ := newTestDriver(t)
+	withoutServers.Servers = nil
+
+	tests := []struct {
+		name     string
+		d        *GFSDriver
+		options  map[string]string
+		wantRule string
+	}{
+		{name: "options next to SERVERS", d: withServers, options: map[string]string{"servers": "a"}, wantRule: "servers-exclusive"},
+		{name: "servers and glusteropts", d: withoutServers, options: map[string]string{"servers": "a", "glusteropts": "-s a"}, wantRule: "servers-glusteropts"},
+		{name: "no servers", d: withoutServers, options: map[string]string{}, wantRule: "servers-required"},
+		{name: "unknown type", d: withServers, options: map[string]string{"type": "nfs"}, wantRule: "type"},
+		{name: "parent directory in subdir", d: withServers, options: map[string]string{"subdir": "vol1/.."}, wantRule: "name"},
+		{name: "ownership", d: withServers, options: map[string]string{"uid": "x"}, wantRule: "ownership"},
+		{name: "share without ro", d: withServers, options: map[string]string{"share": "data"}, wantRule: "share-ro"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &volume.CreateRequest{Name: "vol1", Options: tt.options}
+			e, err := tt.d.Explain(req)
+			require.NoError(t, err)
+			assert.False(t, e.Valid)
+			assert.Equal(t, tt.wantRule, e.Rule)
+			assert.NotEmpty(t, e.RuleDescription)
+			assert.Contains(t, e.Error, "VALIDATION")
+			assert.Empty(t, e.Args)
+
+			// The explanation agrees with Validate
+			assert.ErrorContains(t, tt.d.Validate(req), e.Error[len("VALIDATION: "):])
+		})
+	}
+}
+
+func TestExplain_Servers(t *testing.T) {
+	d := NewDriver(nil)
+
+	e, err := d.Explain(&volume.CreateRequest{Name: "vol1/sub", Options: map[string]string{"servers": "store1, store2", "ro": "true"}})
+	require.NoError(t, err)
+	assert.True(t, e.Valid)
+	assert.Empty(t, e.Rule)
+	assert.Equal(t, "glusterfs", e.Backend)
+	assert.Equal(t, []string{"store1", "store2"}, e.Servers)
+	assert.Equal(t, backend.ServersFromOptions, e.ServersSource)
+	assert.Equal(t, d.MountOptions(&volume.CreateRequest{Name: "vol1/sub", Options: e.Options}), backend.Values(e.Args))
+	assert.Contains(t, e.Args, backend.Argument{Value: "--subdir-mount=/sub", Reason: "subdirectory, the volume name after the first slash"})
+	assert.Contains(t, e.Args, backend.Argument{Value: "--read-only", Reason: "driver_opts.ro=true"})
+
+	e, err = d.Explain(&volume.CreateRequest{Name: "vol1", Options: map[string]string{"glusteropts": "-s store1 --volfile-server=store2 --volfile-id=vol1"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"store1", "store2"}, e.Servers)
+	assert.Equal(t, backend.ServersFromGlusteropts, e.ServersSource)
+	assert.Equal(t, "driver_opts.glusteropts, passed verbatim", e.Args[0].Reason)
+
+	d.Servers = []string{"store3"}
+	e, err = d.Explain(&volume.CreateRequest{Name: "vol1", Options: nil})
+	require.NoError(t, err)
+	assert.True(t, e.Valid)
+	assert.Equal(t, []string{"store3"}, e.Servers)
+	assert.Equal(t, backend.ServersFromPlugin, e.ServersSource)
+}
+
+func TestExplain_Share(t *testing.T) {
+	d, fake := newTestDriver(t)
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "data", Options: map[string]string{}}))
+
+	e, err := d.Explain(&volume.CreateRequest{Name: "data-ro", Options: map[string]string{"share": "data", "ro": "true"}})
+	require.NoError(t, err)
+	assert.True(t, e.Valid)
+	assert.Equal(t, "share", e.Backend)
+	assert.Equal(t, "data", e.SharedFrom)
+	assert.Equal(t, []string{"server1"}, e.Servers)
+	assert.Equal(t, "SERVERS of shared volume data", e.ServersSource)
+	assert.Empty(t, e.Args)
+
+	// Backends that cannot explain their arguments get a generic reason
+	e, err = d.Explain(&volume.CreateRequest{Name: "data", Options: map[string]string{}})
+	require.NoError(t, err)
+	assert.Equal(t, []backend.Argument{
+		{Value: "data", Reason: "built by the glusterfs backend"},
+		{Value: "servers=server1", Reason: "built by the glusterfs backend"},
+	}, e.Args)
+	assert.Empty(t, fake.Calls())
+}
@@ -0,0 +1,93 @@
+package driver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"glusterfs-plugin/internal/errors"
+	"glusterfs-plugin/pkg/volume"
+)
+
+const (
+	// DefaultRoot is the mount root of the plugin, the propagatedMount of
+	// config.json, so that Docker sees the mounts made by the plugin.
+	DefaultRoot = "/var/lib/docker-volumes"
+
+	// rootMode lets Docker reach the mount points without listing them.
+	rootMode = 0711
+
+	// mountpointMode keeps unmounted mount points private; once mounted,
+	// the root of the volume defines the permissions.
+	mountpointMode = 0700
+
+	// maxMountpointPrefix bounds the readable part of mount point names.
+	maxMountpointPrefix = 48
+)
+
+// mountpointFor returns the mount point of a volume: a directory below
+// Root named after the volume and a hash of its name and options, so
+// that volumes never share a directory and recreating a volume with
+// other options does not reuse the directory of a stale mount.
+func (p *GFSDriver) mountpointFor(req *volume.CreateRequest) string {
+	// Quoting tells an option a with value b=c from an option a=b with
+	// value c
+	h := sha256.New()
+	fmt.Fprintf(h, "%q", req.Name)
+	keys := make([]string, 0, len(req.Options))
+	for key := range req.Options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(h, " %q=%q", key, req.Options[key])
+	}
+
+	prefix := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, req.Name)
+	if len(prefix) > maxMountpointPrefix {
+		prefix = prefix[:maxMountpointPrefix]
+	}
+	return filepath.Join(p.Root, prefix+"-"+hex.EncodeToString(h.Sum(nil))[:32])
+}
+
+// createMountpoint creates a mount point returned by mountpointFor, and
+// the mount root if needed. An existing mount point is reused if it is
+// a directory.
+func (p *GFSDriver) createMountpoint(mountpoint string) error {
+	if err := os.MkdirAll(p.Root, rootMode); err != nil {
+		return errors.NewMountError(fmt.Sprintf("failed to create mount root %s", p.Root), err)
+	}
+	if err := os.Mkdir(mountpoint, mountpointMode); err != nil && !os.IsExist(err) {
+		return errors.NewMountError(fmt.Sprintf("failed to create mount point %s", mountpoint), err)
+	}
+	return nil
+}
+
+// removeMountpoint removes a mount point created by createMountpoint
+// once the volume is unmounted. Mount points outside Root and
+// directories that are not empty, such as mount points still mounted,
+// are left alone.
+func (p *GFSDriver) removeMountpoint(mountpoint string) {
+	if !p.ownsMountpoint(mountpoint) {
+		return
+	}
+	if err := os.Remove(mountpoint); err != nil && !os.IsNotExist(err) {
+		log.Printf("warning: failed to remove mount point %s: %v", mountpoint, err)
+	}
+}
+
+// ownsMountpoint reports whether a mount point is a directory of Root,
+// as created by createMountpoint.
+func (p *GFSDriver) ownsMountpoint(mountpoint string) bool {
+	return filepath.Dir(mountpoint) == filepath.Clean(p.Root)
+}
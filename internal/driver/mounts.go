@@ -0,0 +1,244 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"glusterfs-plugin/internal/backend"
+	"glusterfs-plugin/internal/errors"
+	"glusterfs-plugin/pkg/volume"
+)
+
+// MountInfo describes an active mount of a volume.
+type MountInfo struct {
+	// Name is the volume name
+	Name string `json:"name"`
+
+	// Mountpoint is where the volume is mounted
+	Mountpoint string `json:"mountpoint"`
+
+	// Refs counts the mounts Docker holds on the volume
+	Refs int `json:"refs"`
+
+	// Type is the backend type, or "share" for volumes sharing another
+	Type string `json:"type"`
+
+	// SharedFrom is the volume shared by a sharing volume
+	SharedFrom string `json:"sharedFrom,omitempty"`
+
+	// Servers are the plugin servers the mount was built from, empty if
+	// the volume names its own servers
+	Servers []string `json:"servers,omitempty"`
+
+	// Stale is set if the plugin servers changed since the mount was
+	// built; it is built with the current servers once its last
+	// container releases it, see StaleMounts
+	Stale bool `json:"stale,omitempty"`
+}
+
+// StaleMount is a mount built from plugin servers that a reload has
+// since changed. Containers bind mount a copy of the volume mount, so it
+// cannot be rebuilt under them: the volume is mounted from the current
+// servers once the containers using it are restarted.
+type StaleMount struct {
+	// Name is the volume name
+	Name string `json:"name"`
+
+	// Refs counts the mounts Docker holds on the volume
+	Refs int `json:"refs"`
+
+	// Servers are the servers the volume is mounted from
+	Servers []string `json:"servers"`
+}
+
+// ActiveMounts returns the active mounts, sorted by volume name.
+//
+// Returns:
+// - The mounted volumes
+func (p *GFSDriver) ActiveMounts() []MountInfo {
+	current := p.servers()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var mounts []MountInfo
+	for name, state := range p.volumes {
+		if state.refs == 0 {
+			continue
+		}
+		info := MountInfo{Name: name, Mountpoint: state.mountpoint, Refs: state.refs, Type: backend.GlusterfsType}
+		if len(state.servers) > 0 {
+			info.Servers = append([]string(nil), state.servers...)
+			info.Stale = !equalServers(state.servers, current)
+		}
+		if source := state.request.Options[shareOption]; source != "" {
+			info.Type, info.SharedFrom = shareOption, source
+		} else if state.backend != nil {
+			info.Type = state.backend.Type()
+		}
+		mounts = append(mounts, info)
+	}
+	sort.Slice(mounts, func(i, j int) bool { return mounts[i].Name < mounts[j].Name })
+	return mounts
+}
+
+// ForceUnmount unmounts a volume regardless of how many mounts Docker
+// holds on it, for operators recovering from stale mounts.
+// Volumes whose mount is shared by mounted volumes are not unmounted.
+//
+// Parameters:
+// - ctx: The context of the originating request
+// - name: The name of the volume
+//
+// Returns:
+// - NotFoundError if the volume does not exist
+// - MountError if it is not mounted or cannot be unmounted
+// - InUseError if mounted volumes share it
+func (p *GFSDriver) ForceUnmount(ctx context.Context, name string) error {
+	unlock := p.locks.Lock(name)
+	defer unlock()
+
+	state, refs, mountpoint, err := p.mountedState(name)
+	if err != nil {
+		return err
+	}
+
+	if err := p.unmountVolume(ctx, state, name, mountpoint); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	state.dropRefs()
+	p.mu.Unlock()
+	p.removeMountpoint(mountpoint)
+
+	log.Printf("forcibly unmounted volume %s from %s, dropping %d mount(s)", name, mountpoint, refs)
+	return nil
+}
+
+// Remount unmounts a volume and mounts it again at the same mount point,
+// keeping the mounts Docker holds on it, for operators recovering from a
+// hung or disconnected client. The volume is mounted with its current
+// mount arguments, but it is still reported as built from the servers
+// of its previous mount: containers keep the mount they were given.
+// Volumes sharing another volume are remounted through that volume, and
+// volumes whose mount is shared by mounted volumes are not remounted.
+//
+// Parameters:
+// - ctx: The context of the originating request
+// - name: The name of the volume
+//
+// Returns:
+//   - NotFoundError if the volume does not exist
+//   - ValidationError if the volume shares another volume
+//   - MountError if it is not mounted or cannot be remounted; the volume
+//     is left unmounted if mounting it again fails
+//   - InUseError if mounted volumes share it
+func (p *GFSDriver) Remount(ctx context.Context, name string) error {
+	unlock := p.locks.Lock(name)
+	defer unlock()
+
+	state, refs, mountpoint, err := p.mountedState(name)
+	if err != nil {
+		return err
+	}
+	if source := state.request.Options[shareOption]; source != "" {
+		return errors.NewValidationError(fmt.Sprintf("volume %s shares volume %s, remount %s instead", name, source, source))
+	}
+
+	if err := p.unmountBackend(ctx, state, name, mountpoint); err != nil {
+		return err
+	}
+
+	// Containers bind mount a copy of the mount and do not see the new
+	// one, so it stays stale until they release it
+	p.mu.Lock()
+	servers := state.servers
+	p.mu.Unlock()
+
+	mountReq := &volume.MountRequest{Name: name, Mountpoint: mountpoint}
+	err = p.mountBackend(ctx, state, name, mountpoint)
+	if err == nil {
+		if err = p.PostMount(mountReq); err != nil {
+			if undoErr := p.unmountBackend(context.Background(), state, name, mountpoint); undoErr != nil {
+				log.Printf("warning: failed to undo remount of volume %s: %v", name, undoErr)
+			}
+		}
+	}
+	if err != nil {
+		p.mu.Lock()
+		state.dropRefs()
+		p.mu.Unlock()
+		p.removeMountpoint(mountpoint)
+		log.Printf("warning: volume %s is unmounted after a failed remount, dropping %d mount(s)", name, refs)
+		return err
+	}
+
+	p.mu.Lock()
+	state.servers = servers
+	p.mu.Unlock()
+	log.Printf("remounted volume %s at %s", name, mountpoint)
+	return nil
+}
+
+// StaleMounts returns the mounts built from plugin servers that a
+// reload has since changed. They keep their servers until the last
+// container using them releases them; Remount does not rebuild them
+// either, since containers keep the mount they were given.
+//
+// Returns:
+// - The stale mounts, sorted by volume name
+func (p *GFSDriver) StaleMounts() []StaleMount {
+	var stale []StaleMount
+	for _, m := range p.ActiveMounts() {
+		if m.Stale {
+			stale = append(stale, StaleMount{Name: m.Name, Refs: m.Refs, Servers: m.Servers})
+		}
+	}
+	return stale
+}
+
+// equalServers reports whether two server lists are the same, in order.
+func equalServers(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// mountedState returns the state of a mounted volume that no mounted
+// volume shares, with its mount count and mount point.
+func (p *GFSDriver) mountedState(name string) (*volumeState, int, string, error) {
+	p.mu.Lock()
+	state, ok := p.volumes[name]
+	var refs int
+	var mountpoint string
+	var sharers []string
+	if ok {
+		refs, mountpoint = state.refs, state.mountpoint
+		for other, s := range p.volumes {
+			if s.refs > 0 && s.request.Options[shareOption] == name {
+				sharers = append(sharers, other)
+			}
+		}
+	}
+	p.mu.Unlock()
+
+	switch {
+	case !ok:
+		return nil, 0, "", errors.NewNotFoundError(fmt.Sprintf("volume %s does not exist", name), nil)
+	case refs == 0:
+		return nil, 0, "", errors.NewMountError(fmt.Sprintf("volume %s is not mounted", name), nil)
+	case len(sharers) > 0:
+		sort.Strings(sharers)
+		return nil, 0, "", errors.NewInUseError(fmt.Sprintf("volume %s is shared by mounted volumes %v", name, sharers), nil)
+	}
+	return state, refs, mountpoint, nil
+}
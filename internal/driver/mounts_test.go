@@ -0,0 +1,231 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"glusterfs-plugin/internal/errors"
+	"glusterfs-plugin/pkg/volume"
+)
+
+func TestActiveMounts(t *testing.T) {
+	d, _ := newTestDriver(t)
+	d.bindMount = func(source, target string, readOnly bool) error { return nil }
+	d.unbind = func(target string) error { return nil }
+
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "data", Options: map[string]string{}}))
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "data-ro", Options: map[string]string{"share": "data", "ro": "true"}}))
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "idle", Options: map[string]string{}}))
+	assert.Empty(t, d.ActiveMounts())
+
+	data, err := d.Mount(context.Background(), &volume.MountRequest{Name: "data", ID: "a"})
+	require.NoError(t, err)
+	_, err = d.Mount(context.Background(), &volume.MountRequest{Name: "data", ID: "b"})
+	require.NoError(t, err)
+	ro, err := d.Mount(context.Background(), &volume.MountRequest{Name: "data-ro", ID: "c"})
+	require.NoError(t, err)
+
+	// The sharing volume holds a mount on the shared one
+	assert.Equal(t, []MountInfo{
+		{Name: "data", Mountpoint: data, Refs: 3, Type: "glusterfs", Servers: []string{"server1"}},
+		{Name: "data-ro", Mountpoint: ro, Refs: 1, Type: "share", SharedFrom: "data"},
+	}, d.ActiveMounts())
+}
+
+func TestForceUnmount(t *testing.T) {
+	d, fake := newTestDriver(t)
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{}}))
+
+	err := d.ForceUnmount(context.Background(), "vol1")
+	assert.ErrorIs(t, err, errors.ErrMount)
+	assert.ErrorContains(t, err, "not mounted")
+	assert.ErrorIs(t, d.ForceUnmount(context.Background(), "missing"), errors.ErrNotFound)
+
+	for _, id := range []string{"a", "b"} {
+		_, err := d.Mount(context.Background(), &volume.MountRequest{Name: "vol1", ID: id})
+		require.NoError(t, err)
+	}
+	require.NoError(t, d.ForceUnmount(context.Background(), "vol1"))
+	assert.Equal(t, 0, fake.MountCount())
+	assert.Empty(t, d.ActiveMounts())
+
+	// The volume can be mounted again afterwards
+	_, err = d.Mount(context.Background(), &volume.MountRequest{Name: "vol1", ID: "c"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, fake.MountCount())
+}
+
+func TestForceUnmount_SharedVolumeInUse(t *testing.T) {
+	d, fake := newTestDriver(t)
+	d.bindMount = func(source, target string, readOnly bool) error { return nil }
+	d.unbind = func(target string) error { return nil }
+
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "data", Options: map[string]string{}}))
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "data-ro", Options: map[string]string{"share": "data", "ro": "true"}}))
+	_, err := d.Mount(context.Background(), &volume.MountRequest{Name: "data-ro", ID: "reader"})
+	require.NoError(t, err)
+
+	err = d.ForceUnmount(context.Background(), "data")
+	assert.ErrorIs(t, err, errors.ErrInUse)
+	assert.ErrorContains(t, err, "data-ro")
+	assert.Equal(t, 1, fake.MountCount())
+
+	// Unmounting the sharing volume first releases the shared mount
+	require.NoError(t, d.ForceUnmount(context.Background(), "data-ro"))
+	assert.Equal(t, 0, fake.MountCount())
+}
+
+func TestRemount(t *testing.T) {
+	d, fake := newTestDriver(t)
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{}}))
+	assert.ErrorIs(t, d.Remount(context.Background(), "vol1"), errors.ErrMount)
+	assert.ErrorIs(t, d.Remount(context.Background(), "missing"), errors.ErrNotFound)
+
+	for _, id := range []string{"a", "b"} {
+		_, err := d.Mount(context.Background(), &volume.MountRequest{Name: "vol1", ID: id})
+		require.NoError(t, err)
+	}
+	require.NoError(t, d.Remount(context.Background(), "vol1"))
+
+	// The mounts Docker holds survive the remount
+	mountpoint, err := d.Path("vol1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"mount " + mountpoint, "unmount " + mountpoint, "mount " + mountpoint}, fake.Calls())
+	assert.Equal(t, []MountInfo{{Name: "vol1", Mountpoint: mountpoint, Refs: 2, Type: "glusterfs", Servers: []string{"server1"}}}, d.ActiveMounts())
+	require.NoError(t, d.Unmount(context.Background(), &volume.MountRequest{Name: "vol1", ID: "a"}))
+	require.NoError(t, d.Unmount(context.Background(), &volume.MountRequest{Name: "vol1", ID: "b"}))
+	assert.Equal(t, 0, fake.MountCount())
+}
+
+func TestRemount_FailureLeavesVolumeUnmounted(t *testing.T) {
+	d, fake := newTestDriver(t)
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{}}))
+	_, err := d.Mount(context.Background(), &volume.MountRequest{Name: "vol1", ID: "a"})
+	require.NoError(t, err)
+
+	fake.MountErr = fmt.Errorf("connection refused")
+	assert.ErrorIs(t, d.Remount(context.Background(), "vol1"), errors.ErrMount)
+	assert.Empty(t, d.ActiveMounts())
+	assert.Equal(t, 0, fake.MountCount())
+
+	// The next mount starts from scratch
+	fake.MountErr = nil
+	_, err = d.Mount(context.Background(), &volume.MountRequest{Name: "vol1", ID: "b"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, fake.MountCount())
+}
+
+func TestRemount_Shares(t *testing.T) {
+	d, _ := newTestDriver(t)
+	d.bindMount = func(source, target string, readOnly bool) error { return nil }
+	d.unbind = func(target string) error { return nil }
+
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "data", Options: map[string]string{}}))
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "data-ro", Options: map[string]string{"share": "data", "ro": "true"}}))
+	_, err := d.Mount(context.Background(), &volume.MountRequest{Name: "data-ro", ID: "reader"})
+	require.NoError(t, err)
+
+	err = d.Remount(context.Background(), "data-ro")
+	assert.ErrorIs(t, err, errors.ErrValidation)
+	assert.ErrorContains(t, err, "remount data instead")
+	assert.ErrorIs(t, d.Remount(context.Background(), "data"), errors.ErrInUse)
+}
+
+func TestSetServers(t *testing.T) {
+	d, fake := newTestDriver(t)
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{}}))
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol2", Options: map[string]string{}}))
+	old, err := d.Mount(context.Background(), &volume.MountRequest{Name: "vol1", ID: "a"})
+	require.NoError(t, err)
+
+	previous, err := d.SetServers([]string{"server2", "server3"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"server1"}, previous)
+
+	// New mounts use the new servers, existing mounts keep theirs
+	mountpoint, err := d.Mount(context.Background(), &volume.MountRequest{Name: "vol2", ID: "b"})
+	require.NoError(t, err)
+	args, _ := fake.Mounted(mountpoint)
+	assert.Equal(t, []string{"vol2", "servers=server2,server3"}, args)
+	args, _ = fake.Mounted(old)
+	assert.Equal(t, []string{"vol1", "servers=server1"}, args)
+}
+
+func TestStaleMounts(t *testing.T) {
+	d, fake := newTestDriver(t)
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{}}))
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol2", Options: map[string]string{}}))
+	vol1, err := d.Mount(context.Background(), &volume.MountRequest{Name: "vol1", ID: "a"})
+	require.NoError(t, err)
+	vol2, err := d.Mount(context.Background(), &volume.MountRequest{Name: "vol2", ID: "b"})
+	require.NoError(t, err)
+	assert.Empty(t, d.StaleMounts())
+
+	_, err = d.SetServers([]string{"server2", "server3"})
+	require.NoError(t, err)
+	assert.Equal(t, []MountInfo{
+		{Name: "vol1", Mountpoint: vol1, Refs: 1, Type: "glusterfs", Servers: []string{"server1"}, Stale: true},
+		{Name: "vol2", Mountpoint: vol2, Refs: 1, Type: "glusterfs", Servers: []string{"server1"}, Stale: true},
+	}, d.ActiveMounts())
+
+	// Releasing the last mount rebuilds the arguments on the next mount
+	require.NoError(t, d.Unmount(context.Background(), &volume.MountRequest{Name: "vol2", ID: "b"}))
+	vol2, err = d.Mount(context.Background(), &volume.MountRequest{Name: "vol2", ID: "c"})
+	require.NoError(t, err)
+	args, _ := fake.Mounted(vol2)
+	assert.Equal(t, []string{"vol2", "servers=server2,server3"}, args)
+
+	// Mounts still in use are reported, not remounted under their
+	// containers
+	assert.Equal(t, []StaleMount{{Name: "vol1", Refs: 1, Servers: []string{"server1"}}}, d.StaleMounts())
+	args, _ = fake.Mounted(vol1)
+	assert.Equal(t, []string{"vol1", "servers=server1"}, args)
+	assert.Equal(t, []MountInfo{
+		{Name: "vol1", Mountpoint: vol1, Refs: 1, Type: "glusterfs", Servers: []string{"server1"}, Stale: true},
+		{Name: "vol2", Mountpoint: vol2, Refs: 1, Type: "glusterfs", Servers: []string{"server2", "server3"}},
+	}, d.ActiveMounts())
+}
+
+func TestRemount_StaysStale(t *testing.T) {
+	d, fake := newTestDriver(t)
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{}}))
+	mountpoint, err := d.Mount(context.Background(), &volume.MountRequest{Name: "vol1", ID: "a"})
+	require.NoError(t, err)
+	_, err = d.SetServers([]string{"server2"})
+	require.NoError(t, err)
+
+	// The containers keep the mount built from the previous servers
+	require.NoError(t, d.Remount(context.Background(), "vol1"))
+	args, _ := fake.Mounted(mountpoint)
+	assert.Equal(t, []string{"vol1", "servers=server2"}, args)
+	assert.Equal(t, []StaleMount{{Name: "vol1", Refs: 1, Servers: []string{"server1"}}}, d.StaleMounts())
+}
+
+func TestSetServers_Concurrent(t *testing.T) {
+	d, _ := newTestDriver(t)
+
+	// Run with -race: swapping servers while requests validate and
+	// mount volumes must not race
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			if _, err := d.SetServers([]string{fmt.Sprintf("server%d", i)}); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		name := fmt.Sprintf("vol%d", i)
+		require.NoError(t, d.Create(&volume.CreateRequest{Name: name, Options: map[string]string{}}))
+		_, err := d.Mount(context.Background(), &volume.MountRequest{Name: name, ID: "a"})
+		require.NoError(t, err)
+		_, err = d.Explain(&volume.CreateRequest{Name: name, Options: map[string]string{}})
+		require.NoError(t, err)
+	}
+	<-done
+}
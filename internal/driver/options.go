@@ -0,0 +1,93 @@
+package driver
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"glusterfs-plugin/internal/backend"
+	"glusterfs-plugin/internal/management"
+)
+
+// normalizeOptions returns driver_opts in a canonical form, so that
+// options written differently but mounting the same way compare equal:
+// values are trimmed, server lists lose their blanks, glusteropts their
+// repeated spaces, and booleans, sizes and subdirectories are rendered
+// the way they are parsed. Values that do not parse are only trimmed.
+//
+// Parameters:
+// - options: The volume driver_opts
+//
+// Returns:
+// - The normalized options, a new map
+func normalizeOptions(options map[string]string) map[string]string {
+	normalized := make(map[string]string, len(options))
+	for key, value := range options {
+		value = strings.TrimSpace(value)
+		switch key {
+		case "servers":
+			value = strings.Join(backend.SplitList(value), ",")
+		case "glusteropts":
+			value = strings.Join(strings.Fields(value), " ")
+		case sizeOption:
+			if size, err := management.ParseSize(value); err == nil {
+				value = strconv.FormatInt(size, 10)
+			}
+		case backend.SubdirOption:
+			if volume, subdir, err := backend.ParseVolumePath(value); err == nil {
+				value = strings.TrimSuffix(volume+"/"+subdir, "/")
+			}
+		case "ro", strictOption, provisionOption:
+			if b, err := strconv.ParseBool(value); err == nil {
+				value = strconv.FormatBool(b)
+			}
+		}
+		normalized[key] = value
+	}
+	return normalized
+}
+
+// optionDiff lists the options that differ between a registered volume
+// and a create request, sorted by name, such as `ro: "false" -> "true"`.
+// Both are compared in normalized form, options missing on one side are
+// shown as unset.
+//
+// Parameters:
+// - registered: The driver_opts the volume was created with
+// - requested: The driver_opts of the new create request
+//
+// Returns:
+// - The differences, empty if the options are equivalent
+func optionDiff(registered, requested map[string]string) []string {
+	registered, requested = normalizeOptions(registered), normalizeOptions(requested)
+	keys := make([]string, 0, len(registered)+len(requested))
+	for key := range registered {
+		keys = append(keys, key)
+	}
+	for key := range requested {
+		if _, ok := registered[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var diff []string
+	for _, key := range keys {
+		old, hadOld := registered[key]
+		value, hasNew := requested[key]
+		if hadOld == hasNew && old == value {
+			continue
+		}
+		diff = append(diff, fmt.Sprintf("%s: %s -> %s", key, optionValue(old, hadOld), optionValue(value, hasNew)))
+	}
+	return diff
+}
+
+// optionValue renders an option value for optionDiff.
+func optionValue(value string, ok bool) string {
+	if !ok {
+		return "unset"
+	}
+	return strconv.Quote(value)
+}
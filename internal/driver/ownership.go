@@ -0,0 +1,156 @@
+package driver
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+
+	"glusterfs-plugin/internal/errors"
+)
+
+// ownership is the owner and mode requested for the root of a mounted
+// volume through driver_opts uid, gid and mode.
+type ownership struct {
+	// uid and gid are -1 when not requested
+	uid int
+	gid int
+
+	// mode is only applied if hasMode is set
+	mode    os.FileMode
+	hasMode bool
+}
+
+// parseOwnership reads the uid, gid and mode volume options.
+//
+// Parameters:
+// - options: The volume driver_opts
+//
+// Returns:
+// - The requested ownership, nil if none of the options is set
+// - ValidationError if an option is malformed
+func parseOwnership(options map[string]string) (*ownership, error) {
+	o := &ownership{uid: -1, gid: -1}
+	set := false
+
+	for _, id := range []struct {
+		name   string
+		target *int
+	}{{"uid", &o.uid}, {"gid", &o.gid}} {
+		value, ok := options[id.name]
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return nil, errors.NewValidationError(fmt.Sprintf("invalid %s %q, must be a non-negative number", id.name, value))
+		}
+		*id.target = n
+		set = true
+	}
+
+	if value, ok := options["mode"]; ok {
+		mode, err := strconv.ParseUint(value, 8, 32)
+		if err != nil || mode > 0o7777 {
+			return nil, errors.NewValidationError(fmt.Sprintf("invalid mode %q, must be an octal permission such as 0775", value))
+		}
+		o.mode = os.FileMode(mode).Perm() | specialBits(mode)
+		o.hasMode = true
+		set = true
+	}
+
+	if !set {
+		return nil, nil
+	}
+	return o, nil
+}
+
+// specialBits converts the setuid, setgid and sticky bits of a numeric
+// mode to their os.FileMode equivalents.
+func specialBits(mode uint64) os.FileMode {
+	var bits os.FileMode
+	if mode&0o4000 != 0 {
+		bits |= os.ModeSetuid
+	}
+	if mode&0o2000 != 0 {
+		bits |= os.ModeSetgid
+	}
+	if mode&0o1000 != 0 {
+		bits |= os.ModeSticky
+	}
+	return bits
+}
+
+// apply sets the owner and mode of path where they differ from the
+// requested ones. Applying the same ownership again changes nothing,
+// so it is safe across remounts.
+//
+// Parameters:
+// - path: The root directory of the mounted volume
+//
+// Returns:
+// - PermissionDeniedError if the change is not permitted
+// - MountError if the directory cannot be inspected or changed
+func (o *ownership) apply(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return errors.NewMountError(fmt.Sprintf("cannot inspect %s", path), err)
+	}
+
+	uid, gid := o.uid, o.gid
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		if uid == int(stat.Uid) {
+			uid = -1
+		}
+		if gid == int(stat.Gid) {
+			gid = -1
+		}
+	}
+	if uid != -1 || gid != -1 {
+		if err := os.Chown(path, uid, gid); err != nil {
+			return ownershipError(fmt.Sprintf("failed to change owner of %s to %s", path, o.owner()), err)
+		}
+	}
+
+	if o.hasMode && info.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky) != o.mode {
+		if err := os.Chmod(path, o.mode); err != nil {
+			return ownershipError(fmt.Sprintf("failed to change mode of %s to %#o", path, o.numericMode()), err)
+		}
+	}
+	return nil
+}
+
+// owner renders the requested owner as uid:gid, with - for unchanged ids.
+func (o *ownership) owner() string {
+	format := func(id int) string {
+		if id < 0 {
+			return "-"
+		}
+		return strconv.Itoa(id)
+	}
+	return format(o.uid) + ":" + format(o.gid)
+}
+
+// numericMode returns the requested mode as a number, as given in the options.
+func (o *ownership) numericMode() uint32 {
+	mode := uint32(o.mode.Perm())
+	if o.mode&os.ModeSetuid != 0 {
+		mode |= 0o4000
+	}
+	if o.mode&os.ModeSetgid != 0 {
+		mode |= 0o2000
+	}
+	if o.mode&os.ModeSticky != 0 {
+		mode |= 0o1000
+	}
+	return mode
+}
+
+// ownershipError wraps a failed chown or chmod, reporting permission
+// problems as PermissionDeniedError.
+func ownershipError(message string, err error) error {
+	if os.IsPermission(err) {
+		return errors.NewPermissionDeniedError(message, err)
+	}
+	return errors.NewMountError(message, err)
+}
@@ -0,0 +1,142 @@
+package driver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"glusterfs-plugin/internal/errors"
+	"glusterfs-plugin/pkg/volume"
+)
+
+func TestParseOwnership(t *testing.T) {
+	tests := []struct {
+		name    string
+		options map[string]string
+		want    *ownership
+		wantErr bool
+	}{
+		{name: "none", options: map[string]string{}, want: nil},
+		{name: "uid only", options: map[string]string{"uid": "1000"}, want: &ownership{uid: 1000, gid: -1}},
+		{name: "uid and gid", options: map[string]string{"uid": "1000", "gid": "100"}, want: &ownership{uid: 1000, gid: 100}},
+		{name: "mode", options: map[string]string{"mode": "0775"}, want: &ownership{uid: -1, gid: -1, mode: 0o775, hasMode: true}},
+		{name: "mode with setgid", options: map[string]string{"mode": "2770"}, want: &ownership{uid: -1, gid: -1, mode: 0o770 | os.ModeSetgid, hasMode: true}},
+		{name: "negative uid", options: map[string]string{"uid": "-1"}, wantErr: true},
+		{name: "named gid", options: map[string]string{"gid": "staff"}, wantErr: true},
+		{name: "decimal mode", options: map[string]string{"mode": "0789"}, wantErr: true},
+		{name: "mode out of range", options: map[string]string{"mode": "17777"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseOwnership(tt.options)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, errors.ErrValidation)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestOwnership_Apply(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "root")
+	require.NoError(t, os.Mkdir(dir, 0755))
+
+	owner := &ownership{uid: os.Getuid(), gid: os.Getgid(), mode: 0o770 | os.ModeSetgid, hasMode: true}
+	require.NoError(t, owner.apply(dir))
+
+	info, err := os.Stat(dir)
+	require.NoError(t, err)
+	assert.Equal(t, os.ModeDir|0o770|os.ModeSetgid, info.Mode())
+
+	// Applying again is a no-op
+	require.NoError(t, owner.apply(dir))
+	info2, err := os.Stat(dir)
+	require.NoError(t, err)
+	assert.Equal(t, info.ModTime(), info2.ModTime())
+	assert.Equal(t, info.Sys().(*syscall.Stat_t).Ctim, info2.Sys().(*syscall.Stat_t).Ctim)
+
+	err = owner.apply(filepath.Join(dir, "missing"))
+	assert.ErrorIs(t, err, errors.ErrMount)
+	assert.ErrorContains(t, err, "cannot inspect")
+}
+
+func TestOwnership_ApplyChown(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("changing the owner requires root")
+	}
+	dir := t.TempDir()
+
+	owner := &ownership{uid: 1234, gid: 5678}
+	require.NoError(t, owner.apply(dir))
+
+	stat := mustStat(t, dir)
+	assert.Equal(t, uint32(1234), stat.Uid)
+	assert.Equal(t, uint32(5678), stat.Gid)
+}
+
+func TestOwnership_ApplyPermissionDenied(t *testing.T) {
+	if os.Getuid() == 0 {
+		t.Skip("root may change any owner")
+	}
+	owner := &ownership{uid: 0, gid: -1}
+	err := owner.apply(t.TempDir())
+	assert.ErrorIs(t, err, errors.ErrPermissionDenied)
+	assert.ErrorContains(t, err, "failed to change owner of")
+}
+
+func TestMount_AppliesOwnership(t *testing.T) {
+	d, fake := newTestDriver(t)
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{"mode": "0700"}}))
+
+	mountpoint, err := d.Mount(context.Background(), &volume.MountRequest{Name: "vol1"})
+	require.NoError(t, err)
+	info, err := os.Stat(mountpoint)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o700), info.Mode().Perm())
+	assert.Equal(t, 1, fake.MountCount())
+}
+
+func TestMount_OwnershipFailureUnmounts(t *testing.T) {
+	d, fake := newTestDriver(t)
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{"mode": "0700"}}))
+
+	// Not even root may change the mode of a procfs directory
+	fake.OnMount = func(mountpoint string) {
+		require.NoError(t, os.Remove(mountpoint))
+		require.NoError(t, os.Symlink("/proc/self/fd", mountpoint))
+	}
+	_, err := d.Mount(context.Background(), &volume.MountRequest{Name: "vol1"})
+	assert.Error(t, err)
+	assert.ErrorContains(t, err, "failed to change mode of")
+	assert.Equal(t, 0, fake.MountCount())
+	assert.Len(t, fake.Calls(), 2)
+	assert.Contains(t, fake.Calls()[1], "unmount ")
+}
+
+func TestValidate_OwnershipConflicts(t *testing.T) {
+	d := NewDriver([]string{"server1"})
+
+	err := d.Validate(&volume.CreateRequest{Name: "vol1", Options: map[string]string{"uid": "1000", "ro": "true"}})
+	assert.ErrorIs(t, err, errors.ErrValidation)
+	assert.ErrorContains(t, err, "read-only")
+
+	err = d.Validate(&volume.CreateRequest{Name: "vol1", Options: map[string]string{"mode": "abc"}})
+	assert.ErrorIs(t, err, errors.ErrValidation)
+
+	assert.NoError(t, d.Validate(&volume.CreateRequest{Name: "vol1", Options: map[string]string{"uid": "1000", "gid": "1000", "mode": "0775"}}))
+}
+
+func mustStat(t *testing.T, path string) *syscall.Stat_t {
+	t.Helper()
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	return info.Sys().(*syscall.Stat_t)
+}
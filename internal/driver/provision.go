@@ -0,0 +1,235 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"glusterfs-plugin/internal/backend"
+	"glusterfs-plugin/internal/errors"
+	"glusterfs-plugin/internal/management"
+	"glusterfs-plugin/pkg/volume"
+)
+
+// provisionOption creates the GlusterFS volume on docker volume create.
+const provisionOption = "provision"
+
+// ReclaimPolicy decides what happens to a provisioned GlusterFS volume
+// when its Docker volume is removed.
+type ReclaimPolicy string
+
+const (
+	// ReclaimRetain leaves the GlusterFS volume in place.
+	ReclaimRetain ReclaimPolicy = "retain"
+
+	// ReclaimDelete stops and deletes the GlusterFS volume. The data on
+	// the bricks is left for the administrator to clean up.
+	ReclaimDelete ReclaimPolicy = "delete"
+)
+
+// ProvisionProfile describes the GlusterFS volumes created for volumes
+// with driver_opts.provision=true.
+type ProvisionProfile struct {
+	// Bricks are the brick roots in the "host:/path" form; each volume
+	// gets a brick in a directory named after it under every root
+	Bricks []string
+
+	// Replica is the number of replicas of each file, 0 or 1 for a
+	// distributed volume
+	Replica int
+
+	// Transport is "tcp", "rdma" or "tcp,rdma", empty for the default
+	Transport string
+
+	// Reclaim is applied when a provisioned volume is removed
+	Reclaim ReclaimPolicy
+}
+
+// Validate checks that volumes can be created from the profile.
+//
+// Returns:
+// - ValidationError if the profile is inconsistent, nil otherwise
+func (pp *ProvisionProfile) Validate() error {
+	if len(pp.Bricks) == 0 {
+		return errors.NewValidationError("provisioning profile has no bricks")
+	}
+	for _, brick := range pp.Bricks {
+		host, path, ok := strings.Cut(brick, ":")
+		if !ok || host == "" || !strings.HasPrefix(path, "/") {
+			return errors.NewValidationError(fmt.Sprintf("invalid brick %q, must be host:/path", brick))
+		}
+	}
+	if pp.Replica < 0 || (pp.Replica > 1 && len(pp.Bricks)%pp.Replica != 0) {
+		return errors.NewValidationError(fmt.Sprintf(
+			"replica %d does not divide the %d bricks of the provisioning profile", pp.Replica, len(pp.Bricks)))
+	}
+	switch pp.Transport {
+	case "", "tcp", "rdma", "tcp,rdma":
+	default:
+		return errors.NewValidationError(fmt.Sprintf("invalid transport %q, must be tcp, rdma or tcp,rdma", pp.Transport))
+	}
+	switch pp.Reclaim {
+	case ReclaimRetain, ReclaimDelete:
+	default:
+		return errors.NewValidationError(fmt.Sprintf("invalid reclaim policy %q, must be retain or delete", pp.Reclaim))
+	}
+	return nil
+}
+
+// volumeConfig describes the GlusterFS volume provisioned for name.
+func (pp *ProvisionProfile) volumeConfig(name string) *management.VolumeConfig {
+	config := &management.VolumeConfig{Name: name, Replica: pp.Replica, Transport: pp.Transport}
+	for _, root := range pp.Bricks {
+		config.Bricks = append(config.Bricks, strings.TrimSuffix(root, "/")+"/"+name)
+	}
+	return config
+}
+
+// parseProvision reads the provision option of a volume.
+//
+// Parameters:
+// - req: The volume described to its backend
+//
+// Returns:
+//   - The spec of the volume to provision, nil if provision is not set
+//     or false
+//   - ValidationError if the option is malformed or the volume is not a
+//     whole GlusterFS volume
+func parseProvision(req *backend.Request) (*backend.Spec, error) {
+	value, ok := req.Options[provisionOption]
+	if !ok {
+		return nil, nil
+	}
+	provision, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, errors.NewValidationError(fmt.Sprintf("invalid provision %q, must be true or false", value))
+	}
+	if !provision {
+		return nil, nil
+	}
+
+	if _, ok := req.Options["glusteropts"]; ok {
+		return nil, errors.NewValidationError("provision cannot be combined with glusteropts")
+	}
+	spec, err := backend.NewSpec(req)
+	if err != nil {
+		return nil, err
+	}
+	if spec.Subdir != "" {
+		return nil, errors.NewValidationError("provision creates whole volumes, subdirectories are not allowed")
+	}
+	return spec, nil
+}
+
+// glusterVolumeUsers returns the registered volumes other than name
+// that are mounted from the GlusterFS volume gv, sorted. Volumes sharing
+// another volume are users through that volume.
+func (p *GFSDriver) glusterVolumeUsers(name, gv string) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var users []string
+	for other, state := range p.volumes {
+		if other == name {
+			continue
+		}
+		if _, shared := state.request.Options[shareOption]; shared {
+			continue
+		}
+		used, _, err := backend.VolumePath(&backend.Request{Name: other, Options: state.request.Options})
+		if err == nil && used == gv {
+			users = append(users, other)
+		}
+	}
+	sort.Strings(users)
+	return users
+}
+
+// validateProvision checks that a volume can be provisioned.
+func (p *GFSDriver) validateProvision(req *volume.CreateRequest) error {
+	spec, err := parseProvision(p.backendRequest(req))
+	if err != nil || spec == nil {
+		return err
+	}
+	if p.Provision == nil {
+		return errors.NewValidationError("provision is not supported, no provisioning profile is configured")
+	}
+	if p.Management == nil {
+		return errors.NewValidationError("provision is not supported, no management client is configured")
+	}
+	return nil
+}
+
+// provisionVolume creates and starts the GlusterFS volume of a volume
+// being created. A volume that cannot be started is deleted again.
+//
+// Returns:
+// - Whether a volume was provisioned
+// - error if the volume cannot be created or started
+func (p *GFSDriver) provisionVolume(req *volume.CreateRequest) (bool, error) {
+	spec, err := parseProvision(p.backendRequest(req))
+	if err != nil || spec == nil {
+		return false, err
+	}
+	client := p.Management(spec.Servers)
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.ManagementTimeout)
+	defer cancel()
+	if err := client.CreateVolume(ctx, p.Provision.volumeConfig(spec.Volume)); err != nil {
+		return false, fmt.Errorf("failed to provision volume %s: %w", req.Name, err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), p.ManagementTimeout)
+	defer cancel()
+	if err := client.StartVolume(ctx, spec.Volume); err != nil {
+		// The rollback gets its own deadline, the start may have used up
+		// the previous one
+		deleteCtx, deleteCancel := context.WithTimeout(context.Background(), p.ManagementTimeout)
+		defer deleteCancel()
+		if deleteErr := client.DeleteVolume(deleteCtx, spec.Volume); deleteErr != nil {
+			log.Printf("warning: failed to delete volume %s after it did not start: %v", spec.Volume, deleteErr)
+		}
+		return false, fmt.Errorf("failed to start provisioned volume %s: %w", req.Name, err)
+	}
+
+	log.Printf("provisioned volume %s", spec.Volume)
+	return true, nil
+}
+
+// reclaimVolume applies the reclaim policy to the GlusterFS volume of a
+// provisioned or cloned volume being removed. Without a provisioning
+// profile the volume is retained. A GlusterFS volume that other
+// registered volumes use, such as subdirectory volumes, is not deleted.
+func (p *GFSDriver) reclaimVolume(req *volume.CreateRequest) error {
+	if p.Provision == nil || p.Provision.Reclaim != ReclaimDelete {
+		return nil
+	}
+	spec, err := backend.NewSpec(p.backendRequest(req))
+	if err != nil {
+		return err
+	}
+	if users := p.glusterVolumeUsers(req.Name, spec.Volume); len(users) > 0 {
+		return errors.NewInUseError(fmt.Sprintf(
+			"volume %s deletes GlusterFS volume %s, which volumes %s still use; remove them first",
+			req.Name, spec.Volume, strings.Join(users, ", ")), nil)
+	}
+	client := p.Management(spec.Servers)
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.ManagementTimeout)
+	defer cancel()
+	if err := client.StopVolume(ctx, spec.Volume); err != nil {
+		return fmt.Errorf("failed to stop provisioned volume %s: %w", req.Name, err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), p.ManagementTimeout)
+	defer cancel()
+	if err := client.DeleteVolume(ctx, spec.Volume); err != nil {
+		return fmt.Errorf("failed to delete provisioned volume %s: %w", req.Name, err)
+	}
+
+	log.Printf("deleted provisioned volume %s", spec.Volume)
+	return nil
+}
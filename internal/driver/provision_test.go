@@ -0,0 +1,246 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"glusterfs-plugin/internal/errors"
+	"glusterfs-plugin/internal/management"
+	"glusterfs-plugin/internal/management/managementtest"
+	"glusterfs-plugin/internal/utils"
+	"glusterfs-plugin/pkg/volume"
+)
+
+// newProvisioningDriver returns a test driver provisioning volumes on
+// three replicated bricks through a fake management client.
+func newProvisioningDriver(t *testing.T, reclaim ReclaimPolicy) (*GFSDriver, *managementtest.Fake) {
+	t.Helper()
+	d, _ := newTestDriver(t)
+	mgmt := managementtest.New()
+	d.Management = mgmt.Factory()
+	d.Provision = &ProvisionProfile{
+		Bricks:    []string{"store1:/bricks", "store2:/bricks/", "store3:/bricks"},
+		Replica:   3,
+		Transport: "tcp",
+		Reclaim:   reclaim,
+	}
+	require.NoError(t, d.Provision.Validate())
+	return d, mgmt
+}
+
+// call posts a plugin protocol request and decodes the response.
+func call(t *testing.T, h http.Handler, path, body string) map[string]interface{} {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp
+}
+
+func TestProvisionProfile_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile ProvisionProfile
+		wantErr string
+	}{
+		{name: "distributed", profile: ProvisionProfile{Bricks: []string{"store1:/bricks"}, Reclaim: ReclaimRetain}},
+		{name: "replicated", profile: ProvisionProfile{Bricks: []string{"a:/b", "b:/b"}, Replica: 2, Transport: "tcp", Reclaim: ReclaimDelete}},
+		{name: "no bricks", profile: ProvisionProfile{Reclaim: ReclaimRetain}, wantErr: "no bricks"},
+		{name: "relative brick", profile: ProvisionProfile{Bricks: []string{"store1:bricks"}, Reclaim: ReclaimRetain}, wantErr: "invalid brick"},
+		{name: "uneven replica", profile: ProvisionProfile{Bricks: []string{"a:/b", "b:/b"}, Replica: 3, Reclaim: ReclaimRetain}, wantErr: "replica 3"},
+		{name: "transport", profile: ProvisionProfile{Bricks: []string{"a:/b"}, Transport: "udp", Reclaim: ReclaimRetain}, wantErr: "invalid transport"},
+		{name: "reclaim", profile: ProvisionProfile{Bricks: []string{"a:/b"}, Reclaim: "recycle"}, wantErr: "invalid reclaim policy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.profile.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, errors.ErrValidation)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestValidate_Provision(t *testing.T) {
+	d, _ := newProvisioningDriver(t, ReclaimRetain)
+
+	assert.NoError(t, d.Validate(&volume.CreateRequest{Name: "vol1", Options: map[string]string{"provision": "true"}}))
+	assert.NoError(t, d.Validate(&volume.CreateRequest{Name: "vol1", Options: map[string]string{"provision": "false"}}))
+
+	err := d.Validate(&volume.CreateRequest{Name: "vol1/sub", Options: map[string]string{"provision": "true"}})
+	assert.ErrorContains(t, err, "subdirectories are not allowed")
+
+	err = d.Validate(&volume.CreateRequest{Name: "vol1", Options: map[string]string{"provision": "yes please"}})
+	assert.ErrorContains(t, err, "invalid provision")
+
+	d.Provision = nil
+	err = d.Validate(&volume.CreateRequest{Name: "vol1", Options: map[string]string{"provision": "true"}})
+	assert.ErrorIs(t, err, errors.ErrValidation)
+	assert.ErrorContains(t, err, "no provisioning profile")
+}
+
+func TestRemove_ReclaimKeepsUsedVolume(t *testing.T) {
+	d, mgmt := newProvisioningDriver(t, ReclaimDelete)
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{"provision": "true"}}))
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol1/team-a", Options: map[string]string{}}))
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "team-b", Options: map[string]string{"subdir": "vol1/team-b"}}))
+
+	// Subdirectory volumes still use the GlusterFS volume
+	err := d.Remove("vol1")
+	assert.ErrorIs(t, err, errors.ErrInUse)
+	assert.ErrorContains(t, err, "which volumes team-b, vol1/team-a still use")
+	_, err = mgmt.Volume(context.Background(), "vol1")
+	require.NoError(t, err)
+	_, err = d.Get("vol1")
+	require.NoError(t, err)
+
+	require.NoError(t, d.Remove("vol1/team-a"))
+	require.NoError(t, d.Remove("team-b"))
+	require.NoError(t, d.Remove("vol1"))
+	_, err = mgmt.Volume(context.Background(), "vol1")
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+}
+
+func TestProvision_EndToEnd(t *testing.T) {
+	d, mgmt := newProvisioningDriver(t, ReclaimDelete)
+	h := utils.NewHandler(d)
+
+	resp := call(t, h, "/VolumeDriver.Create", `{"Name":"vol1","Opts":{"provision":"true"}}`)
+	assert.Equal(t, "", resp["Err"])
+
+	v, err := mgmt.Volume(context.Background(), "vol1")
+	require.NoError(t, err)
+	assert.True(t, v.Started())
+	assert.Equal(t, []string{"store1:/bricks/vol1", "store2:/bricks/vol1", "store3:/bricks/vol1"}, v.Bricks)
+	assert.Equal(t, 3, v.ReplicaCount)
+
+	resp = call(t, h, "/VolumeDriver.Get", `{"Name":"vol1"}`)
+	assert.Equal(t, true, resp["Volume"].(map[string]interface{})["Status"].(map[string]interface{})["provisioned"])
+
+	resp = call(t, h, "/VolumeDriver.Mount", `{"Name":"vol1","ID":"c1"}`)
+	require.Equal(t, "", resp["Err"])
+
+	// A mounted volume is not reclaimed
+	resp = call(t, h, "/VolumeDriver.Remove", `{"Name":"vol1"}`)
+	assert.Contains(t, resp["Err"], "IN_USE")
+
+	resp = call(t, h, "/VolumeDriver.Unmount", `{"Name":"vol1","ID":"c1"}`)
+	require.Equal(t, "", resp["Err"])
+	resp = call(t, h, "/VolumeDriver.Remove", `{"Name":"vol1"}`)
+	assert.Equal(t, "", resp["Err"])
+
+	_, err = mgmt.Volume(context.Background(), "vol1")
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+	assert.Equal(t, []string{
+		"create vol1 replica=3 transport=tcp store1:/bricks/vol1 store2:/bricks/vol1 store3:/bricks/vol1",
+		"start vol1",
+		"stop vol1",
+		"delete vol1",
+	}, lifecycleCalls(mgmt.Calls()))
+}
+
+func TestProvision_RetainKeepsVolume(t *testing.T) {
+	d, mgmt := newProvisioningDriver(t, ReclaimRetain)
+	h := utils.NewHandler(d)
+
+	require.Equal(t, "", call(t, h, "/VolumeDriver.Create", `{"Name":"vol1","Opts":{"provision":"true"}}`)["Err"])
+	require.Equal(t, "", call(t, h, "/VolumeDriver.Remove", `{"Name":"vol1"}`)["Err"])
+
+	v, err := mgmt.Volume(context.Background(), "vol1")
+	require.NoError(t, err)
+	assert.True(t, v.Started())
+}
+
+func TestProvision_ExistingVolume(t *testing.T) {
+	d, _ := newProvisioningDriver(t, ReclaimDelete)
+	h := utils.NewHandler(d)
+
+	require.Equal(t, "", call(t, h, "/VolumeDriver.Create", `{"Name":"vol1","Opts":{"provision":"true"}}`)["Err"])
+	require.Equal(t, "", call(t, h, "/VolumeDriver.Remove", `{"Name":"vol1"}`)["Err"])
+
+	require.Equal(t, "", call(t, h, "/VolumeDriver.Create", `{"Name":"vol2","Opts":{"provision":"true"}}`)["Err"])
+	d.mu.Lock()
+	delete(d.volumes, "vol2")
+	d.mu.Unlock()
+
+	// The GlusterFS volume of a forgotten volume is not adopted
+	resp := call(t, h, "/VolumeDriver.Create", `{"Name":"vol2","Opts":{"provision":"true"}}`)
+	assert.Contains(t, resp["Err"], "ALREADY_EXISTS")
+}
+
+func TestProvision_StartFailureDeletesVolume(t *testing.T) {
+	d, mgmt := newProvisioningDriver(t, ReclaimRetain)
+	mgmt.StartErr = fmt.Errorf("brick store2:/bricks/vol1 is not connected")
+
+	err := d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{"provision": "true"}})
+	assert.EqualError(t, err, "failed to start provisioned volume vol1: brick store2:/bricks/vol1 is not connected")
+
+	_, err = mgmt.Volume(context.Background(), "vol1")
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+	_, err = d.Get("vol1")
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+}
+
+// hangingDelete is a management client whose DeleteVolume hangs until
+// its context is done, like an unreachable glusterd.
+type hangingDelete struct {
+	*managementtest.Fake
+}
+
+func (h hangingDelete) DeleteVolume(ctx context.Context, name string) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestProvision_StartFailureRollbackIsBounded(t *testing.T) {
+	d, mgmt := newProvisioningDriver(t, ReclaimRetain)
+	d.Management = func(servers []string) management.Client { return hangingDelete{mgmt} }
+	d.ManagementTimeout = 50 * time.Millisecond
+	mgmt.StartErr = fmt.Errorf("brick store2:/bricks/vol1 is not connected")
+
+	start := time.Now()
+	err := d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{"provision": "true"}})
+	assert.EqualError(t, err, "failed to start provisioned volume vol1: brick store2:/bricks/vol1 is not connected")
+	assert.Less(t, time.Since(start), 10*time.Second)
+}
+
+func TestProvision_ReclaimFailureKeepsVolume(t *testing.T) {
+	d, mgmt := newProvisioningDriver(t, ReclaimDelete)
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{"provision": "true"}}))
+
+	mgmt.DeleteErr = fmt.Errorf("another transaction is in progress")
+	err := d.Remove("vol1")
+	assert.EqualError(t, err, "failed to delete provisioned volume vol1: another transaction is in progress")
+
+	// The volume stays registered so that the removal can be retried
+	_, err = d.Get("vol1")
+	require.NoError(t, err)
+
+	mgmt.DeleteErr = nil
+	require.NoError(t, d.Remove("vol1"))
+}
+
+// lifecycleCalls drops the volume lookups made by the test itself.
+func lifecycleCalls(calls []string) []string {
+	var kept []string
+	for _, c := range calls {
+		if !strings.HasPrefix(c, "volume ") {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,186 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"glusterfs-plugin/internal/backend"
+	"glusterfs-plugin/internal/errors"
+	"glusterfs-plugin/internal/management"
+	"glusterfs-plugin/pkg/volume"
+)
+
+const (
+	// sizeOption limits a subdirectory volume with a directory quota.
+	sizeOption = "size"
+
+	// strictOption refuses to mount a volume that exceeded its quota.
+	strictOption = "strict"
+)
+
+// quota is the directory quota requested through driver_opts size and strict.
+type quota struct {
+	// spec locates the quota directory and the servers managing it
+	spec *backend.Spec
+
+	// limit is the hard limit in bytes
+	limit int64
+
+	// strict refuses mounts once the limit is reached
+	strict bool
+}
+
+// path is the quota directory relative to the volume root.
+func (q *quota) path() string {
+	return "/" + q.spec.Subdir
+}
+
+// parseQuota reads the size and strict options of a volume.
+//
+// Parameters:
+// - req: The volume described to its backend
+//
+// Returns:
+//   - The requested quota, nil if size is not set
+//   - ValidationError if an option is malformed or the volume is not a
+//     subdirectory of a GlusterFS volume
+func parseQuota(req *backend.Request) (*quota, error) {
+	size, ok := req.Options[sizeOption]
+	if !ok {
+		if _, ok := req.Options[strictOption]; ok {
+			return nil, errors.NewValidationError("strict requires size")
+		}
+		return nil, nil
+	}
+
+	limit, err := management.ParseSize(size)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &quota{limit: limit}
+	if value, ok := req.Options[strictOption]; ok {
+		if q.strict, err = strconv.ParseBool(value); err != nil {
+			return nil, errors.NewValidationError(fmt.Sprintf("invalid strict %q, must be true or false", value))
+		}
+	}
+
+	if _, ok := req.Options["glusteropts"]; ok {
+		return nil, errors.NewValidationError("size cannot be combined with glusteropts")
+	}
+	if q.spec, err = backend.NewSpec(req); err != nil {
+		return nil, err
+	}
+	if q.spec.Subdir == "" {
+		return nil, errors.NewValidationError("size can only be applied to subdirectory volumes such as volume/subdir")
+	}
+	return q, nil
+}
+
+// managementClient returns a client for the servers managing a quota.
+func (p *GFSDriver) managementClient(q *quota) (management.Client, error) {
+	if p.Management == nil {
+		return nil, errors.NewValidationError("size is not supported, no management client is configured")
+	}
+	return p.Management(q.spec.Servers), nil
+}
+
+// applyQuota sets the directory quota of a volume being created.
+func (p *GFSDriver) applyQuota(req *volume.CreateRequest) error {
+	q, err := parseQuota(p.backendRequest(req))
+	if err != nil || q == nil {
+		return err
+	}
+	client, err := p.managementClient(q)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.ManagementTimeout)
+	defer cancel()
+
+	if err := client.SetQuota(ctx, q.spec.Volume, q.path(), q.limit); err != nil {
+		// GlusterFS only sets quotas on existing directories
+		if msg := err.Error(); strings.Contains(msg, "does not exist") || strings.Contains(msg, "No such file or directory") {
+			return fmt.Errorf("failed to set quota of volume %s: %w; create %s on volume %s first", req.Name, err, q.path(), q.spec.Volume)
+		}
+		return fmt.Errorf("failed to set quota of volume %s: %w", req.Name, err)
+	}
+	p.status.Delete(quotaStatusKey(req.Name))
+	return nil
+}
+
+// checkQuota refuses to mount a strict volume that reached its quota.
+// The mount is also refused if the usage cannot be read.
+func (p *GFSDriver) checkQuota(ctx context.Context, req *volume.CreateRequest) error {
+	q, err := parseQuota(p.backendRequest(req))
+	if err != nil || q == nil || !q.strict {
+		return err
+	}
+
+	usage, err := p.quotaUsage(ctx, q)
+	if err != nil {
+		return fmt.Errorf("failed to check quota of volume %s: %w", req.Name, err)
+	}
+	if usage.Exceeded() {
+		return errors.NewQuotaExceededError(fmt.Sprintf("volume %s uses %s of %s",
+			req.Name, management.FormatSize(usage.Used), management.FormatSize(usage.Limit)), nil)
+	}
+	return nil
+}
+
+// quotaUsage reads the usage of a quota, bounded by ManagementTimeout.
+func (p *GFSDriver) quotaUsage(ctx context.Context, q *quota) (*management.Quota, error) {
+	client, err := p.managementClient(q)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.ManagementTimeout)
+	defer cancel()
+	return client.Quota(ctx, q.spec.Volume, q.path())
+}
+
+// quotaResult is a quota usage read for the status of a volume.
+type quotaResult struct {
+	usage *management.Quota
+	err   error
+}
+
+// quotaStatusKey is the status cache key of the quota usage of a volume.
+func quotaStatusKey(name string) string {
+	return "quota/" + name
+}
+
+// quotaStatus adds the quota usage of a volume to its status.
+// Docker reads the status on hot paths such as container creation, so
+// the usage is read within StatusTimeout and reused for statusCacheTTL,
+// failures included.
+func (p *GFSDriver) quotaStatus(req *volume.CreateRequest, status map[string]interface{}) {
+	q, err := parseQuota(p.backendRequest(req))
+	if err != nil || q == nil {
+		return
+	}
+
+	key := quotaStatusKey(req.Name)
+	cached, ok := p.status.Get(key)
+	result, _ := cached.(quotaResult)
+	if !ok {
+		ctx, cancel := context.WithTimeout(context.Background(), p.StatusTimeout)
+		result.usage, result.err = p.quotaUsage(ctx, q)
+		cancel()
+		p.status.Set(key, result)
+	}
+
+	if result.err != nil {
+		status["quotaError"] = result.err.Error()
+		return
+	}
+	status["quota"] = map[string]interface{}{
+		"limit":    management.FormatSize(result.usage.Limit),
+		"used":     management.FormatSize(result.usage.Used),
+		"exceeded": result.usage.Exceeded(),
+	}
+}
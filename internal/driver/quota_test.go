@@ -0,0 +1,168 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"glusterfs-plugin/internal/errors"
+	"glusterfs-plugin/internal/management/managementtest"
+	"glusterfs-plugin/internal/utils"
+	"glusterfs-plugin/pkg/volume"
+)
+
+func TestValidate_Quota(t *testing.T) {
+	d := NewDriver(nil)
+
+	tests := []struct {
+		name    string
+		volume  string
+		options map[string]string
+		wantErr string
+	}{
+		{name: "subdir with size", volume: "shared/team-a", options: map[string]string{"servers": "store1", "size": "10G"}},
+		{name: "strict", volume: "shared/team-a", options: map[string]string{"servers": "store1", "size": "10G", "strict": "true"}},
+		{name: "whole volume", volume: "shared", options: map[string]string{"servers": "store1", "size": "10G"}, wantErr: "subdirectory volumes"},
+		{name: "invalid size", volume: "shared/team-a", options: map[string]string{"servers": "store1", "size": "lots"}, wantErr: "invalid size"},
+		{name: "invalid strict", volume: "shared/team-a", options: map[string]string{"servers": "store1", "size": "1G", "strict": "always"}, wantErr: "invalid strict"},
+		{name: "strict without size", volume: "shared/team-a", options: map[string]string{"servers": "store1", "strict": "true"}, wantErr: "strict requires size"},
+		{name: "glusteropts", volume: "shared/team-a", options: map[string]string{"glusteropts": "-s store1", "size": "1G"}, wantErr: "glusteropts"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := d.Validate(&volume.CreateRequest{Name: tt.volume, Options: tt.options})
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, errors.ErrValidation)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+
+	d.Management = nil
+	err := d.Validate(&volume.CreateRequest{Name: "shared/team-a", Options: map[string]string{"servers": "store1", "size": "1G"}})
+	assert.ErrorContains(t, err, "no management client")
+}
+
+func TestCreate_SetsQuota(t *testing.T) {
+	d, _ := newTestDriver(t)
+	mgmt := managementtest.New()
+	d.Management = mgmt.Factory()
+
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "shared/team-a", Options: map[string]string{"size": "10G"}}))
+	assert.Equal(t, []string{"set-quota shared /team-a 10737418240"}, mgmt.Calls())
+
+	mgmt.SetUsed("shared", "/team-a", 1536<<20)
+	v, err := d.Get("shared/team-a")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"limit": "10G", "used": "1.5G", "exceeded": false}, v.Status["quota"])
+}
+
+func TestCreate_QuotaFailure(t *testing.T) {
+	d, _ := newTestDriver(t)
+	mgmt := managementtest.New()
+	mgmt.SetQuotaErr = fmt.Errorf("Path /team-a does not exist")
+	d.Management = mgmt.Factory()
+
+	err := d.Create(&volume.CreateRequest{Name: "shared/team-a", Options: map[string]string{"size": "10G"}})
+	assert.EqualError(t, err, "failed to set quota of volume shared/team-a: Path /team-a does not exist; create /team-a on volume shared first")
+
+	_, err = d.Get("shared/team-a")
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+}
+
+func TestGet_QuotaError(t *testing.T) {
+	d, _ := newTestDriver(t)
+	mgmt := managementtest.New()
+	d.Management = mgmt.Factory()
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "shared/team-a", Options: map[string]string{"size": "10G"}}))
+
+	mgmt.QuotaErr = errors.NewServerUnreachableError("glusterd on server1", nil)
+	v, err := d.Get("shared/team-a")
+	require.NoError(t, err)
+	assert.NotContains(t, v.Status, "quota")
+	assert.Equal(t, "server unreachable error: glusterd on server1", v.Status["quotaError"])
+}
+
+func TestGet_QuotaStatusCached(t *testing.T) {
+	d, _ := newTestDriver(t)
+	mgmt := managementtest.New()
+	d.Management = mgmt.Factory()
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "shared/team-a", Options: map[string]string{"size": "10G"}}))
+	mgmt.SetUsed("shared", "/team-a", 1<<30)
+
+	v, err := d.Get("shared/team-a")
+	require.NoError(t, err)
+	assert.Equal(t, "1G", v.Status["quota"].(map[string]interface{})["used"])
+
+	// The usage is reused until the cache expires, failures as well
+	mgmt.SetUsed("shared", "/team-a", 2<<30)
+	v, err = d.Get("shared/team-a")
+	require.NoError(t, err)
+	assert.Equal(t, "1G", v.Status["quota"].(map[string]interface{})["used"])
+
+	d.status = utils.NewTTLCache(0)
+	v, err = d.Get("shared/team-a")
+	require.NoError(t, err)
+	assert.Equal(t, "2G", v.Status["quota"].(map[string]interface{})["used"])
+}
+
+func TestGet_QuotaStatusTimeout(t *testing.T) {
+	d, _ := newTestDriver(t)
+	mgmt := managementtest.New()
+	d.Management = mgmt.Factory()
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "shared/team-a", Options: map[string]string{"size": "10G"}}))
+
+	mgmt.Delay = time.Minute
+	d.StatusTimeout = 50 * time.Millisecond
+	start := time.Now()
+	v, err := d.Get("shared/team-a")
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), 10*time.Second)
+	assert.Contains(t, v.Status["quotaError"], "deadline exceeded")
+}
+
+func TestMount_StrictQuota(t *testing.T) {
+	d, fake := newTestDriver(t)
+	mgmt := managementtest.New()
+	d.Management = mgmt.Factory()
+
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "shared/strict", Options: map[string]string{"size": "1G", "strict": "true"}}))
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "shared/lenient", Options: map[string]string{"size": "1G"}}))
+	mgmt.SetUsed("shared", "/strict", 1<<30)
+	mgmt.SetUsed("shared", "/lenient", 1<<30)
+
+	_, err := d.Mount(context.Background(), &volume.MountRequest{Name: "shared/strict"})
+	assert.ErrorIs(t, err, errors.ErrQuotaExceeded)
+	assert.EqualError(t, err, "quota exceeded error: volume shared/strict uses 1G of 1G")
+	assert.Equal(t, 0, fake.MountCount())
+
+	// Without strict the volume is still mounted
+	_, err = d.Mount(context.Background(), &volume.MountRequest{Name: "shared/lenient"})
+	require.NoError(t, err)
+
+	// Once space is freed the strict volume mounts again
+	mgmt.SetUsed("shared", "/strict", 512<<20)
+	_, err = d.Mount(context.Background(), &volume.MountRequest{Name: "shared/strict"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, fake.MountCount())
+}
+
+func TestMount_StrictQuotaUnknownUsage(t *testing.T) {
+	d, fake := newTestDriver(t)
+	mgmt := managementtest.New()
+	d.Management = mgmt.Factory()
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "shared/team-a", Options: map[string]string{"size": "1G", "strict": "true"}}))
+
+	mgmt.QuotaErr = errors.NewServerUnreachableError("glusterd on server1", nil)
+	_, err := d.Mount(context.Background(), &volume.MountRequest{Name: "shared/team-a"})
+	assert.ErrorIs(t, err, errors.ErrServerUnreachable)
+	assert.ErrorContains(t, err, "failed to check quota of volume shared/team-a")
+	assert.Equal(t, 0, fake.MountCount())
+}
@@ -0,0 +1,589 @@
+package driver
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"glusterfs-plugin/internal/backend"
+	"glusterfs-plugin/internal/errors"
+	"glusterfs-plugin/pkg/volume"
+)
+
+const (
+	// clientLogTailLines is the number of client log lines attached to
+	// mount errors and reported in the volume status.
+	clientLogTailLines = 20
+
+	// DefaultMountTimeout bounds a mount when MOUNT_TIMEOUT is not set.
+	DefaultMountTimeout = 60 * time.Second
+
+	// DefaultUnmountTimeout bounds an unmount when UNMOUNT_TIMEOUT is not set.
+	DefaultUnmountTimeout = 30 * time.Second
+
+	// DefaultManagementTimeout bounds a management operation such as
+	// setting a quota.
+	DefaultManagementTimeout = 30 * time.Second
+
+	// DefaultStatusTimeout bounds a cluster query made for the status of
+	// volumes, such as the usage of a quota.
+	DefaultStatusTimeout = 5 * time.Second
+
+	// statusCacheTTL is how long the answers of status queries are
+	// reused.
+	statusCacheTTL = 10 * time.Second
+)
+
+// volumeState holds the runtime state of a volume known to the driver.
+type volumeState struct {
+	// request is the create request the volume was registered with
+	request *volume.CreateRequest
+
+	// backend mounts the volume
+	backend backend.Backend
+
+	// provisioned is set if the GlusterFS volume was created for it
+	provisioned bool
+
+	// lineage records the origin of cloned volumes
+	lineage *lineage
+
+	// mountpoint is where the volume is mounted while refs > 0
+	mountpoint string
+
+	// servers are the plugin servers the mount was built from, nil if
+	// the volume names its own servers or shares another volume
+	servers []string
+
+	// refs counts the active mounts of the volume
+	refs int
+
+	// ids counts the active mounts by the ID Docker mounted them with,
+	// so that Unmount only releases mounts it was given
+	ids map[string]int
+}
+
+// Create registers a new volume after validating the request.
+// Subdirectory volumes with driver_opts.size get a directory quota,
+// volumes with driver_opts.provision=true get a new GlusterFS volume and
+// volumes with driver_opts.from-snapshot or clone-of get a clone.
+// Creating a registered volume again with the same options succeeds
+// without doing anything. Like all lifecycle operations, it is
+// serialized with other operations on the same volume.
+//
+// Parameters:
+// - req: The create request for the volume
+//
+// Returns:
+//   - error if the request is invalid, the volume exists with other
+//     options, the quota cannot be set or the volume cannot be
+//     provisioned, nil otherwise
+func (p *GFSDriver) Create(req *volume.CreateRequest) error {
+	unlock := p.locks.Lock(req.Name)
+	defer unlock()
+
+	// Options are compared once they are valid, normalizing them must
+	// not make an invalid request equal to the registered one
+	if err := p.Validate(req); err != nil {
+		return err
+	}
+	b, err := p.backendFor(req.Options)
+	if err != nil {
+		return err
+	}
+	if exists, err := p.existingVolume(req); exists || err != nil {
+		return err
+	}
+	provisioned, err := p.provisionVolume(req)
+	if err != nil {
+		return err
+	}
+	origin, err := p.cloneVolume(req)
+	if err != nil {
+		return err
+	}
+	if err := p.applyQuota(req); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.volumes[req.Name] = &volumeState{
+		request:     req,
+		backend:     b,
+		provisioned: provisioned || origin != nil,
+		lineage:     origin,
+	}
+	return nil
+}
+
+// existingVolume checks a create request against the registered volume
+// of the same name, if any. Docker creates volumes again when a stack is
+// redeployed, so equivalent options, compared in normalized form, are
+// accepted without registering anything; other options are refused
+// rather than changing how the volume is mounted.
+//
+// Parameters:
+// - req: The create request for the volume
+//
+// Returns:
+//   - Whether the volume is registered
+//   - AlreadyExistsError listing the differences if it is registered with
+//     other options
+func (p *GFSDriver) existingVolume(req *volume.CreateRequest) (bool, error) {
+	p.mu.Lock()
+	state, ok := p.volumes[req.Name]
+	p.mu.Unlock()
+
+	if !ok {
+		return false, nil
+	}
+	if diff := optionDiff(state.request.Options, req.Options); len(diff) > 0 {
+		return true, errors.NewAlreadyExistsError(fmt.Sprintf(
+			"volume %s already exists with other options (%s)", req.Name, strings.Join(diff, ", ")), nil)
+	}
+	return true, nil
+}
+
+// Remove unregisters a volume and discards its client log.
+// Volumes that are still mounted or shared by registered volumes cannot
+// be removed. The GlusterFS volume of a provisioned volume is deleted if
+// the reclaim policy says so.
+//
+// Parameters:
+// - name: The name of the volume
+//
+// Returns:
+// - error if the volume does not exist, is in use or cannot be reclaimed
+func (p *GFSDriver) Remove(name string) error {
+	unlock := p.locks.Lock(name)
+	defer unlock()
+
+	p.mu.Lock()
+	state, ok := p.volumes[name]
+	var refs int
+	var sharers []string
+	if ok {
+		refs = state.refs
+		sharers = p.sharersOf(name)
+	}
+	p.mu.Unlock()
+
+	if !ok {
+		return errors.NewNotFoundError(fmt.Sprintf("volume %s does not exist", name), nil)
+	}
+	if refs > 0 {
+		return errors.NewInUseError(fmt.Sprintf("volume %s is mounted %d time(s)", name, refs), nil)
+	}
+	if len(sharers) > 0 {
+		return errors.NewInUseError(fmt.Sprintf(
+			"volume %s is shared by volumes %s, remove them first", name, strings.Join(sharers, ", ")), nil)
+	}
+	if state.provisioned {
+		if err := p.reclaimVolume(state.request); err != nil {
+			return err
+		}
+		// The deleted volume must not come back as discovered
+		p.status.Delete(discoverStatusKey(p.servers()))
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.volumes, name)
+	p.Logs.Reset(name)
+	p.status.Delete(quotaStatusKey(name))
+	return nil
+}
+
+// sharersOf returns the registered volumes sharing a volume, sorted.
+// The caller holds p.mu.
+func (p *GFSDriver) sharersOf(name string) []string {
+	var sharers []string
+	for sharer, state := range p.volumes {
+		if state.request.Options[shareOption] == name {
+			sharers = append(sharers, sharer)
+		}
+	}
+	sort.Strings(sharers)
+	return sharers
+}
+
+// Get returns the volume with the given name and its status.
+// The status reports whether the volume is mounted and read-only, the
+// volume it shares if any, whether its GlusterFS volume was provisioned,
+// the lineage of clones, the usage of its quota if it has one, and the
+// tail of the glusterfs client log, if any was captured.
+// With Discover set, volumes of the cluster that are not registered are
+// returned with a status marking them as discovered.
+//
+// Parameters:
+// - name: The name of the volume
+//
+// Returns:
+// - The volume
+// - error if the volume does not exist
+func (p *GFSDriver) Get(name string) (*volume.Volume, error) {
+	p.mu.Lock()
+	state, ok := p.volumes[name]
+	var mountpoint string
+	var mounted bool
+	var request *volume.CreateRequest
+	var provisioned bool
+	var origin *lineage
+	if ok {
+		mountpoint, mounted = state.mountpoint, state.refs > 0
+		request, provisioned, origin = state.request, state.provisioned, state.lineage
+	}
+	p.mu.Unlock()
+
+	if !ok {
+		return p.getDiscovered(name)
+	}
+
+	options := request.Options
+	readOnly, _ := backend.ReadOnly(options)
+	status := map[string]interface{}{
+		"mounted":  mounted,
+		"readOnly": readOnly,
+	}
+	if source := options[shareOption]; source != "" {
+		status["sharedFrom"] = source
+	}
+	if provisioned {
+		status["provisioned"] = true
+	}
+	if origin != nil {
+		status["lineage"] = origin.status()
+	}
+	p.quotaStatus(request, status)
+	if lines := p.Logs.Tail(name, clientLogTailLines); len(lines) > 0 {
+		status["clientLog"] = lines
+	}
+
+	return &volume.Volume{
+		Name:       name,
+		Mountpoint: mountpoint,
+		Status:     status,
+	}, nil
+}
+
+// List returns all registered volumes, sorted by name.
+// With Discover set, the volumes of the cluster that are not registered
+// are listed too, with a status marking them as discovered. Otherwise
+// status is only reported by Get.
+//
+// Returns:
+// - The registered and discovered volumes
+// - error is always nil
+func (p *GFSDriver) List() ([]*volume.Volume, error) {
+	p.mu.Lock()
+	volumes := make([]*volume.Volume, 0, len(p.volumes))
+	registered := make(map[string]bool, len(p.volumes))
+	for name, state := range p.volumes {
+		volumes = append(volumes, &volume.Volume{Name: name, Mountpoint: state.mountpoint})
+		registered[name] = true
+	}
+	p.mu.Unlock()
+
+	volumes = append(volumes, p.discoverVolumes(registered)...)
+	sort.Slice(volumes, func(i, j int) bool { return volumes[i].Name < volumes[j].Name })
+	return volumes, nil
+}
+
+// Path returns the mount point of a volume.
+//
+// Parameters:
+// - name: The name of the volume
+//
+// Returns:
+// - The mount point, empty if the volume is not mounted
+// - error if the volume does not exist
+func (p *GFSDriver) Path(name string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	state, ok := p.volumes[name]
+	if !ok {
+		return "", errors.NewNotFoundError(fmt.Sprintf("volume %s does not exist", name), nil)
+	}
+	return state.mountpoint, nil
+}
+
+// Capabilities reports the capabilities of the driver.
+// GlusterFS volumes are reachable from every node of the cluster,
+// so they are global.
+//
+// Returns:
+// - The driver capabilities
+func (p *GFSDriver) Capabilities() volume.Capability {
+	return volume.Capability{Scope: "global"}
+}
+
+// Mount mounts a registered volume, starting the glusterfs client on the
+// first mount and counting further mounts.
+// If the client fails, the returned MountError carries the last lines
+// of the client log. The mount is bounded by MountTimeout; when it times
+// out or the context is cancelled, the backend aborts the mount and
+// cleans up after it.
+// Volumes sharing another volume mount it and bind its mount point instead.
+// Discovered volumes are not mounted until they are created.
+// Volumes with driver_opts.strict=true are not mounted once their quota
+// is exceeded.
+//
+// Parameters:
+//   - ctx: The context of the originating request
+//   - req: The mount request; its mount point is ignored, volumes are
+//     mounted at a directory below Root derived from the volume name and
+//     options, which is removed again after the last unmount
+//
+// Returns:
+// - The mount point of the volume
+// - error if the volume is unknown or cannot be mounted
+func (p *GFSDriver) Mount(ctx context.Context, req *volume.MountRequest) (string, error) {
+	if req == nil {
+		return "", errors.NewMountError("mount request cannot be nil", nil)
+	}
+
+	unlock := p.locks.Lock(req.Name)
+	defer unlock()
+
+	p.mu.Lock()
+	state, ok := p.volumes[req.Name]
+	if ok && state.refs > 0 {
+		state.addRef(req.ID)
+		mountpoint := state.mountpoint
+		p.mu.Unlock()
+		return mountpoint, nil
+	}
+	p.mu.Unlock()
+
+	if !ok {
+		return "", p.discoveredMountError(req.Name)
+	}
+
+	// The mount point is always a directory of Root: a mount point in
+	// the request would let any client of the socket mount anywhere
+	mountpoint := p.mountpointFor(state.request)
+	if err := p.createMountpoint(mountpoint); err != nil {
+		return "", err
+	}
+	mounted := false
+	defer func() {
+		if !mounted {
+			p.removeMountpoint(mountpoint)
+		}
+	}()
+
+	mountReq := &volume.MountRequest{Name: req.Name, Mountpoint: mountpoint}
+	if err := p.PreMount(mountReq); err != nil {
+		return "", err
+	}
+	if err := p.checkQuota(ctx, state.request); err != nil {
+		return "", err
+	}
+
+	var err error
+	if source := state.request.Options[shareOption]; source != "" {
+		err = p.mountShared(ctx, source, req.Name, mountpoint)
+	} else {
+		err = p.mountBackend(ctx, state, req.Name, mountpoint)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := p.PostMount(mountReq); err != nil {
+		// Do not leave a mount behind that the caller was told failed
+		if undoErr := p.unmountVolume(context.Background(), state, req.Name, mountpoint); undoErr != nil {
+			log.Printf("warning: failed to undo mount of volume %s: %v", req.Name, undoErr)
+		}
+		return "", err
+	}
+
+	p.mu.Lock()
+	state.mountpoint = mountpoint
+	state.addRef(req.ID)
+	p.mu.Unlock()
+
+	mounted = true
+	return mountpoint, nil
+}
+
+// Unmount releases a mount of the volume and unmounts it once no
+// mounts are left. Mounts are paired by ID: a request with an ID the
+// volume is not mounted with, such as a repeated Unmount, releases
+// nothing. The unmount is bounded by UnmountTimeout.
+//
+// Parameters:
+// - ctx: The context of the originating request
+// - req: The unmount request
+//
+// Returns:
+// - error if the volume is not mounted with the ID or cannot be unmounted
+func (p *GFSDriver) Unmount(ctx context.Context, req *volume.MountRequest) error {
+	if req == nil {
+		return errors.NewMountError("unmount request cannot be nil", nil)
+	}
+
+	unlock := p.locks.Lock(req.Name)
+	defer unlock()
+
+	p.mu.Lock()
+	state, ok := p.volumes[req.Name]
+	if !ok || state.refs == 0 {
+		p.mu.Unlock()
+		return errors.NewMountError(fmt.Sprintf("volume %s is not mounted", req.Name), nil)
+	}
+	if state.ids[req.ID] == 0 {
+		p.mu.Unlock()
+		return errors.NewMountError(fmt.Sprintf("volume %s is not mounted with ID %q", req.Name, req.ID), nil)
+	}
+	if state.refs > 1 {
+		state.releaseRef(req.ID)
+		p.mu.Unlock()
+		return nil
+	}
+	mountpoint := state.mountpoint
+	p.mu.Unlock()
+
+	if err := p.unmountVolume(ctx, state, req.Name, mountpoint); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	state.dropRefs()
+	p.mu.Unlock()
+	p.removeMountpoint(mountpoint)
+
+	log.Printf("successfully unmounted volume %s from %s", req.Name, mountpoint)
+	return nil
+}
+
+// addRef counts a mount of the volume with the given ID. The caller
+// holds p.mu.
+func (s *volumeState) addRef(id string) {
+	if s.ids == nil {
+		s.ids = map[string]int{}
+	}
+	s.ids[id]++
+	s.refs++
+}
+
+// releaseRef releases a mount of the volume with the given ID. The
+// caller holds p.mu and checks that the volume is mounted with it.
+func (s *volumeState) releaseRef(id string) {
+	if s.ids[id]--; s.ids[id] == 0 {
+		delete(s.ids, id)
+	}
+	s.refs--
+}
+
+// dropRefs forgets every mount of the volume once it is unmounted. The
+// caller holds p.mu.
+func (s *volumeState) dropRefs() {
+	s.refs = 0
+	s.ids = nil
+	s.mountpoint = ""
+}
+
+// unmountVolume unmounts a volume, either through its backend or by
+// releasing the volume it shares.
+func (p *GFSDriver) unmountVolume(ctx context.Context, state *volumeState, name, mountpoint string) error {
+	if source := state.request.Options[shareOption]; source != "" {
+		return p.unmountShared(ctx, source, name, mountpoint)
+	}
+	return p.unmountBackend(ctx, state, name, mountpoint)
+}
+
+// mountBackend mounts a volume through its backend.
+func (p *GFSDriver) mountBackend(ctx context.Context, state *volumeState, name, mountpoint string) error {
+	// Start from a clean log so errors only show this attempt
+	p.Logs.Reset(name)
+	p.Mounts.Register(mountpoint, name)
+
+	ctx, cancel := context.WithTimeout(ctx, p.MountTimeout)
+	defer cancel()
+
+	obs := backend.Observer{
+		Started: func(pid int) { p.Mounts.TrackPID(pid, mountpoint) },
+		Output:  func(line string) { p.Logs.Append(name, line) },
+	}
+	breq := p.backendRequest(state.request)
+	if err := state.backend.Mount(ctx, state.backend.MountArgs(breq), mountpoint, obs); err != nil {
+		p.Mounts.Unregister(mountpoint)
+		if stderrors.Is(err, context.DeadlineExceeded) {
+			return errors.NewTimeoutError(
+				fmt.Sprintf("mounting volume %s did not finish within %s", name, p.MountTimeout),
+				err,
+			)
+		}
+		return errors.NewMountError(
+			fmt.Sprintf("failed to mount volume %s at %s", name, mountpoint),
+			err,
+		).WithClientLog(p.Logs.Tail(name, clientLogTailLines))
+	}
+
+	// Remember the plugin servers to tell when a reload makes them stale
+	var servers []string
+	if backend.ServersSource(breq) == backend.ServersFromPlugin {
+		servers = breq.Servers
+	}
+	p.mu.Lock()
+	state.servers = servers
+	p.mu.Unlock()
+	return nil
+}
+
+// unmountBackend unmounts a volume through its backend.
+func (p *GFSDriver) unmountBackend(ctx context.Context, state *volumeState, name, mountpoint string) error {
+	ctx, cancel := context.WithTimeout(ctx, p.UnmountTimeout)
+	defer cancel()
+
+	if err := state.backend.Unmount(ctx, mountpoint); err != nil {
+		if stderrors.Is(err, context.DeadlineExceeded) {
+			return errors.NewTimeoutError(
+				fmt.Sprintf("unmounting volume %s did not finish within %s", name, p.UnmountTimeout),
+				err,
+			)
+		}
+		return errors.NewMountError(fmt.Sprintf("failed to unmount volume %s", name), err)
+	}
+	p.Mounts.Unregister(mountpoint)
+	return nil
+}
+
+// mountShared mounts the shared source volume, or takes another reference
+// on it, and binds its mount point read-only at mountpoint.
+func (p *GFSDriver) mountShared(ctx context.Context, source, name, mountpoint string) error {
+	sourceReq := &volume.MountRequest{Name: source, ID: shareMountID(name)}
+	sourceMountpoint, err := p.Mount(ctx, sourceReq)
+	if err != nil {
+		return errors.NewMountError(fmt.Sprintf("failed to mount shared volume %s for %s", source, name), err)
+	}
+
+	if err := p.bindMount(sourceMountpoint, mountpoint, true); err != nil {
+		if releaseErr := p.Unmount(context.Background(), sourceReq); releaseErr != nil {
+			log.Printf("warning: failed to release shared volume %s: %v", source, releaseErr)
+		}
+		return errors.NewMountError(fmt.Sprintf("failed to bind volume %s at %s", source, mountpoint), err)
+	}
+	return nil
+}
+
+// unmountShared removes the bind mount of a sharing volume and releases
+// its reference on the source volume.
+func (p *GFSDriver) unmountShared(ctx context.Context, source, name, mountpoint string) error {
+	if err := p.unbind(mountpoint); err != nil {
+		return errors.NewMountError(fmt.Sprintf("failed to unbind volume %s", name), err)
+	}
+	return p.Unmount(ctx, &volume.MountRequest{Name: source, ID: shareMountID(name)})
+}
+
+// shareMountID identifies the mount a sharing volume holds on its source.
+func shareMountID(name string) string {
+	return "share:" + name
+}
@@ -0,0 +1,612 @@
+package driver
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"glusterfs-plugin/internal/backend"
+	"glusterfs-plugin/internal/backend/backendtest"
+	"glusterfs-plugin/internal/errors"
+	"glusterfs-plugin/pkg/volume"
+)
+
+// newTestDriver returns a driver mounting under a temp dir through a fake
+// backend registered as the default glusterfs type.
+func newTestDriver(t *testing.T) (*GFSDriver, *backendtest.Fake) {
+	t.Helper()
+	d := NewDriver([]string{"server1"})
+	d.Root = filepath.Join(t.TempDir(), "mnt")
+	fake := backendtest.New("glusterfs")
+	d.RegisterBackend(fake)
+	return d, fake
+}
+
+func TestMount_Lifecycle(t *testing.T) {
+	d, fake := newTestDriver(t)
+	fake.Output = []string{"connected to server1"}
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{}}))
+
+	mountpoint, err := d.Mount(context.Background(), &volume.MountRequest{Name: "vol1"})
+	require.NoError(t, err)
+	assert.Equal(t, d.Root, filepath.Dir(mountpoint))
+	assert.Regexp(t, `^vol1-[0-9a-f]{32}$`, filepath.Base(mountpoint))
+	path, err := d.Path("vol1")
+	require.NoError(t, err)
+	assert.Equal(t, mountpoint, path)
+
+	args, ok := fake.Mounted(mountpoint)
+	require.True(t, ok)
+	assert.Equal(t, []string{"vol1", "servers=server1"}, args)
+
+	// A second mount shares the first one
+	second, err := d.Mount(context.Background(), &volume.MountRequest{Name: "vol1"})
+	require.NoError(t, err)
+	assert.Equal(t, mountpoint, second)
+	assert.Equal(t, 1, fake.MountCount())
+
+	v, err := d.Get("vol1")
+	require.NoError(t, err)
+	assert.Equal(t, mountpoint, v.Mountpoint)
+	assert.Equal(t, true, v.Status["mounted"])
+	assert.Equal(t, []string{"connected to server1"}, v.Status["clientLog"])
+
+	require.NoError(t, d.Unmount(context.Background(), &volume.MountRequest{Name: "vol1"}))
+	assert.Equal(t, 1, fake.MountCount())
+	require.NoError(t, d.Unmount(context.Background(), &volume.MountRequest{Name: "vol1"}))
+	assert.Equal(t, 0, fake.MountCount())
+	assert.Error(t, d.Unmount(context.Background(), &volume.MountRequest{Name: "vol1"}))
+
+	v, err = d.Get("vol1")
+	require.NoError(t, err)
+	assert.Equal(t, false, v.Status["mounted"])
+	assert.Empty(t, v.Mountpoint)
+
+	// The mount point is removed after the last unmount
+	assert.NoDirExists(t, mountpoint)
+}
+
+func TestMountpointFor(t *testing.T) {
+	d := NewDriver(nil)
+	assert.Equal(t, DefaultRoot, d.Root)
+
+	vol1 := d.mountpointFor(&volume.CreateRequest{Name: "vol1/apps", Options: map[string]string{"ro": "true"}})
+	assert.Equal(t, DefaultRoot, filepath.Dir(vol1))
+	assert.Regexp(t, `^vol1_apps-[0-9a-f]{32}$`, filepath.Base(vol1))
+
+	// Deterministic, and different for other names or options
+	assert.Equal(t, vol1, d.mountpointFor(&volume.CreateRequest{Name: "vol1/apps", Options: map[string]string{"ro": "true"}}))
+	assert.NotEqual(t, vol1, d.mountpointFor(&volume.CreateRequest{Name: "vol1/apps", Options: map[string]string{"ro": "false"}}))
+	assert.NotEqual(t, vol1, d.mountpointFor(&volume.CreateRequest{Name: "vol1_apps", Options: map[string]string{"ro": "true"}}))
+	assert.NotEqual(t,
+		d.mountpointFor(&volume.CreateRequest{Name: "vol1", Options: map[string]string{"a": "b=c"}}),
+		d.mountpointFor(&volume.CreateRequest{Name: "vol1", Options: map[string]string{"a=b": "c"}}))
+
+	long := d.mountpointFor(&volume.CreateRequest{Name: strings.Repeat("v", 200)})
+	assert.Len(t, filepath.Base(long), maxMountpointPrefix+1+32)
+}
+
+func TestMount_IgnoresRequestMountpoint(t *testing.T) {
+	d, fake := newTestDriver(t)
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{}}))
+
+	// Clients of the socket cannot choose where the volume is mounted
+	outside := filepath.Join(t.TempDir(), "etc", "cron.d")
+	mountpoint, err := d.Mount(context.Background(), &volume.MountRequest{Name: "vol1", ID: "a", Mountpoint: outside})
+	require.NoError(t, err)
+	assert.Equal(t, d.Root, filepath.Dir(mountpoint))
+	_, ok := fake.Mounted(mountpoint)
+	assert.True(t, ok)
+	_, err = os.Stat(outside)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestMount_MountpointPermissions(t *testing.T) {
+	d, fake := newTestDriver(t)
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{}}))
+
+	mountpoint, err := d.Mount(context.Background(), &volume.MountRequest{Name: "vol1", ID: "a"})
+	require.NoError(t, err)
+	info, err := os.Stat(d.Root)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0711), info.Mode().Perm())
+	info, err = os.Stat(mountpoint)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+	require.NoError(t, d.Unmount(context.Background(), &volume.MountRequest{Name: "vol1", ID: "a"}))
+
+	// A failed mount leaves no directory behind
+	fake.MountErr = fmt.Errorf("connection refused")
+	_, err = d.Mount(context.Background(), &volume.MountRequest{Name: "vol1", ID: "b"})
+	assert.Error(t, err)
+	entries, err := os.ReadDir(d.Root)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	// Mount points replaced by symbolic links are refused
+	fake.MountErr = nil
+	require.NoError(t, os.Symlink(t.TempDir(), mountpoint))
+	_, err = d.Mount(context.Background(), &volume.MountRequest{Name: "vol1", ID: "c"})
+	assert.ErrorIs(t, err, errors.ErrMount)
+	assert.Equal(t, 0, fake.MountCount())
+}
+
+func TestMount_FailureCarriesClientLog(t *testing.T) {
+	d, fake := newTestDriver(t)
+	fake.Output = []string{"E [MSGID: 100] failed to fetch volume file"}
+	fake.MountErr = fmt.Errorf("exit status 1")
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{}}))
+
+	_, err := d.Mount(context.Background(), &volume.MountRequest{Name: "vol1"})
+	require.Error(t, err)
+
+	var mountErr *errors.MountError
+	require.True(t, stderrors.As(err, &mountErr))
+	assert.Equal(t, []string{"E [MSGID: 100] failed to fetch volume file"}, mountErr.ClientLog)
+	assert.Contains(t, err.Error(), "failed to fetch volume file")
+
+	v, err := d.Get("vol1")
+	require.NoError(t, err)
+	assert.Equal(t, false, v.Status["mounted"])
+	assert.Equal(t, []string{"E [MSGID: 100] failed to fetch volume file"}, v.Status["clientLog"])
+}
+
+func TestMount_UnknownVolume(t *testing.T) {
+	d, _ := newTestDriver(t)
+
+	_, err := d.Mount(context.Background(), &volume.MountRequest{Name: "missing"})
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+
+	_, err = d.Get("missing")
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+
+	assert.ErrorIs(t, d.Create(&volume.CreateRequest{Name: "bad", Options: map[string]string{"servers": "a"}}), errors.ErrValidation)
+}
+
+func TestMount_SelectsBackendByType(t *testing.T) {
+	d, glusterfs := newTestDriver(t)
+	nfs := backendtest.New("nfs")
+	d.RegisterBackend(nfs)
+
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "exports", Options: map[string]string{"type": "nfs"}}))
+	mountpoint, err := d.Mount(context.Background(), &volume.MountRequest{Name: "exports"})
+	require.NoError(t, err)
+
+	_, ok := nfs.Mounted(mountpoint)
+	assert.True(t, ok)
+	assert.Equal(t, 0, glusterfs.MountCount())
+
+	err = d.Create(&volume.CreateRequest{Name: "bad", Options: map[string]string{"type": "cephfs"}})
+	assert.ErrorIs(t, err, errors.ErrValidation)
+	assert.Contains(t, err.Error(), `unsupported type "cephfs", must be one of glusterfs, nfs`)
+}
+
+func TestMount_Timeout(t *testing.T) {
+	d, fake := newTestDriver(t)
+	fake.Delay = 30 * time.Second
+	d.MountTimeout = 100 * time.Millisecond
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{}}))
+
+	_, err := d.Mount(context.Background(), &volume.MountRequest{Name: "vol1"})
+	assert.ErrorIs(t, err, errors.ErrTimeout)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	v, err := d.Get("vol1")
+	require.NoError(t, err)
+	assert.Equal(t, false, v.Status["mounted"])
+}
+
+func TestMount_CancelledRequest(t *testing.T) {
+	d, fake := newTestDriver(t)
+	fake.Delay = 30 * time.Second
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{}}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	_, err := d.Mount(ctx, &volume.MountRequest{Name: "vol1"})
+	assert.ErrorIs(t, err, errors.ErrMount)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestUnmount_Timeout(t *testing.T) {
+	d, fake := newTestDriver(t)
+	d.UnmountTimeout = 100 * time.Millisecond
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{}}))
+
+	_, err := d.Mount(context.Background(), &volume.MountRequest{Name: "vol1"})
+	require.NoError(t, err)
+
+	fake.Delay = 30 * time.Second
+	err = d.Unmount(context.Background(), &volume.MountRequest{Name: "vol1"})
+	assert.ErrorIs(t, err, errors.ErrTimeout)
+
+	// The volume stays mounted so the unmount can be retried
+	v, err := d.Get("vol1")
+	require.NoError(t, err)
+	assert.Equal(t, true, v.Status["mounted"])
+}
+
+func TestMount_ConcurrentOperationsOnOneVolume(t *testing.T) {
+	d, fake := newTestDriver(t)
+	fake.Delay = time.Millisecond
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{}}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := &volume.MountRequest{Name: "vol1", ID: fmt.Sprintf("c%d", i)}
+			if _, err := d.Mount(context.Background(), req); !assert.NoError(t, err) {
+				return
+			}
+			_, _ = d.Get("vol1")
+			_, _ = d.List()
+			assert.NoError(t, d.Unmount(context.Background(), req))
+			assert.Error(t, d.Remove("missing"))
+		}(i)
+	}
+	wg.Wait()
+
+	assert.False(t, fake.Overlapped(), "mount and unmount ran concurrently")
+	assert.Equal(t, 0, fake.MountCount())
+
+	// Every mount was matched by exactly one unmount
+	var mounts, unmounts int
+	for _, call := range fake.Calls() {
+		switch call[:2] {
+		case "mo":
+			mounts++
+		case "un":
+			unmounts++
+		}
+	}
+	assert.NotZero(t, mounts)
+	assert.Equal(t, mounts, unmounts)
+
+	v, err := d.Get("vol1")
+	require.NoError(t, err)
+	assert.Equal(t, false, v.Status["mounted"])
+}
+
+func TestMount_DifferentVolumesRunInParallel(t *testing.T) {
+	d, _ := newTestDriver(t)
+	slow := backendtest.New("slow")
+	slow.Delay = 2 * time.Second
+	d.RegisterBackend(slow)
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "slow", Options: map[string]string{"type": "slow"}}))
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "fast", Options: map[string]string{}}))
+
+	slowDone := make(chan struct{})
+	go func() {
+		defer close(slowDone)
+		_, _ = d.Mount(context.Background(), &volume.MountRequest{Name: "slow"})
+	}()
+	defer func() { <-slowDone }()
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	_, err := d.Mount(context.Background(), &volume.MountRequest{Name: "fast"})
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), time.Second, "fast volume waited for slow volume")
+}
+
+func TestRemove_SharedVolume(t *testing.T) {
+	d, _ := newTestDriver(t)
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "data", Options: map[string]string{}}))
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "data-ro", Options: map[string]string{"share": "data", "ro": "true"}}))
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "data-ro2", Options: map[string]string{"share": "data", "ro": "true"}}))
+
+	err := d.Remove("data")
+	assert.ErrorIs(t, err, errors.ErrInUse)
+	assert.ErrorContains(t, err, "volume data is shared by volumes data-ro, data-ro2, remove them first")
+	_, err = d.Get("data")
+	require.NoError(t, err)
+
+	require.NoError(t, d.Remove("data-ro"))
+	require.NoError(t, d.Remove("data-ro2"))
+	require.NoError(t, d.Remove("data"))
+}
+
+func TestRemove(t *testing.T) {
+	d, _ := newTestDriver(t)
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{}}))
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol0", Options: map[string]string{}}))
+
+	mountpoint, err := d.Mount(context.Background(), &volume.MountRequest{Name: "vol1"})
+	require.NoError(t, err)
+	assert.ErrorIs(t, d.Remove("vol1"), errors.ErrInUse)
+
+	path, err := d.Path("vol1")
+	require.NoError(t, err)
+	assert.Equal(t, mountpoint, path)
+
+	volumes, err := d.List()
+	require.NoError(t, err)
+	assert.Equal(t, []*volume.Volume{{Name: "vol0"}, {Name: "vol1", Mountpoint: mountpoint}}, volumes)
+
+	require.NoError(t, d.Unmount(context.Background(), &volume.MountRequest{Name: "vol1"}))
+	require.NoError(t, d.Remove("vol1"))
+	assert.ErrorIs(t, d.Remove("vol1"), errors.ErrNotFound)
+
+	_, err = d.Path("vol1")
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+	assert.Equal(t, "global", d.Capabilities().Scope)
+}
+
+func TestCreate_OwnServersAfterServersSet(t *testing.T) {
+	d := NewDriver(nil)
+	d.Root = filepath.Join(t.TempDir(), "mnt")
+	fake := backendtest.New("glusterfs")
+	d.RegisterBackend(fake)
+	options := map[string]string{"servers": "a,b"}
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol1", Options: options}))
+
+	_, err := d.SetServers([]string{"server1"})
+	require.NoError(t, err)
+
+	// The volume keeps mounting from its own servers
+	mountpoint, err := d.Mount(context.Background(), &volume.MountRequest{Name: "vol1", ID: "c1"})
+	require.NoError(t, err)
+	args, _ := fake.Mounted(mountpoint)
+	assert.Equal(t, []string{"vol1", "servers=a,b"}, args)
+
+	// A redeploy creates it again with the same options
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{"servers": "a,b"}}))
+
+	// New volumes still follow the rules
+	err = d.Create(&volume.CreateRequest{Name: "vol2", Options: options})
+	assert.ErrorIs(t, err, errors.ErrValidation)
+	assert.ErrorContains(t, err, "SERVERS is set")
+}
+
+func TestSetServers_RefusesEmpty(t *testing.T) {
+	d, _ := newTestDriver(t)
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{}}))
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "data", Options: map[string]string{}}))
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "data-ro", Options: map[string]string{"share": "data", "ro": "true"}}))
+
+	// Volumes mounted from SERVERS would have no servers left
+	_, err := d.SetServers(nil)
+	assert.ErrorIs(t, err, errors.ErrValidation)
+	assert.ErrorContains(t, err, "volumes data, vol1 are mounted from it")
+	assert.Equal(t, []string{"server1"}, d.Servers)
+	previous, err := d.SetServers([]string{"server2"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"server1"}, previous)
+
+	// Volumes naming their own servers do not depend on it
+	require.NoError(t, d.Remove("vol1"))
+	require.NoError(t, d.Remove("data-ro"))
+	require.NoError(t, d.Remove("data"))
+	_, err = d.SetServers(nil)
+	require.NoError(t, err)
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol2", Options: map[string]string{"servers": "a"}}))
+	_, err = d.SetServers(nil)
+	assert.NoError(t, err)
+}
+
+func TestCreate_Idempotent(t *testing.T) {
+	d := NewDriver(nil)
+	d.Root = filepath.Join(t.TempDir(), "mnt")
+	fake := backendtest.New("glusterfs")
+	d.RegisterBackend(fake)
+	options := map[string]string{"servers": "a,b", "ro": "true"}
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol1", Options: options}))
+	mountpoint, err := d.Mount(context.Background(), &volume.MountRequest{Name: "vol1", ID: "c1"})
+	require.NoError(t, err)
+
+	// Equivalent options are accepted and leave the mounted volume alone
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{"servers": "a, b", "ro": "1"}}))
+	path, err := d.Path("vol1")
+	require.NoError(t, err)
+	assert.Equal(t, mountpoint, path)
+
+	err = d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{"servers": "a,c", "glusteropts": ""}})
+	assert.ErrorIs(t, err, errors.ErrValidation)
+	err = d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{"servers": "a,c", "ro": "false"}})
+	assert.ErrorIs(t, err, errors.ErrAlreadyExists)
+	assert.Contains(t, err.Error(), `volume vol1 already exists with other options (ro: "true" -> "false", servers: "a,b" -> "a,c")`)
+	err = d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{"servers": "a,b"}})
+	assert.Contains(t, err.Error(), `(ro: "true" -> unset)`)
+
+	// The volume keeps its options
+	v, err := d.Get("vol1")
+	require.NoError(t, err)
+	assert.Equal(t, true, v.Status["readOnly"])
+	args, ok := fake.Mounted(mountpoint)
+	require.True(t, ok)
+	assert.Equal(t, []string{"vol1", "ro=true", "servers=a,b"}, args)
+}
+
+func TestCreate_InvalidRecreate(t *testing.T) {
+	d, _ := newTestDriver(t)
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{"uid": "1000"}}))
+
+	// Normalized, " 1000" equals the registered uid, but it is not valid
+	err := d.Create(&volume.CreateRequest{Name: "vol1", Options: map[string]string{"uid": " 1000"}})
+	assert.ErrorIs(t, err, errors.ErrValidation)
+	assert.ErrorContains(t, err, `invalid uid " 1000"`)
+}
+
+func TestNormalizeOptions(t *testing.T) {
+	assert.Equal(t, map[string]string{
+		"servers":     "a,b",
+		"glusteropts": "-s a --volfile-id=vol1",
+		"ro":          "true",
+		"strict":      "false",
+		"size":        "10737418240",
+		"subdir":      "vol1/a/b",
+		"uid":         "1000",
+		"mode":        "not-parsed",
+	}, normalizeOptions(map[string]string{
+		"servers":     " a, ,b ",
+		"glusteropts": "-s a   --volfile-id=vol1 ",
+		"ro":          "1",
+		"strict":      "F",
+		"size":        "10G",
+		"subdir":      "vol1//a/./b/",
+		"uid":         " 1000",
+		"mode":        "not-parsed",
+	}))
+}
+
+func TestNormalizeOptions_SameMountArgs(t *testing.T) {
+	d := NewDriver(nil)
+	d.RegisterBackend(backend.NewGlusterfs())
+
+	// Options that compare equal must mount the same way
+	a := map[string]string{"glusteropts": "-s a --volfile-id=vol1"}
+	b := map[string]string{"glusteropts": " -s  a\t--volfile-id=vol1 "}
+	require.Equal(t, normalizeOptions(a), normalizeOptions(b))
+	assert.Equal(t,
+		d.MountOptions(&volume.CreateRequest{Name: "vol1", Options: a}),
+		d.MountOptions(&volume.CreateRequest{Name: "vol1", Options: b}))
+}
+
+func TestValidate_Share(t *testing.T) {
+	d, _ := newTestDriver(t)
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "data", Options: map[string]string{}}))
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "data-ro", Options: map[string]string{"share": "data", "ro": "true"}}))
+
+	tests := []struct {
+		name    string
+		options map[string]string
+		wantErr string
+	}{
+		{name: "without ro", options: map[string]string{"share": "data"}, wantErr: "share requires ro=true"},
+		{name: "ro false", options: map[string]string{"share": "data", "ro": "false"}, wantErr: "share requires ro=true"},
+		{name: "with servers", options: map[string]string{"share": "data", "ro": "true", "servers": "a"}, wantErr: "servers is not allowed"},
+		{name: "with glusteropts", options: map[string]string{"share": "data", "ro": "true", "glusteropts": "-s a"}, wantErr: "glusteropts is not allowed"},
+		{name: "with type", options: map[string]string{"share": "data", "ro": "true", "type": "glusterfs"}, wantErr: "type is not allowed"},
+		{name: "with subdir", options: map[string]string{"share": "data", "ro": "true", "subdir": "vol1/apps"}, wantErr: "subdir is not allowed"},
+		{name: "itself", options: map[string]string{"share": "other", "ro": "true"}, wantErr: `invalid share "other"`},
+		{name: "unknown volume", options: map[string]string{"share": "missing", "ro": "true"}, wantErr: "shared volume missing does not exist"},
+		{name: "nested share", options: map[string]string{"share": "data-ro", "ro": "true"}, wantErr: "volume data-ro shares a volume itself"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := d.Validate(&volume.CreateRequest{Name: "other", Options: tt.options})
+			assert.ErrorIs(t, err, errors.ErrValidation)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestValidate_VolumeName(t *testing.T) {
+	d, _ := newTestDriver(t)
+	withoutServers := NewDriver(nil)
+
+	tests := []struct {
+		name    string
+		d       *GFSDriver
+		req     *volume.CreateRequest
+		wantErr string
+	}{
+		{name: "parent directory", d: d, req: &volume.CreateRequest{Name: "vol/../../etc"}, wantErr: "cannot contain .."},
+		{name: "invalid volume", d: d, req: &volume.CreateRequest{Name: "vol.1/apps"}, wantErr: "can only contain letters"},
+		{name: "invalid subdir", d: d, req: &volume.CreateRequest{Name: "web", Options: map[string]string{"subdir": "vol1/.."}}, wantErr: "cannot contain .."},
+		{name: "subdir and slash", d: d, req: &volume.CreateRequest{Name: "vol1/web", Options: map[string]string{"subdir": "vol1/apps"}}, wantErr: "subdir is only for volume names without one"},
+		{name: "subdir and glusteropts", d: withoutServers, req: &volume.CreateRequest{Name: "web", Options: map[string]string{"subdir": "vol1/apps", "glusteropts": "-s a"}}, wantErr: "subdir is not allowed"},
+		{name: "glusteropts and parent directory", d: withoutServers, req: &volume.CreateRequest{Name: "../web", Options: map[string]string{"glusteropts": "-s a"}}, wantErr: "cannot contain .."},
+		{name: "share and parent directory", d: d, req: &volume.CreateRequest{Name: "a/../b", Options: map[string]string{"share": "data", "ro": "true"}}, wantErr: "cannot contain .."},
+		{name: "empty", d: d, req: &volume.CreateRequest{Name: ""}, wantErr: "volume name cannot be empty"},
+		{name: "empty with subdir", d: d, req: &volume.CreateRequest{Name: "", Options: map[string]string{"subdir": "vol1/apps"}}, wantErr: "volume name cannot be empty"},
+		{name: "empty with glusteropts", d: withoutServers, req: &volume.CreateRequest{Name: "", Options: map[string]string{"glusteropts": "-s a --volfile-id=vol1"}}, wantErr: "volume name cannot be empty"},
+		{name: "empty share", d: d, req: &volume.CreateRequest{Name: "", Options: map[string]string{"share": "data", "ro": "true"}}, wantErr: "volume name cannot be empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.req.Options == nil {
+				tt.req.Options = map[string]string{}
+			}
+			err := tt.d.Validate(tt.req)
+			assert.ErrorIs(t, err, errors.ErrValidation)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+
+	// Names that glusteropts mount need not be GlusterFS volume names
+	assert.NoError(t, withoutServers.Validate(&volume.CreateRequest{Name: "web.data", Options: map[string]string{"glusteropts": "-s a --volfile-id=vol1"}}))
+}
+
+func TestMountOptions_SubdirAlias(t *testing.T) {
+	d := NewDriver([]string{"server1"})
+
+	req := &volume.CreateRequest{Name: "web-data", Options: map[string]string{"subdir": "vol1//apps/web/"}}
+	require.NoError(t, d.Validate(req))
+	assert.Equal(t, []string{"-s", "server1", "--volfile-id=vol1", "--subdir-mount=/apps/web", "--logger=syslog"}, d.MountOptions(req))
+
+	// Volume names are normalized the same way
+	req = &volume.CreateRequest{Name: "vol1/apps//web/", Options: map[string]string{}}
+	assert.Equal(t, []string{"-s", "server1", "--volfile-id=vol1", "--subdir-mount=/apps/web", "--logger=syslog"}, d.MountOptions(req))
+}
+
+func TestMount_ReadOnlyShare(t *testing.T) {
+	d, fake := newTestDriver(t)
+	binds := map[string]string{}
+	d.bindMount = func(source, target string, readOnly bool) error {
+		assert.True(t, readOnly)
+		binds[target] = source
+		return nil
+	}
+	d.unbind = func(target string) error {
+		delete(binds, target)
+		return nil
+	}
+
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "data", Options: map[string]string{}}))
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "data-ro", Options: map[string]string{"share": "data", "ro": "true"}}))
+
+	// The read-write consumer and the read-only consumer share one client
+	rw, err := d.Mount(context.Background(), &volume.MountRequest{Name: "data", ID: "writer"})
+	require.NoError(t, err)
+	ro, err := d.Mount(context.Background(), &volume.MountRequest{Name: "data-ro", ID: "reader"})
+	require.NoError(t, err)
+	assert.NotEqual(t, rw, ro)
+	assert.Equal(t, map[string]string{ro: rw}, binds)
+	assert.Equal(t, 1, fake.MountCount())
+
+	v, err := d.Get("data-ro")
+	require.NoError(t, err)
+	assert.Equal(t, true, v.Status["readOnly"])
+	assert.Equal(t, "data", v.Status["sharedFrom"])
+	v, err = d.Get("data")
+	require.NoError(t, err)
+	assert.Equal(t, false, v.Status["readOnly"])
+
+	// The shared volume stays mounted while it is shared
+	require.NoError(t, d.Unmount(context.Background(), &volume.MountRequest{Name: "data", ID: "writer"}))
+	assert.Equal(t, 1, fake.MountCount())
+	assert.ErrorIs(t, d.Remove("data"), errors.ErrInUse)
+
+	require.NoError(t, d.Unmount(context.Background(), &volume.MountRequest{Name: "data-ro", ID: "reader"}))
+	assert.Empty(t, binds)
+	assert.Equal(t, 0, fake.MountCount())
+}
+
+func TestMount_ReadOnlyShareBindFailureReleasesSource(t *testing.T) {
+	d, fake := newTestDriver(t)
+	d.bindMount = func(source, target string, readOnly bool) error {
+		return fmt.Errorf("operation not permitted")
+	}
+
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "data", Options: map[string]string{}}))
+	require.NoError(t, d.Create(&volume.CreateRequest{Name: "data-ro", Options: map[string]string{"share": "data", "ro": "true"}}))
+
+	_, err := d.Mount(context.Background(), &volume.MountRequest{Name: "data-ro"})
+	assert.ErrorIs(t, err, errors.ErrMount)
+	assert.Equal(t, 0, fake.MountCount())
+}
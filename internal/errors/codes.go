@@ -0,0 +1,62 @@
+package errors
+
+import (
+	stderrors "errors"
+	"fmt"
+)
+
+// Code is a stable, machine-readable identifier for a class of errors.
+// Codes are part of the Err strings returned to Docker and must not change.
+type Code string
+
+const (
+	CodeValidation        Code = "VALIDATION"
+	CodeMount             Code = "MOUNT_FAILED"
+	CodeNotFound          Code = "NOT_FOUND"
+	CodeAlreadyExists     Code = "ALREADY_EXISTS"
+	CodeInUse             Code = "IN_USE"
+	CodeTimeout           Code = "TIMEOUT"
+	CodeServerUnreachable Code = "SERVER_UNREACHABLE"
+	CodePermissionDenied  Code = "PERMISSION_DENIED"
+	CodeQuotaExceeded     Code = "QUOTA_EXCEEDED"
+
+	// CodeInternal is used for errors that do not carry a code
+	CodeInternal Code = "INTERNAL"
+)
+
+// Sentinels for use with errors.Is, matching any error with the same code
+var (
+	ErrValidation        error = &ValidationError{}
+	ErrMount             error = &MountError{}
+	ErrNotFound          error = &codedError{code: CodeNotFound}
+	ErrAlreadyExists     error = &codedError{code: CodeAlreadyExists}
+	ErrInUse             error = &codedError{code: CodeInUse}
+	ErrTimeout           error = &codedError{code: CodeTimeout}
+	ErrServerUnreachable error = &codedError{code: CodeServerUnreachable}
+	ErrPermissionDenied  error = &codedError{code: CodePermissionDenied}
+	ErrQuotaExceeded     error = &codedError{code: CodeQuotaExceeded}
+)
+
+// coder is implemented by every error type of this package
+type coder interface {
+	Code() Code
+}
+
+// CodeOf returns the code of the first coded error in err's chain,
+// or CodeInternal if there is none
+func CodeOf(err error) Code {
+	var c coder
+	if stderrors.As(err, &c) {
+		return c.Code()
+	}
+	return CodeInternal
+}
+
+// Response renders err as the Err string of a Docker plugin response,
+// in the form "CODE: message". A nil error renders as an empty string.
+func Response(err error) string {
+	if err == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s: %s", CodeOf(err), err.Error())
+}
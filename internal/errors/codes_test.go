@@ -0,0 +1,167 @@
+package errors
+
+import (
+	stderrors "errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorMappings(t *testing.T) {
+	cause := fmt.Errorf("connection refused")
+
+	tests := []struct {
+		name     string
+		err      error
+		sentinel error
+		code     Code
+		want     string
+		response string
+	}{
+		{
+			name:     "validation",
+			err:      &ValidationError{Message: "bad option", Cause: cause},
+			sentinel: ErrValidation,
+			code:     CodeValidation,
+			want:     "validation error: bad option (caused by: connection refused)",
+			response: "VALIDATION: validation error: bad option (caused by: connection refused)",
+		},
+		{
+			name:     "mount",
+			err:      NewMountError("mount failed", cause),
+			sentinel: ErrMount,
+			code:     CodeMount,
+			want:     "mount error: mount failed (caused by: connection refused)",
+			response: "MOUNT_FAILED: mount error: mount failed (caused by: connection refused)",
+		},
+		{
+			name:     "not found",
+			err:      NewNotFoundError("volume vol1 does not exist", cause),
+			sentinel: ErrNotFound,
+			code:     CodeNotFound,
+			want:     "not found error: volume vol1 does not exist (caused by: connection refused)",
+			response: "NOT_FOUND: not found error: volume vol1 does not exist (caused by: connection refused)",
+		},
+		{
+			name:     "already exists",
+			err:      NewAlreadyExistsError("volume vol1 already exists", cause),
+			sentinel: ErrAlreadyExists,
+			code:     CodeAlreadyExists,
+			want:     "already exists error: volume vol1 already exists (caused by: connection refused)",
+			response: "ALREADY_EXISTS: already exists error: volume vol1 already exists (caused by: connection refused)",
+		},
+		{
+			name:     "in use",
+			err:      NewInUseError("volume vol1 is mounted", cause),
+			sentinel: ErrInUse,
+			code:     CodeInUse,
+			want:     "in use error: volume vol1 is mounted (caused by: connection refused)",
+			response: "IN_USE: in use error: volume vol1 is mounted (caused by: connection refused)",
+		},
+		{
+			name:     "timeout",
+			err:      NewTimeoutError("mount did not finish", cause),
+			sentinel: ErrTimeout,
+			code:     CodeTimeout,
+			want:     "timeout error: mount did not finish (caused by: connection refused)",
+			response: "TIMEOUT: timeout error: mount did not finish (caused by: connection refused)",
+		},
+		{
+			name:     "server unreachable",
+			err:      NewServerUnreachableError("store1:24007", cause),
+			sentinel: ErrServerUnreachable,
+			code:     CodeServerUnreachable,
+			want:     "server unreachable error: store1:24007 (caused by: connection refused)",
+			response: "SERVER_UNREACHABLE: server unreachable error: store1:24007 (caused by: connection refused)",
+		},
+		{
+			name:     "permission denied",
+			err:      NewPermissionDeniedError("cannot chown /mnt/vol1", cause),
+			sentinel: ErrPermissionDenied,
+			code:     CodePermissionDenied,
+			want:     "permission denied error: cannot chown /mnt/vol1 (caused by: connection refused)",
+			response: "PERMISSION_DENIED: permission denied error: cannot chown /mnt/vol1 (caused by: connection refused)",
+		},
+		{
+			name:     "quota exceeded",
+			err:      NewQuotaExceededError("volume vol1 uses 10G of 10G", cause),
+			sentinel: ErrQuotaExceeded,
+			code:     CodeQuotaExceeded,
+			want:     "quota exceeded error: volume vol1 uses 10G of 10G (caused by: connection refused)",
+			response: "QUOTA_EXCEEDED: quota exceeded error: volume vol1 uses 10G of 10G (caused by: connection refused)",
+		},
+	}
+
+	sentinels := []error{
+		ErrValidation, ErrMount, ErrNotFound, ErrAlreadyExists, ErrInUse,
+		ErrTimeout, ErrServerUnreachable, ErrPermissionDenied, ErrQuotaExceeded,
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.err.Error())
+			assert.Equal(t, tt.code, CodeOf(tt.err))
+			assert.Equal(t, tt.response, Response(tt.err))
+
+			// The cause is reachable through Unwrap
+			assert.Same(t, cause, stderrors.Unwrap(tt.err))
+			assert.True(t, stderrors.Is(tt.err, cause))
+
+			// Only the matching sentinel matches, also when wrapped
+			wrapped := fmt.Errorf("handling request: %w", tt.err)
+			for _, sentinel := range sentinels {
+				assert.Equal(t, sentinel == tt.sentinel, stderrors.Is(wrapped, sentinel), "sentinel %v", sentinel)
+			}
+			assert.Equal(t, tt.code, CodeOf(wrapped))
+		})
+	}
+}
+
+func TestCodesAreUnique(t *testing.T) {
+	codes := []Code{
+		CodeValidation, CodeMount, CodeNotFound, CodeAlreadyExists, CodeInUse,
+		CodeTimeout, CodeServerUnreachable, CodePermissionDenied, CodeQuotaExceeded,
+		CodeInternal,
+	}
+	seen := make(map[Code]bool)
+	for _, code := range codes {
+		assert.False(t, seen[code], "duplicate code %s", code)
+		seen[code] = true
+	}
+}
+
+func TestResponse_UncodedErrors(t *testing.T) {
+	assert.Equal(t, "", Response(nil))
+	assert.Equal(t, CodeInternal, CodeOf(fmt.Errorf("boom")))
+	assert.Equal(t, "INTERNAL: boom", Response(fmt.Errorf("boom")))
+}
+
+func TestErrorsAs(t *testing.T) {
+	err := fmt.Errorf("mounting: %w", NewMountError("failed", nil).WithClientLog([]string{"line"}))
+
+	var mountErr *MountError
+	assert.True(t, stderrors.As(err, &mountErr))
+	assert.Equal(t, "failed", mountErr.Message)
+	assert.Equal(t, []string{"line"}, mountErr.ClientLog)
+
+	var validation *ValidationError
+	assert.False(t, stderrors.As(err, &validation))
+}
+
+func TestErrorsIs_Cause(t *testing.T) {
+	unreachable := NewServerUnreachableError("store1", nil)
+	err := NewNotFoundError("volume vol1 does not exist", unreachable)
+
+	// Errors match their sentinel and, through the cause, the sentinels
+	// and errors they wrap
+	assert.True(t, stderrors.Is(err, ErrNotFound))
+	assert.True(t, stderrors.Is(err, ErrServerUnreachable))
+	assert.True(t, stderrors.Is(err, unreachable))
+	assert.False(t, stderrors.Is(err, ErrTimeout))
+
+	// Other errors with the same code are not the same error
+	assert.False(t, stderrors.Is(err, NewNotFoundError("volume vol2 does not exist", nil)))
+	assert.False(t, stderrors.Is(NewValidationError("a"), NewValidationError("b")))
+	assert.False(t, stderrors.Is(NewMountError("a", nil), NewMountError("b", nil)))
+}
@@ -1,38 +1,166 @@
+// Package errors defines the typed errors returned by the plugin.
+// Every error carries a stable Code, wraps its cause and is rendered
+// into a consistent Err string for Docker by Response.
 package errors
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
+
+// format renders the common "<kind> error: message (caused by: cause)" layout
+func format(kind, message string, cause error) string {
+	if cause != nil {
+		return fmt.Sprintf("%s error: %s (caused by: %v)", kind, message, cause)
+	}
+	return fmt.Sprintf("%s error: %s", kind, message)
+}
 
 // ValidationError represents an error during validation
 type ValidationError struct {
-	message string
+	Message string
+	Cause   error
 }
 
 func (e *ValidationError) Error() string {
-	return fmt.Sprintf("validation error: %s", e.message)
+	return format("validation", e.Message, e.Cause)
+}
+
+// Code returns CodeValidation
+func (e *ValidationError) Code() Code { return CodeValidation }
+
+// Unwrap returns the underlying cause
+func (e *ValidationError) Unwrap() error { return e.Cause }
+
+// Is reports whether target is ErrValidation; other targets are matched
+// against the cause by errors.Is
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
 }
 
 // NewValidationError creates a new ValidationError
 func NewValidationError(message string) *ValidationError {
-	return &ValidationError{message: message}
+	return &ValidationError{Message: message}
 }
 
 // MountError represents an error during mount operations
 type MountError struct {
-	message string
-	cause   error
+	Message string
+	Cause   error
+
+	// ClientLog holds the last lines of the glusterfs client log
+	ClientLog []string
 }
 
 func (e *MountError) Error() string {
-	if e.cause != nil {
-		return fmt.Sprintf("mount error: %s (caused by: %v)", e.message, e.cause)
+	msg := format("mount", e.Message, e.Cause)
+	if len(e.ClientLog) > 0 {
+		msg = fmt.Sprintf("%s\nglusterfs client log:\n%s", msg, strings.Join(e.ClientLog, "\n"))
 	}
-	return fmt.Sprintf("mount error: %s", e.message)
+	return msg
+}
+
+// Code returns CodeMount
+func (e *MountError) Code() Code { return CodeMount }
+
+// Unwrap returns the underlying cause
+func (e *MountError) Unwrap() error { return e.Cause }
+
+// Is reports whether target is ErrMount; other targets are matched
+// against the cause by errors.Is
+func (e *MountError) Is(target error) bool {
+	return target == ErrMount
+}
+
+// WithClientLog attaches the last lines of the glusterfs client log to the error
+func (e *MountError) WithClientLog(lines []string) *MountError {
+	e.ClientLog = lines
+	return e
 }
 
 // NewMountError creates a new MountError
 func NewMountError(message string, cause error) *MountError {
 	return &MountError{
-		message: message,
-		cause:   cause,
+		Message: message,
+		Cause:   cause,
 	}
-} 
\ No newline at end of file
+}
+
+// codedError is an error identified by its code alone: the errors
+// without fields of their own share it and are created by the typed
+// constructors below
+type codedError struct {
+	code Code
+	msg  string
+	err  error
+}
+
+// kinds names the codes of codedError in messages
+var kinds = map[Code]string{
+	CodeNotFound:          "not found",
+	CodeAlreadyExists:     "already exists",
+	CodeInUse:             "in use",
+	CodeTimeout:           "timeout",
+	CodeServerUnreachable: "server unreachable",
+	CodePermissionDenied:  "permission denied",
+	CodeQuotaExceeded:     "quota exceeded",
+}
+
+func (e *codedError) Error() string {
+	return format(kinds[e.code], e.msg, e.err)
+}
+
+// Code returns the code of the error
+func (e *codedError) Code() Code { return e.code }
+
+// Unwrap returns the underlying cause
+func (e *codedError) Unwrap() error { return e.err }
+
+// Is reports whether target is the sentinel of the error code; other
+// targets are matched against the cause by errors.Is
+func (e *codedError) Is(target error) bool {
+	sentinel, ok := target.(*codedError)
+	return ok && sentinel.code == e.code && sentinel.msg == "" && sentinel.err == nil
+}
+
+// NewNotFoundError creates an error matching ErrNotFound, for a
+// reference to a volume or resource that does not exist
+func NewNotFoundError(message string, cause error) error {
+	return &codedError{code: CodeNotFound, msg: message, err: cause}
+}
+
+// NewAlreadyExistsError creates an error matching ErrAlreadyExists, for
+// an attempt to create something that already exists
+func NewAlreadyExistsError(message string, cause error) error {
+	return &codedError{code: CodeAlreadyExists, msg: message, err: cause}
+}
+
+// NewInUseError creates an error matching ErrInUse, for an operation
+// refused because a volume is still in use
+func NewInUseError(message string, cause error) error {
+	return &codedError{code: CodeInUse, msg: message, err: cause}
+}
+
+// NewTimeoutError creates an error matching ErrTimeout, for an
+// operation that did not complete in time
+func NewTimeoutError(message string, cause error) error {
+	return &codedError{code: CodeTimeout, msg: message, err: cause}
+}
+
+// NewServerUnreachableError creates an error matching
+// ErrServerUnreachable, for a GlusterFS server that cannot be contacted
+func NewServerUnreachableError(message string, cause error) error {
+	return &codedError{code: CodeServerUnreachable, msg: message, err: cause}
+}
+
+// NewPermissionDeniedError creates an error matching ErrPermissionDenied,
+// for an operation rejected for lack of permissions
+func NewPermissionDeniedError(message string, cause error) error {
+	return &codedError{code: CodePermissionDenied, msg: message, err: cause}
+}
+
+// NewQuotaExceededError creates an error matching ErrQuotaExceeded, for
+// a volume that has used up its quota
+func NewQuotaExceededError(message string, cause error) error {
+	return &codedError{code: CodeQuotaExceeded, msg: message, err: cause}
+}
@@ -65,4 +65,13 @@ func TestMountError(t *testing.T) {
 			assert.Equal(t, tt.want, err.Error())
 		})
 	}
-} 
\ No newline at end of file
+}
+
+func TestMountError_WithClientLog(t *testing.T) {
+	lines := []string{"E [MSGID: 101] failed to fetch volume file", "W [MSGID: 102] exiting"}
+	err := NewMountError("mount failed", nil).WithClientLog(lines)
+
+	assert.Equal(t, lines, err.ClientLog)
+	assert.Equal(t, "mount error: mount failed\nglusterfs client log:\n"+
+		"E [MSGID: 101] failed to fetch volume file\nW [MSGID: 102] exiting", err.Error())
+}
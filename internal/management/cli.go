@@ -0,0 +1,579 @@
+package management
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	stderrors "errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"glusterfs-plugin/internal/errors"
+)
+
+const (
+	// glusterBinary is the gluster management CLI.
+	glusterBinary = "gluster"
+
+	// connectionFailed is printed by the CLI when glusterd cannot be reached.
+	connectionFailed = "Connection failed"
+
+	// quotaAlreadyEnabled is the error of enabling quotas twice.
+	quotaAlreadyEnabled = "already enabled"
+
+	// doesNotExist ends the error of commands on unknown volumes.
+	doesNotExist = "does not exist"
+
+	// alreadyExists ends the error of creating an existing volume.
+	alreadyExists = "already exists"
+
+	// notStarted is the error of stopping a stopped volume.
+	notStarted = "is not in the started state"
+
+	// alreadyActivated is the error of activating an active snapshot.
+	alreadyActivated = "already activated"
+
+	// snapshotTimeLayout is the layout of snapshot creation times.
+	snapshotTimeLayout = "2006-01-02 15:04:05"
+)
+
+// Error codes glusterd reports in opErrno, from glusterd-errno.h.
+// The messages above are matched only when glusterd reports no code.
+const (
+	errNoVolume       = 30806 // EG_NOVOL
+	errNoSnapshot     = 30807 // EG_NOSNAP
+	errVolumeStopped  = 30810 // EG_VOLSTP
+	errVolumeExists   = 30811 // EG_VOLEXST
+	errSnapshotExists = 30812 // EG_SNAPEXST
+)
+
+// transports maps the transport codes of volume info to their names.
+var transports = map[string]string{"0": "tcp", "1": "rdma", "2": "tcp,rdma"}
+
+// CLI is a Client running the gluster command line tool in XML mode.
+// Commands are sent to the first server whose glusterd answers.
+type CLI struct {
+	// Binary is the gluster executable.
+	Binary string
+
+	// Servers are the glusterd hosts, tried in order. Without servers
+	// the commands go to the local glusterd.
+	Servers []string
+}
+
+var _ Client = (*CLI)(nil)
+
+// NewCLI creates a client for the cluster reachable through servers.
+//
+// Parameters:
+// - servers: The glusterd hosts
+//
+// Returns:
+// - A new CLI using the system gluster binary
+func NewCLI(servers []string) *CLI {
+	return &CLI{Binary: glusterBinary, Servers: servers}
+}
+
+// cliOutput is the envelope of every gluster --xml response.
+type cliOutput struct {
+	OpRet    int    `xml:"opRet"`
+	OpErrno  int    `xml:"opErrno"`
+	OpErrstr string `xml:"opErrstr"`
+}
+
+// quotaListOutput is the response of "volume quota <volume> list".
+type quotaListOutput struct {
+	cliOutput
+	Limits []struct {
+		Path      string `xml:"path"`
+		HardLimit string `xml:"hard_limit"`
+		UsedSpace string `xml:"used_space"`
+	} `xml:"volQuota>limit"`
+}
+
+// volumeListOutput is the response of "volume list".
+type volumeListOutput struct {
+	cliOutput
+	Volumes []string `xml:"volList>volume"`
+}
+
+// volumeInfoOutput is the response of "volume info <volume>".
+type volumeInfoOutput struct {
+	cliOutput
+	Volumes []struct {
+		Name         string   `xml:"name"`
+		ID           string   `xml:"id"`
+		StatusStr    string   `xml:"statusStr"`
+		TypeStr      string   `xml:"typeStr"`
+		Transport    string   `xml:"transport"`
+		ReplicaCount int      `xml:"replicaCount"`
+		Bricks       []string `xml:"bricks>brick>name"`
+		Options      []struct {
+			Name  string `xml:"name"`
+			Value string `xml:"value"`
+		} `xml:"options>option"`
+	} `xml:"volInfo>volumes>volume"`
+}
+
+// volumeStatusOutput is the response of "volume status <volume>".
+type volumeStatusOutput struct {
+	cliOutput
+	Nodes []struct {
+		Hostname string `xml:"hostname"`
+		Path     string `xml:"path"`
+		Status   int    `xml:"status"`
+		Port     string `xml:"port"`
+		PID      string `xml:"pid"`
+	} `xml:"volStatus>volumes>volume>node"`
+}
+
+// snapshotInfoOutput is the response of "snapshot info <snapshot>".
+type snapshotInfoOutput struct {
+	cliOutput
+	Snapshots []struct {
+		Name       string `xml:"name"`
+		CreateTime string `xml:"createTime"`
+		Volume     struct {
+			Status string `xml:"status"`
+			Origin string `xml:"originVolume>name"`
+		} `xml:"snapVolume"`
+	} `xml:"snapInfo>snapshots>snapshot"`
+}
+
+// commandError is a gluster command rejected by glusterd.
+type commandError struct {
+	args    []string
+	errno   int
+	message string
+}
+
+func (e *commandError) Error() string {
+	return fmt.Sprintf("gluster %s: %s", strings.Join(e.args, " "), e.message)
+}
+
+// Volumes returns the names of the volumes of the cluster.
+//
+// Parameters:
+// - ctx: Bounds the gluster command
+//
+// Returns:
+// - The volume names
+// - ServerUnreachableError if no glusterd answers
+func (c *CLI) Volumes(ctx context.Context) ([]string, error) {
+	var out volumeListOutput
+	if err := c.run(ctx, &out, "volume", "list"); err != nil {
+		return nil, err
+	}
+	return out.Volumes, nil
+}
+
+// Volume returns a volume with its bricks and reconfigured options.
+//
+// Parameters:
+// - ctx: Bounds the gluster command
+// - name: The volume name
+//
+// Returns:
+// - The volume
+// - NotFoundError if the volume does not exist
+// - ServerUnreachableError if no glusterd answers
+func (c *CLI) Volume(ctx context.Context, name string) (*Volume, error) {
+	var out volumeInfoOutput
+	if err := c.run(ctx, &out, "volume", "info", name); err != nil {
+		return nil, volumeError(name, err)
+	}
+
+	for _, info := range out.Volumes {
+		if info.Name != name {
+			continue
+		}
+		v := &Volume{
+			Name:         info.Name,
+			ID:           info.ID,
+			Status:       info.StatusStr,
+			Type:         info.TypeStr,
+			Transport:    transports[info.Transport],
+			ReplicaCount: info.ReplicaCount,
+			Bricks:       info.Bricks,
+			Options:      make(map[string]string, len(info.Options)),
+		}
+		for _, option := range info.Options {
+			v.Options[option.Name] = option.Value
+		}
+		return v, nil
+	}
+	return nil, errors.NewNotFoundError(fmt.Sprintf("volume %s does not exist", name), nil)
+}
+
+// Bricks returns the status of the bricks of a volume.
+// Daemons reported with the bricks, such as the self-heal daemon, are
+// left out.
+//
+// Parameters:
+// - ctx: Bounds the gluster command
+// - name: The volume name
+//
+// Returns:
+// - The brick status, in the order of the volume bricks
+// - NotFoundError if the volume does not exist
+// - ServerUnreachableError if no glusterd answers
+func (c *CLI) Bricks(ctx context.Context, name string) ([]Brick, error) {
+	var out volumeStatusOutput
+	if err := c.run(ctx, &out, "volume", "status", name); err != nil {
+		return nil, volumeError(name, err)
+	}
+
+	var bricks []Brick
+	for _, node := range out.Nodes {
+		if !strings.HasPrefix(node.Path, "/") {
+			continue
+		}
+		brick := Brick{Host: node.Hostname, Path: node.Path, Online: node.Status == 1}
+		// Offline bricks report N/A and -1
+		if brick.Online {
+			brick.Port, _ = strconv.Atoi(node.Port)
+			brick.PID, _ = strconv.Atoi(node.PID)
+		}
+		bricks = append(bricks, brick)
+	}
+	return bricks, nil
+}
+
+// CreateVolume creates a volume from its bricks.
+//
+// Parameters:
+// - ctx: Bounds the gluster command
+// - config: The volume to create
+//
+// Returns:
+// - AlreadyExistsError if the volume exists
+// - ServerUnreachableError if no glusterd answers
+// - error if gluster rejects the volume, nil otherwise
+func (c *CLI) CreateVolume(ctx context.Context, config *VolumeConfig) error {
+	args := []string{"volume", "create", config.Name}
+	if config.Replica > 1 {
+		args = append(args, "replica", strconv.Itoa(config.Replica))
+	}
+	if config.Transport != "" {
+		args = append(args, "transport", config.Transport)
+	}
+	args = append(args, config.Bricks...)
+
+	var out cliOutput
+	err := c.run(ctx, &out, args...)
+	if isCommandError(err, errVolumeExists, alreadyExists) {
+		return errors.NewAlreadyExistsError(fmt.Sprintf("volume %s already exists", config.Name), err)
+	}
+	return err
+}
+
+// StartVolume starts a volume.
+//
+// Parameters:
+// - ctx: Bounds the gluster command
+// - name: The volume name
+//
+// Returns:
+// - NotFoundError if the volume does not exist
+// - ServerUnreachableError if no glusterd answers
+func (c *CLI) StartVolume(ctx context.Context, name string) error {
+	var out cliOutput
+	return volumeError(name, c.run(ctx, &out, "volume", "start", name))
+}
+
+// StopVolume stops a volume, succeeding if it is already stopped.
+//
+// Parameters:
+// - ctx: Bounds the gluster command
+// - name: The volume name
+//
+// Returns:
+// - NotFoundError if the volume does not exist
+// - ServerUnreachableError if no glusterd answers
+func (c *CLI) StopVolume(ctx context.Context, name string) error {
+	var out cliOutput
+	err := c.run(ctx, &out, "volume", "stop", name)
+	if isCommandError(err, errVolumeStopped, notStarted) {
+		return nil
+	}
+	return volumeError(name, err)
+}
+
+// DeleteVolume deletes a stopped volume.
+//
+// Parameters:
+// - ctx: Bounds the gluster command
+// - name: The volume name
+//
+// Returns:
+// - NotFoundError if the volume does not exist
+// - ServerUnreachableError if no glusterd answers
+func (c *CLI) DeleteVolume(ctx context.Context, name string) error {
+	var out cliOutput
+	return volumeError(name, c.run(ctx, &out, "volume", "delete", name))
+}
+
+// CreateSnapshot takes a snapshot of a volume, named exactly name.
+//
+// Parameters:
+// - ctx: Bounds the gluster command
+// - volume: The volume to snapshot
+// - name: The snapshot name
+//
+// Returns:
+// - AlreadyExistsError if the snapshot exists
+// - NotFoundError if the volume does not exist
+// - ServerUnreachableError if no glusterd answers
+func (c *CLI) CreateSnapshot(ctx context.Context, volume, name string) error {
+	var out cliOutput
+	err := c.run(ctx, &out, "snapshot", "create", name, volume, "no-timestamp")
+	if isCommandError(err, errSnapshotExists, alreadyExists) {
+		return errors.NewAlreadyExistsError(fmt.Sprintf("snapshot %s already exists", name), err)
+	}
+	return volumeError(volume, err)
+}
+
+// Snapshot returns a snapshot with the volume it was taken of.
+//
+// Parameters:
+// - ctx: Bounds the gluster command
+// - name: The snapshot name
+//
+// Returns:
+// - The snapshot
+// - NotFoundError if the snapshot does not exist
+// - ServerUnreachableError if no glusterd answers
+func (c *CLI) Snapshot(ctx context.Context, name string) (*Snapshot, error) {
+	var out snapshotInfoOutput
+	if err := c.run(ctx, &out, "snapshot", "info", name); err != nil {
+		return nil, snapshotError(name, err)
+	}
+
+	for _, info := range out.Snapshots {
+		if info.Name != name {
+			continue
+		}
+		created, err := time.Parse(snapshotTimeLayout, info.CreateTime)
+		if err != nil {
+			return nil, fmt.Errorf("snapshot %s has an invalid creation time %q", name, info.CreateTime)
+		}
+		return &Snapshot{
+			Name:    info.Name,
+			Volume:  info.Volume.Origin,
+			Created: created,
+			Active:  info.Volume.Status == "Started",
+		}, nil
+	}
+	return nil, errors.NewNotFoundError(fmt.Sprintf("snapshot %s does not exist", name), nil)
+}
+
+// ActivateSnapshot activates a snapshot, succeeding if it is active.
+//
+// Parameters:
+// - ctx: Bounds the gluster command
+// - name: The snapshot name
+//
+// Returns:
+// - NotFoundError if the snapshot does not exist
+// - ServerUnreachableError if no glusterd answers
+func (c *CLI) ActivateSnapshot(ctx context.Context, name string) error {
+	var out cliOutput
+	err := c.run(ctx, &out, "snapshot", "activate", name)
+	if isCommandError(err, 0, alreadyActivated) {
+		return nil
+	}
+	return snapshotError(name, err)
+}
+
+// CloneSnapshot creates the volume clone from an active snapshot.
+//
+// Parameters:
+// - ctx: Bounds the gluster command
+// - clone: The name of the new volume
+// - snapshot: The snapshot to clone
+//
+// Returns:
+// - AlreadyExistsError if a volume named clone exists
+// - NotFoundError if the snapshot does not exist
+// - ServerUnreachableError if no glusterd answers
+func (c *CLI) CloneSnapshot(ctx context.Context, clone, snapshot string) error {
+	var out cliOutput
+	err := c.run(ctx, &out, "snapshot", "clone", clone, snapshot)
+	if isCommandError(err, errVolumeExists, alreadyExists) {
+		return errors.NewAlreadyExistsError(fmt.Sprintf("volume %s already exists", clone), err)
+	}
+	return snapshotError(snapshot, err)
+}
+
+// DeleteSnapshot deletes a snapshot; script mode answers the
+// confirmation prompt.
+//
+// Parameters:
+// - ctx: Bounds the gluster command
+// - name: The snapshot name
+//
+// Returns:
+// - NotFoundError if the snapshot does not exist
+// - ServerUnreachableError if no glusterd answers
+func (c *CLI) DeleteSnapshot(ctx context.Context, name string) error {
+	var out cliOutput
+	return snapshotError(name, c.run(ctx, &out, "snapshot", "delete", name))
+}
+
+// snapshotError turns the rejection of a command on an unknown snapshot
+// into a NotFoundError.
+func snapshotError(name string, err error) error {
+	if isCommandError(err, errNoSnapshot, doesNotExist) {
+		return errors.NewNotFoundError(fmt.Sprintf("snapshot %s does not exist", name), err)
+	}
+	return err
+}
+
+// volumeError turns the rejection of a command on an unknown volume
+// into a NotFoundError.
+func volumeError(name string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if isCommandError(err, errNoVolume, doesNotExist) {
+		return errors.NewNotFoundError(fmt.Sprintf("volume %s does not exist", name), err)
+	}
+	return err
+}
+
+// isCommandError reports whether err is a command rejected by glusterd
+// with the opErrno errno. Rejections reported without a code are matched
+// by message instead; errno 0 stands for errors glusterd has no code for.
+func isCommandError(err error, errno int, message string) bool {
+	var cmdErr *commandError
+	if !stderrors.As(err, &cmdErr) {
+		return false
+	}
+	if cmdErr.errno != 0 {
+		return cmdErr.errno == errno
+	}
+	return strings.Contains(cmdErr.message, message)
+}
+
+// SetQuota enables quotas on the volume if needed and limits the
+// directory at path to limit bytes.
+//
+// Parameters:
+// - ctx: Bounds the gluster commands
+// - volume: The GlusterFS volume
+// - path: The directory, relative to the volume root
+// - limit: The hard limit in bytes
+//
+// Returns:
+// - ServerUnreachableError if no glusterd answers
+// - error if gluster rejects a command, nil otherwise
+func (c *CLI) SetQuota(ctx context.Context, volume, path string, limit int64) error {
+	var enabled cliOutput
+	err := c.run(ctx, &enabled, "volume", "quota", volume, "enable")
+	if err != nil && !isCommandError(err, 0, quotaAlreadyEnabled) {
+		return err
+	}
+
+	var out cliOutput
+	return c.run(ctx, &out, "volume", "quota", volume, "limit-usage", path, strconv.FormatInt(limit, 10))
+}
+
+// Quota returns the usage of the quota on the directory at path.
+//
+// Parameters:
+// - ctx: Bounds the gluster command
+// - volume: The GlusterFS volume
+// - path: The directory, relative to the volume root
+//
+// Returns:
+// - The quota usage
+// - NotFoundError if the directory has no quota
+// - ServerUnreachableError if no glusterd answers
+func (c *CLI) Quota(ctx context.Context, volume, path string) (*Quota, error) {
+	var out quotaListOutput
+	if err := c.run(ctx, &out, "volume", "quota", volume, "list", path); err != nil {
+		return nil, err
+	}
+
+	for _, limit := range out.Limits {
+		if limit.Path != path {
+			continue
+		}
+		hard, err := strconv.ParseInt(limit.HardLimit, 10, 64)
+		if err != nil {
+			break
+		}
+		// Usage is reported as N/A until the quota daemon crawled the directory
+		used, _ := strconv.ParseInt(limit.UsedSpace, 10, 64)
+		return &Quota{Path: path, Limit: hard, Used: used}, nil
+	}
+	return nil, errors.NewNotFoundError(fmt.Sprintf("no quota on %s of volume %s", path, volume), nil)
+}
+
+// run executes a gluster command and decodes its XML output into out.
+// The servers are tried in order until one of them answers.
+func (c *CLI) run(ctx context.Context, out interface{ result() *cliOutput }, args ...string) error {
+	hosts := c.Servers
+	if len(hosts) == 0 {
+		hosts = []string{""}
+	}
+
+	var lastErr error
+	for _, host := range hosts {
+		cmdArgs := []string{"--mode=script", "--xml"}
+		if host != "" {
+			cmdArgs = append(cmdArgs, "--remote-host="+remoteHost(host))
+		}
+		cmdArgs = append(cmdArgs, args...)
+
+		var stdout, stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, c.Binary, cmdArgs...)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		runErr := cmd.Run()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		if xml.Unmarshal(stdout.Bytes(), out) == nil {
+			if res := out.result(); res.OpRet != 0 {
+				return &commandError{args: args, errno: res.OpErrno, message: res.OpErrstr}
+			}
+			return nil
+		}
+
+		message := strings.TrimSpace(stdout.String() + " " + stderr.String())
+		if runErr == nil {
+			return fmt.Errorf("gluster %s: unexpected output %q", strings.Join(args, " "), message)
+		}
+		if !strings.Contains(message, connectionFailed) {
+			return fmt.Errorf("gluster %s: %v: %s", strings.Join(args, " "), runErr, message)
+		}
+		lastErr = errors.NewServerUnreachableError(fmt.Sprintf("glusterd on %s", hostName(host)), runErr)
+	}
+	return lastErr
+}
+
+// result gives run access to the envelope of any response.
+func (o *cliOutput) result() *cliOutput { return o }
+
+// remoteHost strips the port of a server given as host:port, since the
+// gluster CLI only takes a host name.
+func remoteHost(host string) string {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		return name
+	}
+	return host
+}
+
+// hostName names a glusterd host in errors.
+func hostName(host string) string {
+	if host == "" {
+		return "localhost"
+	}
+	return host
+}
@@ -0,0 +1,326 @@
+package management
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"glusterfs-plugin/internal/errors"
+)
+
+const okOutput = `<cliOutput><opRet>0</opRet><opErrno>0</opErrno><opErrstr/></cliOutput>`
+
+// newTestCLI returns a client running a fake gluster script.
+// The script records its arguments, one call per line, in the returned file.
+func newTestCLI(t *testing.T, servers []string, body string) (*CLI, string) {
+	t.Helper()
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+	script := "#!/bin/sh\necho \"$@\" >> " + calls + "\n" + body + "\n"
+	binary := filepath.Join(dir, "gluster")
+	require.NoError(t, os.WriteFile(binary, []byte(script), 0755))
+
+	c := NewCLI(servers)
+	c.Binary = binary
+	return c, calls
+}
+
+func readCalls(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func TestCLI_SetQuota(t *testing.T) {
+	c, calls := newTestCLI(t, []string{"store1"}, "echo '"+okOutput+"'")
+
+	require.NoError(t, c.SetQuota(context.Background(), "shared", "/team-a", 10<<30))
+	assert.Equal(t, []string{
+		"--mode=script --xml --remote-host=store1 volume quota shared enable",
+		"--mode=script --xml --remote-host=store1 volume quota shared limit-usage /team-a 10737418240",
+	}, readCalls(t, calls))
+}
+
+func TestCLI_SetQuota_AlreadyEnabled(t *testing.T) {
+	c, calls := newTestCLI(t, nil, `
+case "$*" in
+*enable) echo '<cliOutput><opRet>-1</opRet><opErrno>0</opErrno><opErrstr>Quota is already enabled</opErrstr></cliOutput>'; exit 1 ;;
+*) echo '`+okOutput+`' ;;
+esac`)
+
+	require.NoError(t, c.SetQuota(context.Background(), "shared", "/team-a", 1024))
+	assert.Len(t, readCalls(t, calls), 2)
+}
+
+func TestCLI_SetQuota_Rejected(t *testing.T) {
+	c, _ := newTestCLI(t, nil, `
+case "$*" in
+*enable) echo '`+okOutput+`' ;;
+*) echo '<cliOutput><opRet>-1</opRet><opErrno>0</opErrno><opErrstr>Path /team-a does not exist</opErrstr></cliOutput>'; exit 1 ;;
+esac`)
+
+	err := c.SetQuota(context.Background(), "shared", "/team-a", 1024)
+	assert.EqualError(t, err, "gluster volume quota shared limit-usage /team-a 1024: Path /team-a does not exist")
+}
+
+func TestCLI_Quota(t *testing.T) {
+	c, calls := newTestCLI(t, []string{"store1"}, fixtureScript(t, "quota_list.xml"))
+
+	q, err := c.Quota(context.Background(), "shared", "/team-a")
+	require.NoError(t, err)
+	assert.Equal(t, &Quota{Path: "/team-a", Limit: 10 << 30, Used: 1536 << 20}, q)
+	assert.False(t, q.Exceeded())
+	assert.Equal(t, []string{"--mode=script --xml --remote-host=store1 volume quota shared list /team-a"}, readCalls(t, calls))
+
+	_, err = c.Quota(context.Background(), "shared", "/team-b")
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+}
+
+func TestCLI_FallsBackToNextServer(t *testing.T) {
+	c, calls := newTestCLI(t, []string{"store1", "store2"}, `
+case "$*" in
+*remote-host=store1*) echo 'Connection failed. Please check if gluster daemon is operational.'; exit 1 ;;
+*) echo '`+okOutput+`' ;;
+esac`)
+
+	require.NoError(t, c.SetQuota(context.Background(), "shared", "/team-a", 1024))
+	assert.Len(t, readCalls(t, calls), 4)
+}
+
+func TestCLI_Unreachable(t *testing.T) {
+	c, _ := newTestCLI(t, []string{"store1", "store2"},
+		"echo 'Connection failed. Please check if gluster daemon is operational.'; exit 1")
+
+	_, err := c.Quota(context.Background(), "shared", "/team-a")
+	assert.ErrorIs(t, err, errors.ErrServerUnreachable)
+	assert.ErrorContains(t, err, "glusterd on store2")
+}
+
+func TestCLI_Cancelled(t *testing.T) {
+	c, _ := newTestCLI(t, nil, "sleep 5")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := c.Quota(ctx, "shared", "/team-a")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestQuota_Exceeded(t *testing.T) {
+	assert.False(t, (&Quota{Limit: 10, Used: 9}).Exceeded())
+	assert.True(t, (&Quota{Limit: 10, Used: 10}).Exceeded())
+	assert.True(t, (&Quota{Limit: 10, Used: 11}).Exceeded())
+}
+
+// fixtureScript returns a script body printing a file of testdata.
+func fixtureScript(t *testing.T, name string) string {
+	t.Helper()
+	path, err := filepath.Abs(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	return "cat " + path
+}
+
+func TestCLI_Volumes(t *testing.T) {
+	c, calls := newTestCLI(t, []string{"store1"}, fixtureScript(t, "volume_list.xml"))
+
+	volumes, err := c.Volumes(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"media", "shared"}, volumes)
+	assert.Equal(t, []string{"--mode=script --xml --remote-host=store1 volume list"}, readCalls(t, calls))
+}
+
+func TestCLI_Volume(t *testing.T) {
+	c, calls := newTestCLI(t, nil, fixtureScript(t, "volume_info.xml"))
+
+	v, err := c.Volume(context.Background(), "shared")
+	require.NoError(t, err)
+	assert.Equal(t, &Volume{
+		Name:         "shared",
+		ID:           "4f1b7c2e-8a4d-4a53-9a0e-3c1f2b6d9e01",
+		Status:       "Started",
+		Type:         "Replicate",
+		Transport:    "tcp",
+		ReplicaCount: 3,
+		Bricks:       []string{"store1:/bricks/shared", "store2:/bricks/shared", "store3:/bricks/shared"},
+		Options:      map[string]string{"features.quota": "on", "transport.address-family": "inet"},
+	}, v)
+	assert.True(t, v.Started())
+	assert.Equal(t, []string{"--mode=script --xml volume info shared"}, readCalls(t, calls))
+}
+
+func TestCLI_Volume_NotFound(t *testing.T) {
+	c, _ := newTestCLI(t, nil,
+		"echo '<cliOutput><opRet>-1</opRet><opErrno>30806</opErrno><opErrstr>Volume media does not exist</opErrstr></cliOutput>'; exit 1")
+
+	_, err := c.Volume(context.Background(), "media")
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+	assert.EqualError(t, err, "not found error: volume media does not exist (caused by: gluster volume info media: Volume media does not exist)")
+
+	_, err = c.Bricks(context.Background(), "media")
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+}
+
+func TestCLI_Bricks(t *testing.T) {
+	c, calls := newTestCLI(t, nil, fixtureScript(t, "volume_status.xml"))
+
+	bricks, err := c.Bricks(context.Background(), "shared")
+	require.NoError(t, err)
+	assert.Equal(t, []Brick{
+		{Host: "store1", Path: "/bricks/shared", Online: true, Port: 49152, PID: 2381},
+		{Host: "store2", Path: "/bricks/shared"},
+	}, bricks)
+	assert.Equal(t, []string{"--mode=script --xml volume status shared"}, readCalls(t, calls))
+}
+
+func TestCLI_UnexpectedOutput(t *testing.T) {
+	c, _ := newTestCLI(t, nil, "echo 'not xml'")
+
+	_, err := c.Volumes(context.Background())
+	assert.EqualError(t, err, `gluster volume list: unexpected output "not xml"`)
+}
+
+func TestCLI_VolumeLifecycle(t *testing.T) {
+	c, calls := newTestCLI(t, []string{"store1"}, "echo '"+okOutput+"'")
+	ctx := context.Background()
+
+	require.NoError(t, c.CreateVolume(ctx, &VolumeConfig{
+		Name:      "vol1",
+		Bricks:    []string{"store1:/bricks/vol1", "store2:/bricks/vol1", "store3:/bricks/vol1"},
+		Replica:   3,
+		Transport: "tcp",
+	}))
+	require.NoError(t, c.CreateVolume(ctx, &VolumeConfig{Name: "vol2", Bricks: []string{"store1:/bricks/vol2"}}))
+	require.NoError(t, c.StartVolume(ctx, "vol1"))
+	require.NoError(t, c.StopVolume(ctx, "vol1"))
+	require.NoError(t, c.DeleteVolume(ctx, "vol1"))
+
+	assert.Equal(t, []string{
+		"--mode=script --xml --remote-host=store1 volume create vol1 replica 3 transport tcp store1:/bricks/vol1 store2:/bricks/vol1 store3:/bricks/vol1",
+		"--mode=script --xml --remote-host=store1 volume create vol2 store1:/bricks/vol2",
+		"--mode=script --xml --remote-host=store1 volume start vol1",
+		"--mode=script --xml --remote-host=store1 volume stop vol1",
+		"--mode=script --xml --remote-host=store1 volume delete vol1",
+	}, readCalls(t, calls))
+}
+
+func TestCLI_VolumeLifecycleErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		errnos [3]int
+	}{
+		{name: "classified by opErrno", errnos: [3]int{30811, 30810, 30806}},
+		{name: "classified by message without opErrno"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestCLI(t, nil, fmt.Sprintf(`
+fail() { echo "<cliOutput><opRet>-1</opRet><opErrno>$1</opErrno><opErrstr>$2</opErrstr></cliOutput>"; exit 1; }
+case "$*" in
+*create*) fail %d "volume create: vol1: failed: Volume vol1 already exists" ;;
+*stop*) fail %d "volume stop: vol1: failed: Volume vol1 is not in the started state" ;;
+*) fail %d "volume $4: vol1: failed: Volume vol1 does not exist" ;;
+esac`, tt.errnos[0], tt.errnos[1], tt.errnos[2]))
+			ctx := context.Background()
+
+			err := c.CreateVolume(ctx, &VolumeConfig{Name: "vol1", Bricks: []string{"store1:/bricks/vol1"}})
+			assert.ErrorIs(t, err, errors.ErrAlreadyExists)
+
+			// Stopping a stopped volume succeeds
+			assert.NoError(t, c.StopVolume(ctx, "vol1"))
+
+			assert.ErrorIs(t, c.StartVolume(ctx, "vol1"), errors.ErrNotFound)
+			assert.ErrorIs(t, c.DeleteVolume(ctx, "vol1"), errors.ErrNotFound)
+		})
+	}
+}
+
+func TestCLI_ErrnoOverridesMessage(t *testing.T) {
+	// The opErrno of an internal error wins over a message that reads alike
+	c, _ := newTestCLI(t, nil,
+		"echo '<cliOutput><opRet>-1</opRet><opErrno>30800</opErrno><opErrstr>Brick /bricks/already exists is busy</opErrstr></cliOutput>'; exit 1")
+
+	err := c.CreateVolume(context.Background(), &VolumeConfig{Name: "vol1", Bricks: []string{"store1:/bricks/already exists"}})
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, errors.ErrAlreadyExists)
+}
+
+func TestCLI_RemoteHostPort(t *testing.T) {
+	c, calls := newTestCLI(t, []string{"store1:24007", "[fd00::1]:24007", "store2"}, `
+case "$*" in
+*store2*) echo '`+okOutput+`' ;;
+*) echo 'Connection failed. Please check if gluster daemon is operational.'; exit 1 ;;
+esac`)
+
+	_, err := c.Volumes(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"--mode=script --xml --remote-host=store1 volume list",
+		"--mode=script --xml --remote-host=fd00::1 volume list",
+		"--mode=script --xml --remote-host=store2 volume list",
+	}, readCalls(t, calls))
+}
+
+func TestCLI_Snapshot(t *testing.T) {
+	c, calls := newTestCLI(t, nil, fixtureScript(t, "snapshot_info.xml"))
+
+	snap, err := c.Snapshot(context.Background(), "nightly")
+	require.NoError(t, err)
+	assert.Equal(t, &Snapshot{
+		Name:    "nightly",
+		Volume:  "shared",
+		Created: time.Date(2026, 10, 18, 2, 0, 5, 0, time.UTC),
+		Active:  true,
+	}, snap)
+	assert.Equal(t, []string{"--mode=script --xml snapshot info nightly"}, readCalls(t, calls))
+
+	_, err = c.Snapshot(context.Background(), "weekly")
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+}
+
+func TestCLI_SnapshotClone(t *testing.T) {
+	c, calls := newTestCLI(t, []string{"store1"}, "echo '"+okOutput+"'")
+	ctx := context.Background()
+
+	require.NoError(t, c.CreateSnapshot(ctx, "shared", "nightly"))
+	require.NoError(t, c.ActivateSnapshot(ctx, "nightly"))
+	require.NoError(t, c.CloneSnapshot(ctx, "fixture", "nightly"))
+	require.NoError(t, c.DeleteSnapshot(ctx, "nightly"))
+
+	assert.Equal(t, []string{
+		"--mode=script --xml --remote-host=store1 snapshot create nightly shared no-timestamp",
+		"--mode=script --xml --remote-host=store1 snapshot activate nightly",
+		"--mode=script --xml --remote-host=store1 snapshot clone fixture nightly",
+		"--mode=script --xml --remote-host=store1 snapshot delete nightly",
+	}, readCalls(t, calls))
+}
+
+func TestCLI_SnapshotErrors(t *testing.T) {
+	c, _ := newTestCLI(t, nil, `
+fail() { echo "<cliOutput><opRet>-1</opRet><opErrno>0</opErrno><opErrstr>$1</opErrstr></cliOutput>"; exit 1; }
+case "$*" in
+*create*) fail "Snapshot nightly already exists" ;;
+*activate*) fail "Snapshot activate: nightly: Snap is already activated." ;;
+*) fail "Snapshot (nightly) does not exist" ;;
+esac`)
+	ctx := context.Background()
+
+	assert.ErrorIs(t, c.CreateSnapshot(ctx, "shared", "nightly"), errors.ErrAlreadyExists)
+	assert.NoError(t, c.ActivateSnapshot(ctx, "nightly"))
+	assert.ErrorIs(t, c.CloneSnapshot(ctx, "fixture", "nightly"), errors.ErrNotFound)
+	assert.ErrorIs(t, c.DeleteSnapshot(ctx, "nightly"), errors.ErrNotFound)
+}
+
+func TestSnapshotName(t *testing.T) {
+	at := time.Date(2026, 10, 19, 10, 15, 0, 0, time.UTC)
+	first, second := SnapshotName("shared", at), SnapshotName("shared", at)
+	assert.Regexp(t, `^shared-20261019-101500-[0-9a-f]{6}$`, first)
+	assert.NotEqual(t, first, second, "snapshots taken in the same second need distinct names")
+}
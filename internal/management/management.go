@@ -0,0 +1,178 @@
+// Package management talks to the GlusterFS management daemon (glusterd)
+// for cluster introspection and for operations the FUSE client cannot
+// perform, such as directory quotas.
+package management
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// Quota is the usage of a directory quota.
+type Quota struct {
+	// Path is the quota directory, relative to the volume root and
+	// starting with a slash
+	Path string
+
+	// Limit is the hard limit in bytes
+	Limit int64
+
+	// Used is the space used in bytes
+	Used int64
+}
+
+// Exceeded reports whether the directory has reached its hard limit.
+func (q *Quota) Exceeded() bool {
+	return q.Used >= q.Limit
+}
+
+// Volume describes a GlusterFS volume.
+type Volume struct {
+	// Name is the volume name
+	Name string
+
+	// ID is the volume UUID
+	ID string
+
+	// Status is "Created", "Started" or "Stopped"
+	Status string
+
+	// Type is the volume type, such as "Distribute" or "Replicate"
+	Type string
+
+	// Transport is "tcp", "rdma" or "tcp,rdma"
+	Transport string
+
+	// ReplicaCount is the number of replicas of each file
+	ReplicaCount int
+
+	// Bricks are the volume bricks in the "host:/path" form
+	Bricks []string
+
+	// Options are the reconfigured volume options
+	Options map[string]string
+}
+
+// Started reports whether the volume can be mounted.
+func (v *Volume) Started() bool {
+	return v.Status == "Started"
+}
+
+// VolumeConfig describes a volume to create.
+type VolumeConfig struct {
+	// Name is the volume name
+	Name string
+
+	// Bricks are the volume bricks in the "host:/path" form
+	Bricks []string
+
+	// Replica is the number of replicas of each file, 0 or 1 for a
+	// distributed volume
+	Replica int
+
+	// Transport is "tcp", "rdma" or "tcp,rdma", empty for the default
+	Transport string
+}
+
+// Snapshot describes a GlusterFS snapshot.
+type Snapshot struct {
+	// Name is the snapshot name
+	Name string
+
+	// Volume is the volume the snapshot was taken of
+	Volume string
+
+	// Created is when the snapshot was taken
+	Created time.Time
+
+	// Active reports whether the snapshot is activated and can be cloned
+	Active bool
+}
+
+// SnapshotName returns a new name for a snapshot of volume taken at t,
+// such as "shared-20261019-101500-3fa2c1". The random suffix keeps
+// snapshots taken within the same second apart.
+func SnapshotName(volume string, t time.Time) string {
+	var suffix [3]byte
+	if _, err := rand.Read(suffix[:]); err != nil {
+		suffix = [3]byte{byte(t.Nanosecond() >> 16), byte(t.Nanosecond() >> 8), byte(t.Nanosecond())}
+	}
+	return volume + "-" + t.UTC().Format("20060102-150405") + "-" + hex.EncodeToString(suffix[:])
+}
+
+// Brick is the status of a brick process.
+type Brick struct {
+	// Host and Path locate the brick
+	Host string
+	Path string
+
+	// Online reports whether the brick process is running
+	Online bool
+
+	// Port is the TCP port of the brick, 0 if offline
+	Port int
+
+	// PID is the brick process ID, 0 if offline
+	PID int
+}
+
+// Client performs management operations on a GlusterFS cluster.
+type Client interface {
+	// Volumes returns the names of the volumes of the cluster.
+	Volumes(ctx context.Context) ([]string, error)
+
+	// Volume returns a volume. It returns NotFoundError if the volume
+	// does not exist.
+	Volume(ctx context.Context, name string) (*Volume, error)
+
+	// Bricks returns the status of the bricks of a volume. It returns
+	// NotFoundError if the volume does not exist.
+	Bricks(ctx context.Context, name string) ([]Brick, error)
+
+	// CreateVolume creates a volume. It returns AlreadyExistsError if a
+	// volume with the same name exists.
+	CreateVolume(ctx context.Context, config *VolumeConfig) error
+
+	// StartVolume starts a volume so that it can be mounted.
+	StartVolume(ctx context.Context, name string) error
+
+	// StopVolume stops a volume. Stopping a stopped volume succeeds.
+	StopVolume(ctx context.Context, name string) error
+
+	// DeleteVolume deletes a stopped volume. The data on its bricks
+	// is left in place.
+	DeleteVolume(ctx context.Context, name string) error
+
+	// CreateSnapshot takes a snapshot of a volume. It returns
+	// AlreadyExistsError if a snapshot with the same name exists.
+	CreateSnapshot(ctx context.Context, volume, name string) error
+
+	// Snapshot returns a snapshot. It returns NotFoundError if the
+	// snapshot does not exist.
+	Snapshot(ctx context.Context, name string) (*Snapshot, error)
+
+	// ActivateSnapshot activates a snapshot. Activating an active
+	// snapshot succeeds.
+	ActivateSnapshot(ctx context.Context, name string) error
+
+	// CloneSnapshot creates the volume clone from an active snapshot.
+	// The clone has to be started before it can be mounted.
+	CloneSnapshot(ctx context.Context, clone, snapshot string) error
+
+	// DeleteSnapshot deletes a snapshot. It returns NotFoundError if the
+	// snapshot does not exist.
+	DeleteSnapshot(ctx context.Context, name string) error
+
+	// SetQuota enables quotas on the volume if needed and limits the
+	// directory at path to limit bytes.
+	SetQuota(ctx context.Context, volume, path string, limit int64) error
+
+	// Quota returns the usage of the quota on the directory at path.
+	// It returns NotFoundError if the directory has no quota.
+	Quota(ctx context.Context, volume, path string) (*Quota, error)
+}
+
+// Factory creates a client for the cluster reachable through servers.
+type Factory func(servers []string) Client
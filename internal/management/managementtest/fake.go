@@ -0,0 +1,371 @@
+// Package managementtest provides a fake management client for tests of
+// code that manages clusters through the management interface.
+package managementtest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"glusterfs-plugin/internal/errors"
+	"glusterfs-plugin/internal/management"
+)
+
+// Fake is an in-memory management.Client.
+// It keeps the volumes and quotas it was given and records every call.
+type Fake struct {
+	// VolumeErr is returned by Volumes, Volume and Bricks
+	VolumeErr error
+
+	// CreateErr, StartErr, StopErr and DeleteErr are returned by the
+	// volume lifecycle calls
+	CreateErr error
+	StartErr  error
+	StopErr   error
+	DeleteErr error
+
+	// SnapshotErr is returned by the snapshot calls
+	SnapshotErr error
+
+	// SetQuotaErr and QuotaErr are returned by the matching calls
+	SetQuotaErr error
+	QuotaErr    error
+
+	// Delay makes Volumes, Volume and Quota take this long, like a slow
+	// glusterd, honouring cancellation
+	Delay time.Duration
+
+	mu        sync.Mutex
+	volumes   map[string]*management.Volume
+	snapshots map[string]*management.Snapshot
+	bricks    map[string][]management.Brick
+	quotas    map[string]*management.Quota
+	calls     []string
+}
+
+var _ management.Client = (*Fake)(nil)
+
+// New creates an empty fake client.
+func New() *Fake {
+	return &Fake{
+		volumes:   make(map[string]*management.Volume),
+		snapshots: make(map[string]*management.Snapshot),
+		bricks:    make(map[string][]management.Brick),
+		quotas:    make(map[string]*management.Quota),
+	}
+}
+
+// AddVolume adds a volume to the cluster, with the status of its bricks.
+func (f *Fake) AddVolume(v *management.Volume, bricks ...management.Brick) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.volumes[v.Name] = v
+	f.bricks[v.Name] = bricks
+}
+
+// Volumes returns the names of the added volumes, sorted.
+func (f *Fake) Volumes(ctx context.Context) ([]string, error) {
+	if err := f.wait(ctx); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, "volumes")
+	if f.VolumeErr != nil {
+		return nil, f.VolumeErr
+	}
+	names := make([]string, 0, len(f.volumes))
+	for name := range f.volumes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Volume returns a copy of an added volume.
+func (f *Fake) Volume(ctx context.Context, name string) (*management.Volume, error) {
+	if err := f.wait(ctx); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, "volume "+name)
+	if f.VolumeErr != nil {
+		return nil, f.VolumeErr
+	}
+	v, ok := f.volumes[name]
+	if !ok {
+		return nil, errors.NewNotFoundError(fmt.Sprintf("volume %s does not exist", name), nil)
+	}
+	copied := *v
+	return &copied, nil
+}
+
+// Bricks returns the brick status given to AddVolume.
+func (f *Fake) Bricks(ctx context.Context, name string) ([]management.Brick, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, "bricks "+name)
+	if f.VolumeErr != nil {
+		return nil, f.VolumeErr
+	}
+	if _, ok := f.volumes[name]; !ok {
+		return nil, errors.NewNotFoundError(fmt.Sprintf("volume %s does not exist", name), nil)
+	}
+	return append([]management.Brick(nil), f.bricks[name]...), nil
+}
+
+// Factory returns a management.Factory that always returns f.
+func (f *Fake) Factory() management.Factory {
+	return func([]string) management.Client { return f }
+}
+
+// CreateVolume adds a volume in the Created state.
+func (f *Fake) CreateVolume(ctx context.Context, config *management.VolumeConfig) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, fmt.Sprintf("create %s replica=%d transport=%s %s",
+		config.Name, config.Replica, config.Transport, strings.Join(config.Bricks, " ")))
+	if f.CreateErr != nil {
+		return f.CreateErr
+	}
+	if _, ok := f.volumes[config.Name]; ok {
+		return errors.NewAlreadyExistsError(fmt.Sprintf("volume %s already exists", config.Name), nil)
+	}
+	f.volumes[config.Name] = &management.Volume{
+		Name:         config.Name,
+		Status:       "Created",
+		Transport:    config.Transport,
+		ReplicaCount: config.Replica,
+		Bricks:       append([]string(nil), config.Bricks...),
+		Options:      map[string]string{},
+	}
+	return nil
+}
+
+// StartVolume moves a volume to the Started state.
+func (f *Fake) StartVolume(ctx context.Context, name string) error {
+	return f.setStatus("start", name, "Started", f.StartErr)
+}
+
+// StopVolume moves a volume to the Stopped state.
+func (f *Fake) StopVolume(ctx context.Context, name string) error {
+	return f.setStatus("stop", name, "Stopped", f.StopErr)
+}
+
+func (f *Fake) setStatus(call, name, status string, err error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, call+" "+name)
+	if err != nil {
+		return err
+	}
+	v, ok := f.volumes[name]
+	if !ok {
+		return errors.NewNotFoundError(fmt.Sprintf("volume %s does not exist", name), nil)
+	}
+	v.Status = status
+	return nil
+}
+
+// DeleteVolume removes a volume that is not started.
+func (f *Fake) DeleteVolume(ctx context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, "delete "+name)
+	if f.DeleteErr != nil {
+		return f.DeleteErr
+	}
+	v, ok := f.volumes[name]
+	if !ok {
+		return errors.NewNotFoundError(fmt.Sprintf("volume %s does not exist", name), nil)
+	}
+	if v.Started() {
+		return fmt.Errorf("volume %s needs to be stopped before deletion", name)
+	}
+	delete(f.volumes, name)
+	delete(f.bricks, name)
+	return nil
+}
+
+// AddSnapshot adds a snapshot to the cluster.
+func (f *Fake) AddSnapshot(snap *management.Snapshot) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.snapshots[snap.Name] = snap
+}
+
+// CreateSnapshot adds an inactive snapshot of an added volume, taken now.
+func (f *Fake) CreateSnapshot(ctx context.Context, volume, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, fmt.Sprintf("snapshot %s %s", volume, name))
+	if f.SnapshotErr != nil {
+		return f.SnapshotErr
+	}
+	if _, ok := f.volumes[volume]; !ok {
+		return errors.NewNotFoundError(fmt.Sprintf("volume %s does not exist", volume), nil)
+	}
+	if _, ok := f.snapshots[name]; ok {
+		return errors.NewAlreadyExistsError(fmt.Sprintf("snapshot %s already exists", name), nil)
+	}
+	f.snapshots[name] = &management.Snapshot{Name: name, Volume: volume, Created: time.Now().UTC().Truncate(time.Second)}
+	return nil
+}
+
+// Snapshot returns a copy of a snapshot.
+func (f *Fake) Snapshot(ctx context.Context, name string) (*management.Snapshot, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, "snapshot-info "+name)
+	if f.SnapshotErr != nil {
+		return nil, f.SnapshotErr
+	}
+	snap, ok := f.snapshots[name]
+	if !ok {
+		return nil, errors.NewNotFoundError(fmt.Sprintf("snapshot %s does not exist", name), nil)
+	}
+	copied := *snap
+	return &copied, nil
+}
+
+// ActivateSnapshot marks a snapshot active.
+func (f *Fake) ActivateSnapshot(ctx context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, "activate "+name)
+	if f.SnapshotErr != nil {
+		return f.SnapshotErr
+	}
+	snap, ok := f.snapshots[name]
+	if !ok {
+		return errors.NewNotFoundError(fmt.Sprintf("snapshot %s does not exist", name), nil)
+	}
+	snap.Active = true
+	return nil
+}
+
+// DeleteSnapshot removes a snapshot.
+func (f *Fake) DeleteSnapshot(ctx context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, "delete-snapshot "+name)
+	if f.SnapshotErr != nil {
+		return f.SnapshotErr
+	}
+	if _, ok := f.snapshots[name]; !ok {
+		return errors.NewNotFoundError(fmt.Sprintf("snapshot %s does not exist", name), nil)
+	}
+	delete(f.snapshots, name)
+	return nil
+}
+
+// CloneSnapshot adds a volume in the Created state cloned from an
+// active snapshot.
+func (f *Fake) CloneSnapshot(ctx context.Context, clone, snapshot string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, fmt.Sprintf("clone %s %s", clone, snapshot))
+	if f.SnapshotErr != nil {
+		return f.SnapshotErr
+	}
+	snap, ok := f.snapshots[snapshot]
+	if !ok {
+		return errors.NewNotFoundError(fmt.Sprintf("snapshot %s does not exist", snapshot), nil)
+	}
+	if !snap.Active {
+		return fmt.Errorf("snapshot %s is not activated", snapshot)
+	}
+	if _, ok := f.volumes[clone]; ok {
+		return errors.NewAlreadyExistsError(fmt.Sprintf("volume %s already exists", clone), nil)
+	}
+	f.volumes[clone] = &management.Volume{Name: clone, Status: "Created", Options: map[string]string{}}
+	return nil
+}
+
+// SetQuota records the quota, keeping the space already used.
+func (f *Fake) SetQuota(ctx context.Context, volume, path string, limit int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, fmt.Sprintf("set-quota %s %s %d", volume, path, limit))
+	if f.SetQuotaErr != nil {
+		return f.SetQuotaErr
+	}
+	key := quotaKey(volume, path)
+	if q, ok := f.quotas[key]; ok {
+		q.Limit = limit
+	} else {
+		f.quotas[key] = &management.Quota{Path: path, Limit: limit}
+	}
+	return nil
+}
+
+// Quota returns a copy of the recorded quota.
+func (f *Fake) Quota(ctx context.Context, volume, path string) (*management.Quota, error) {
+	if err := f.wait(ctx); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, fmt.Sprintf("quota %s %s", volume, path))
+	if f.QuotaErr != nil {
+		return nil, f.QuotaErr
+	}
+	q, ok := f.quotas[quotaKey(volume, path)]
+	if !ok {
+		return nil, errors.NewNotFoundError(fmt.Sprintf("no quota on %s of volume %s", path, volume), nil)
+	}
+	copied := *q
+	return &copied, nil
+}
+
+// SetUsed sets the space used under a quota set with SetQuota.
+func (f *Fake) SetUsed(volume, path string, used int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if q, ok := f.quotas[quotaKey(volume, path)]; ok {
+		q.Used = used
+	}
+}
+
+// Calls returns the calls made so far, in order.
+func (f *Fake) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+// wait waits for Delay, or until ctx is done.
+func (f *Fake) wait(ctx context.Context) error {
+	if f.Delay <= 0 {
+		return nil
+	}
+	select {
+	case <-time.After(f.Delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func quotaKey(volume, path string) string {
+	return volume + ":" + path
+}
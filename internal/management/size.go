@@ -0,0 +1,64 @@
+package management
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"glusterfs-plugin/internal/errors"
+)
+
+// sizeUnits are the size suffixes understood by ParseSize. Like the
+// gluster CLI they are binary multiples, so 1K is 1024 bytes.
+var sizeUnits = []struct {
+	suffix string
+	factor int64
+}{
+	{"P", 1 << 50},
+	{"T", 1 << 40},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+}
+
+// ParseSize parses a size such as "10G", "512MB", "1.5T" or "1048576".
+// Suffixes are case-insensitive and may end with "B" or "iB".
+//
+// Parameters:
+// - s: The size to parse
+//
+// Returns:
+// - The size in bytes
+// - ValidationError if s is not a positive size
+func ParseSize(s string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	value = strings.TrimSuffix(strings.TrimSuffix(value, "B"), "I")
+
+	factor := int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			value, factor = strings.TrimSuffix(value, unit.suffix), unit.factor
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	bytes := n * float64(factor)
+	if err != nil || math.IsNaN(bytes) || bytes < 1 || bytes >= math.MaxInt64 {
+		return 0, errors.NewValidationError(fmt.Sprintf("invalid size %q, must be a positive size such as 10G", s))
+	}
+	return int64(bytes), nil
+}
+
+// FormatSize renders a size in bytes in the largest unit that fits,
+// rounded to one decimal, such as "10G" or "1.5M".
+func FormatSize(bytes int64) string {
+	for _, unit := range sizeUnits {
+		if bytes >= unit.factor {
+			n := math.Round(float64(bytes)/float64(unit.factor)*10) / 10
+			return strconv.FormatFloat(n, 'f', -1, 64) + unit.suffix
+		}
+	}
+	return strconv.FormatInt(bytes, 10)
+}
@@ -0,0 +1,53 @@
+package management
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"glusterfs-plugin/internal/errors"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{input: "1048576", want: 1 << 20},
+		{input: "10G", want: 10 << 30},
+		{input: "10g", want: 10 << 30},
+		{input: "512MB", want: 512 << 20},
+		{input: "2GiB", want: 2 << 30},
+		{input: "1.5T", want: 3 << 39},
+		{input: "4K", want: 4096},
+		{input: " 1P ", want: 1 << 50},
+		{input: "", wantErr: true},
+		{input: "0", wantErr: true},
+		{input: "-1G", wantErr: true},
+		{input: "ten", wantErr: true},
+		{input: "10X", wantErr: true},
+		{input: "99999999P", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseSize(tt.input)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, errors.ErrValidation)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	assert.Equal(t, "512", FormatSize(512))
+	assert.Equal(t, "4K", FormatSize(4096))
+	assert.Equal(t, "1.5G", FormatSize(1536<<20))
+	assert.Equal(t, "10G", FormatSize(10<<30))
+	assert.Equal(t, "1.3M", FormatSize(4<<20/3))
+}
@@ -0,0 +1,165 @@
+package utils
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"glusterfs-plugin/internal/errors"
+	"glusterfs-plugin/pkg/volume"
+)
+
+const (
+	// pluginContentType is the media type of Docker plugin API messages.
+	pluginContentType = "application/vnd.docker.plugins.v1.2+json"
+)
+
+// activateResponse is the response to Plugin.Activate.
+type activateResponse struct {
+	Implements []string
+}
+
+// nameRequest is the body of requests that only carry a volume name.
+type nameRequest struct {
+	Name string
+}
+
+// errorResponse is the body of responses that only carry an error.
+type errorResponse struct {
+	Err string
+}
+
+// mountResponse is the response to VolumeDriver.Mount and VolumeDriver.Path.
+type mountResponse struct {
+	Mountpoint string
+	Err        string
+}
+
+// listResponse is the response to VolumeDriver.List.
+type listResponse struct {
+	Volumes []*volume.Volume
+	Err     string
+}
+
+// getResponse is the response to VolumeDriver.Get.
+type getResponse struct {
+	Volume *volume.Volume `json:",omitempty"`
+	Err    string
+}
+
+// capabilitiesResponse is the response to VolumeDriver.Capabilities.
+type capabilitiesResponse struct {
+	Capabilities volume.Capability
+}
+
+// NewHandler returns an http.Handler implementing the Docker volume plugin
+// protocol on top of the given driver.
+// Each request's context is passed to the driver, so operations are
+// cancelled when Docker gives up on a request.
+//
+// Parameters:
+// - driver: The volume driver implementation
+//
+// Returns:
+// - The protocol handler
+func NewHandler(driver volume.Driver) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/Plugin.Activate", func(w http.ResponseWriter, r *http.Request) {
+		writeResponse(w, activateResponse{Implements: []string{"VolumeDriver"}})
+	})
+
+	mux.HandleFunc("/VolumeDriver.Create", func(w http.ResponseWriter, r *http.Request) {
+		var req volume.CreateRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		if req.Options == nil {
+			req.Options = map[string]string{}
+		}
+		writeResponse(w, errorResponse{Err: errors.Response(driver.Create(&req))})
+	})
+
+	mux.HandleFunc("/VolumeDriver.Remove", func(w http.ResponseWriter, r *http.Request) {
+		var req nameRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		writeResponse(w, errorResponse{Err: errors.Response(driver.Remove(req.Name))})
+	})
+
+	mux.HandleFunc("/VolumeDriver.List", func(w http.ResponseWriter, r *http.Request) {
+		volumes, err := driver.List()
+		if volumes == nil {
+			volumes = []*volume.Volume{}
+		}
+		writeResponse(w, listResponse{Volumes: volumes, Err: errors.Response(err)})
+	})
+
+	mux.HandleFunc("/VolumeDriver.Get", func(w http.ResponseWriter, r *http.Request) {
+		var req nameRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		v, err := driver.Get(req.Name)
+		writeResponse(w, getResponse{Volume: v, Err: errors.Response(err)})
+	})
+
+	mux.HandleFunc("/VolumeDriver.Path", func(w http.ResponseWriter, r *http.Request) {
+		var req nameRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		mountpoint, err := driver.Path(req.Name)
+		writeResponse(w, mountResponse{Mountpoint: mountpoint, Err: errors.Response(err)})
+	})
+
+	mux.HandleFunc("/VolumeDriver.Mount", func(w http.ResponseWriter, r *http.Request) {
+		var req volume.MountRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		mountpoint, err := driver.Mount(r.Context(), &req)
+		writeResponse(w, mountResponse{Mountpoint: mountpoint, Err: errors.Response(err)})
+	})
+
+	mux.HandleFunc("/VolumeDriver.Unmount", func(w http.ResponseWriter, r *http.Request) {
+		var req volume.MountRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+		writeResponse(w, errorResponse{Err: errors.Response(driver.Unmount(r.Context(), &req))})
+	})
+
+	mux.HandleFunc("/VolumeDriver.Capabilities", func(w http.ResponseWriter, r *http.Request) {
+		writeResponse(w, capabilitiesResponse{Capabilities: driver.Capabilities()})
+	})
+
+	return mux
+}
+
+// decodeRequest decodes the JSON body of a request into v.
+// On failure it writes an error response and returns false.
+func decodeRequest(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		err = errors.NewValidationError("invalid request body: " + err.Error())
+		writeStatus(w, http.StatusBadRequest, errorResponse{Err: errors.Response(err)})
+		return false
+	}
+	return true
+}
+
+// writeResponse writes v as a successful JSON plugin response.
+// Driver errors are reported in the Err field, not the status code.
+func writeResponse(w http.ResponseWriter, v interface{}) {
+	writeStatus(w, http.StatusOK, v)
+}
+
+// writeStatus writes v as a JSON plugin response with the given status.
+func writeStatus(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", pluginContentType)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
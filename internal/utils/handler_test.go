@@ -0,0 +1,233 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"glusterfs-plugin/internal/errors"
+	"glusterfs-plugin/pkg/volume"
+)
+
+// fakeDriver is an in-memory volume.Driver recording the calls it receives.
+type fakeDriver struct {
+	created  []*volume.CreateRequest
+	mountCtx context.Context
+	volumes  map[string]*volume.Volume
+	mountErr error
+}
+
+var _ volume.Driver = (*fakeDriver)(nil)
+
+func (f *fakeDriver) Validate(req *volume.CreateRequest) error { return nil }
+
+func (f *fakeDriver) MountOptions(req *volume.CreateRequest) []string { return nil }
+
+func (f *fakeDriver) PreMount(req *volume.MountRequest) error { return nil }
+
+func (f *fakeDriver) PostMount(req *volume.MountRequest) error { return nil }
+
+func (f *fakeDriver) Create(req *volume.CreateRequest) error {
+	f.created = append(f.created, req)
+	if _, ok := req.Options["invalid"]; ok {
+		return errors.NewValidationError("invalid option")
+	}
+	return nil
+}
+
+func (f *fakeDriver) Get(name string) (*volume.Volume, error) {
+	if v, ok := f.volumes[name]; ok {
+		return v, nil
+	}
+	return nil, errors.NewNotFoundError("volume "+name+" does not exist", nil)
+}
+
+func (f *fakeDriver) Remove(name string) error {
+	if _, ok := f.volumes[name]; !ok {
+		return errors.NewNotFoundError("volume "+name+" does not exist", nil)
+	}
+	delete(f.volumes, name)
+	return nil
+}
+
+func (f *fakeDriver) List() ([]*volume.Volume, error) {
+	var volumes []*volume.Volume
+	for _, v := range f.volumes {
+		volumes = append(volumes, &volume.Volume{Name: v.Name, Mountpoint: v.Mountpoint})
+	}
+	return volumes, nil
+}
+
+func (f *fakeDriver) Path(name string) (string, error) {
+	v, err := f.Get(name)
+	if err != nil {
+		return "", err
+	}
+	return v.Mountpoint, nil
+}
+
+func (f *fakeDriver) Capabilities() volume.Capability {
+	return volume.Capability{Scope: "global"}
+}
+
+func (f *fakeDriver) Mount(ctx context.Context, req *volume.MountRequest) (string, error) {
+	f.mountCtx = ctx
+	if f.mountErr != nil {
+		return "", f.mountErr
+	}
+	return "/mnt/" + req.Name, nil
+}
+
+func (f *fakeDriver) Unmount(ctx context.Context, req *volume.MountRequest) error {
+	return nil
+}
+
+func post(t *testing.T, h http.Handler, path, body string) map[string]interface{} {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	assert.Equal(t, pluginContentType, rec.Header().Get("Content-Type"))
+
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp
+}
+
+func TestHandler(t *testing.T) {
+	driver := &fakeDriver{
+		volumes: map[string]*volume.Volume{
+			"vol1": {Name: "vol1", Mountpoint: "/mnt/vol1", Status: map[string]interface{}{"mounted": true}},
+		},
+	}
+	h := NewHandler(driver)
+
+	tests := []struct {
+		name string
+		path string
+		body string
+		want map[string]interface{}
+	}{
+		{
+			name: "activate",
+			path: "/Plugin.Activate",
+			want: map[string]interface{}{"Implements": []interface{}{"VolumeDriver"}},
+		},
+		{
+			name: "create",
+			path: "/VolumeDriver.Create",
+			body: `{"Name":"vol1","Opts":{"servers":"a"}}`,
+			want: map[string]interface{}{"Err": ""},
+		},
+		{
+			name: "create rejected",
+			path: "/VolumeDriver.Create",
+			body: `{"Name":"vol1","Opts":{"invalid":"x"}}`,
+			want: map[string]interface{}{"Err": "VALIDATION: validation error: invalid option"},
+		},
+		{
+			name: "get",
+			path: "/VolumeDriver.Get",
+			body: `{"Name":"vol1"}`,
+			want: map[string]interface{}{
+				"Volume": map[string]interface{}{
+					"Name":       "vol1",
+					"Mountpoint": "/mnt/vol1",
+					"Status":     map[string]interface{}{"mounted": true},
+				},
+				"Err": "",
+			},
+		},
+		{
+			name: "get unknown",
+			path: "/VolumeDriver.Get",
+			body: `{"Name":"missing"}`,
+			want: map[string]interface{}{"Err": "NOT_FOUND: not found error: volume missing does not exist"},
+		},
+		{
+			name: "path",
+			path: "/VolumeDriver.Path",
+			body: `{"Name":"vol1"}`,
+			want: map[string]interface{}{"Mountpoint": "/mnt/vol1", "Err": ""},
+		},
+		{
+			name: "mount",
+			path: "/VolumeDriver.Mount",
+			body: `{"Name":"vol1","ID":"abc"}`,
+			want: map[string]interface{}{"Mountpoint": "/mnt/vol1", "Err": ""},
+		},
+		{
+			name: "unmount",
+			path: "/VolumeDriver.Unmount",
+			body: `{"Name":"vol1","ID":"abc"}`,
+			want: map[string]interface{}{"Err": ""},
+		},
+		{
+			name: "list",
+			path: "/VolumeDriver.List",
+			want: map[string]interface{}{
+				"Volumes": []interface{}{map[string]interface{}{"Name": "vol1", "Mountpoint": "/mnt/vol1"}},
+				"Err":     "",
+			},
+		},
+		{
+			name: "path unknown",
+			path: "/VolumeDriver.Path",
+			body: `{"Name":"missing"}`,
+			want: map[string]interface{}{"Mountpoint": "", "Err": "NOT_FOUND: not found error: volume missing does not exist"},
+		},
+		{
+			name: "remove",
+			path: "/VolumeDriver.Remove",
+			body: `{"Name":"vol1"}`,
+			want: map[string]interface{}{"Err": ""},
+		},
+		{
+			name: "list empty",
+			path: "/VolumeDriver.List",
+			want: map[string]interface{}{"Volumes": []interface{}{}, "Err": ""},
+		},
+		{
+			name: "capabilities",
+			path: "/VolumeDriver.Capabilities",
+			want: map[string]interface{}{"Capabilities": map[string]interface{}{"Scope": "global"}},
+		},
+		{
+			name: "malformed body",
+			path: "/VolumeDriver.Mount",
+			body: `{`,
+			want: map[string]interface{}{"Err": "VALIDATION: validation error: invalid request body: unexpected EOF"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, post(t, h, tt.path, tt.body))
+		})
+	}
+
+	// Create options are decoded from the Opts field
+	require.NotEmpty(t, driver.created)
+	assert.Equal(t, map[string]string{"servers": "a"}, driver.created[0].Options)
+}
+
+func TestHandler_MountUsesRequestContext(t *testing.T) {
+	driver := &fakeDriver{mountErr: errors.NewTimeoutError("mount timed out", context.DeadlineExceeded)}
+	h := NewHandler(driver)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req := httptest.NewRequest(http.MethodPost, "/VolumeDriver.Mount", strings.NewReader(`{"Name":"vol1"}`)).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	require.NotNil(t, driver.mountCtx)
+	cancel()
+	assert.ErrorIs(t, driver.mountCtx.Err(), context.Canceled)
+	assert.Contains(t, rec.Body.String(), `"Err":"TIMEOUT: timeout error: mount timed out (caused by: context deadline exceeded)"`)
+}
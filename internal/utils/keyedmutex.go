@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"sync"
+)
+
+// KeyedMutex serializes operations per key while letting operations on
+// different keys run in parallel.
+// Locks are created on demand and released once no goroutine holds or
+// waits for them, so the set of keys does not grow without bound.
+type KeyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+// keyedLock is the mutex of a single key and the number of its users.
+type keyedLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// NewKeyedMutex creates an empty KeyedMutex.
+//
+// Returns:
+// - A new KeyedMutex
+func NewKeyedMutex() *KeyedMutex {
+	return &KeyedMutex{locks: make(map[string]*keyedLock)}
+}
+
+// Lock acquires the lock of the given key, blocking until it is available.
+//
+// Parameters:
+// - key: The key to lock, usually a volume name
+//
+// Returns:
+// - A function releasing the lock, which must be called exactly once
+func (m *KeyedMutex) Lock(key string) func() {
+	m.mu.Lock()
+	lock, ok := m.locks[key]
+	if !ok {
+		lock = &keyedLock{}
+		m.locks[key] = lock
+	}
+	lock.refs++
+	m.mu.Unlock()
+
+	lock.mu.Lock()
+
+	return func() {
+		lock.mu.Unlock()
+
+		m.mu.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(m.locks, key)
+		}
+		m.mu.Unlock()
+	}
+}
+
+// Len returns the number of keys currently held or waited for.
+func (m *KeyedMutex) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.locks)
+}
@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyedMutex_SerializesSameKey(t *testing.T) {
+	m := NewKeyedMutex()
+
+	var active, maxActive int32
+	var counter int // deliberately unsynchronized, guarded by the keyed lock
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock := m.Lock("vol1")
+			defer unlock()
+
+			n := atomic.AddInt32(&active, 1)
+			for {
+				max := atomic.LoadInt32(&maxActive)
+				if n <= max || atomic.CompareAndSwapInt32(&maxActive, max, n) {
+					break
+				}
+			}
+			counter++
+			atomic.AddInt32(&active, -1)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 100, counter)
+	assert.Equal(t, int32(1), maxActive)
+	assert.Equal(t, 0, m.Len(), "unused locks should be released")
+}
+
+func TestKeyedMutex_DifferentKeysRunInParallel(t *testing.T) {
+	m := NewKeyedMutex()
+
+	unlock := m.Lock("vol1")
+	defer unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		other := m.Lock("vol2")
+		other()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("lock on a different key blocked")
+	}
+	assert.Equal(t, 1, m.Len())
+}
+
+func TestKeyedMutex_BlocksSameKey(t *testing.T) {
+	m := NewKeyedMutex()
+	unlock := m.Lock("vol1")
+
+	acquired := make(chan struct{})
+	go func() {
+		second := m.Lock("vol1")
+		close(acquired)
+		second()
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("second lock acquired while first was held")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("second lock not acquired after release")
+	}
+}
@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"sync"
+)
+
+const (
+	// DefaultLogBufferLines is the number of lines kept per volume.
+	DefaultLogBufferLines = 200
+
+	// maxLogLineLength bounds the size of a single stored line.
+	maxLogLineLength = 1024
+)
+
+// LogBuffer is a size-bounded ring buffer of log lines.
+// Once full, the oldest lines are overwritten.
+type LogBuffer struct {
+	mu    sync.Mutex
+	lines []string
+	next  int
+	full  bool
+}
+
+// NewLogBuffer creates a ring buffer holding at most size lines.
+//
+// Parameters:
+// - size: The maximum number of lines, DefaultLogBufferLines if not positive
+//
+// Returns:
+// - A new, empty LogBuffer
+func NewLogBuffer(size int) *LogBuffer {
+	if size <= 0 {
+		size = DefaultLogBufferLines
+	}
+	return &LogBuffer{lines: make([]string, size)}
+}
+
+// Append adds a line to the buffer, truncating overly long lines.
+//
+// Parameters:
+// - line: The log line to store
+func (b *LogBuffer) Append(line string) {
+	if len(line) > maxLogLineLength {
+		line = line[:maxLogLineLength] + "..."
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lines[b.next] = line
+	b.next = (b.next + 1) % len(b.lines)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// Tail returns up to n of the most recent lines, oldest first.
+//
+// Parameters:
+// - n: The maximum number of lines to return, all lines if not positive
+//
+// Returns:
+// - The most recent lines
+func (b *LogBuffer) Tail(n int) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	count := b.next
+	if b.full {
+		count = len(b.lines)
+	}
+	if n <= 0 || n > count {
+		n = count
+	}
+
+	tail := make([]string, 0, n)
+	start := b.next - n
+	if start < 0 {
+		start += len(b.lines)
+	}
+	for i := 0; i < n; i++ {
+		tail = append(tail, b.lines[(start+i)%len(b.lines)])
+	}
+	return tail
+}
+
+// Len returns the number of lines currently stored.
+func (b *LogBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.full {
+		return len(b.lines)
+	}
+	return b.next
+}
+
+// ClientLogs keeps a LogBuffer per volume for glusterfs client output.
+type ClientLogs struct {
+	mu      sync.Mutex
+	size    int
+	buffers map[string]*LogBuffer
+}
+
+// NewClientLogs creates an empty per-volume log store.
+//
+// Parameters:
+// - size: The number of lines kept for each volume
+//
+// Returns:
+// - A new ClientLogs instance
+func NewClientLogs(size int) *ClientLogs {
+	return &ClientLogs{
+		size:    size,
+		buffers: make(map[string]*LogBuffer),
+	}
+}
+
+// Append adds a line to the buffer of the given volume.
+//
+// Parameters:
+// - volumeName: The volume the line belongs to
+// - line: The log line to store
+func (c *ClientLogs) Append(volumeName, line string) {
+	c.mu.Lock()
+	buf, ok := c.buffers[volumeName]
+	if !ok {
+		buf = NewLogBuffer(c.size)
+		c.buffers[volumeName] = buf
+	}
+	c.mu.Unlock()
+
+	buf.Append(line)
+}
+
+// Tail returns up to n of the most recent lines logged for a volume.
+//
+// Parameters:
+// - volumeName: The volume to read
+// - n: The maximum number of lines, all lines if not positive
+//
+// Returns:
+// - The most recent lines, nil if nothing was logged for the volume
+func (c *ClientLogs) Tail(volumeName string, n int) []string {
+	c.mu.Lock()
+	buf, ok := c.buffers[volumeName]
+	c.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+	return buf.Tail(n)
+}
+
+// Reset discards all lines logged for a volume.
+//
+// Parameters:
+// - volumeName: The volume to reset
+func (c *ClientLogs) Reset(volumeName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.buffers, volumeName)
+}
@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogBuffer(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		lines   []string
+		tail    int
+		want    []string
+		wantLen int
+	}{
+		{
+			name:    "empty buffer",
+			size:    3,
+			tail:    5,
+			want:    []string{},
+			wantLen: 0,
+		},
+		{
+			name:    "partially filled",
+			size:    3,
+			lines:   []string{"a", "b"},
+			tail:    5,
+			want:    []string{"a", "b"},
+			wantLen: 2,
+		},
+		{
+			name:    "wrapped keeps most recent",
+			size:    3,
+			lines:   []string{"a", "b", "c", "d", "e"},
+			tail:    0,
+			want:    []string{"c", "d", "e"},
+			wantLen: 3,
+		},
+		{
+			name:    "tail smaller than content",
+			size:    3,
+			lines:   []string{"a", "b", "c", "d"},
+			tail:    2,
+			want:    []string{"c", "d"},
+			wantLen: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := NewLogBuffer(tt.size)
+			for _, line := range tt.lines {
+				buf.Append(line)
+			}
+			assert.Equal(t, tt.want, buf.Tail(tt.tail))
+			assert.Equal(t, tt.wantLen, buf.Len())
+		})
+	}
+}
+
+func TestLogBuffer_TruncatesLongLines(t *testing.T) {
+	buf := NewLogBuffer(1)
+	buf.Append(strings.Repeat("x", maxLogLineLength*2))
+	assert.Len(t, buf.Tail(1)[0], maxLogLineLength+len("..."))
+}
+
+func TestClientLogs(t *testing.T) {
+	logs := NewClientLogs(2)
+	logs.Append("vol1", "a")
+	logs.Append("vol1", "b")
+	logs.Append("vol1", "c")
+	logs.Append("vol2", "x")
+
+	assert.Equal(t, []string{"b", "c"}, logs.Tail("vol1", 0))
+	assert.Equal(t, []string{"x"}, logs.Tail("vol2", 10))
+	assert.Nil(t, logs.Tail("unknown", 10))
+
+	logs.Reset("vol1")
+	assert.Nil(t, logs.Tail("vol1", 10))
+}
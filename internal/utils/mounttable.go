@@ -0,0 +1,163 @@
+package utils
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// unresolvedTTL is how long a PID that could not be resolved is not
+	// looked up again
+	unresolvedTTL = time.Minute
+
+	// maxUnresolved bounds the number of unresolved PIDs remembered
+	maxUnresolved = 1024
+)
+
+// MountTable keeps track of which volume is mounted at which mount point and
+// maps glusterfs client processes to those mounts.
+// It is used to attribute client log messages to volumes.
+type MountTable struct {
+	mu sync.RWMutex
+
+	// volumes maps mount points to volume names
+	volumes map[string]string
+
+	// pids maps client PIDs to the mount point they serve
+	pids map[int]string
+
+	// unresolved maps PIDs that could not be resolved to the time they
+	// were looked up, since any local process can write to /dev/log
+	unresolved map[int]time.Time
+
+	// procRoot is the proc filesystem used to inspect client command lines
+	procRoot string
+
+	// now returns the current time, replaced in tests
+	now func() time.Time
+}
+
+// NewMountTable creates an empty mount table.
+//
+// Returns:
+// - A new MountTable reading process information from /proc
+func NewMountTable() *MountTable {
+	return &MountTable{
+		volumes:    make(map[string]string),
+		pids:       make(map[int]string),
+		unresolved: make(map[int]time.Time),
+		procRoot:   "/proc",
+		now:        time.Now,
+	}
+}
+
+// Register records that a volume is mounted at the given mount point.
+//
+// Parameters:
+// - mountpoint: The absolute path of the mount
+// - volumeName: The name of the mounted volume
+func (t *MountTable) Register(mountpoint, volumeName string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.volumes[filepath.Clean(mountpoint)] = volumeName
+
+	// The clients of the new mount may reuse a PID that did not resolve
+	t.unresolved = make(map[int]time.Time)
+}
+
+// Unregister removes a mount point and any client PIDs attached to it.
+//
+// Parameters:
+// - mountpoint: The absolute path of the mount
+func (t *MountTable) Unregister(mountpoint string) {
+	mountpoint = filepath.Clean(mountpoint)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.volumes, mountpoint)
+	for pid, mp := range t.pids {
+		if mp == mountpoint {
+			delete(t.pids, pid)
+		}
+	}
+}
+
+// TrackPID attaches a client process to a registered mount point.
+//
+// Parameters:
+// - pid: The PID of the glusterfs client
+// - mountpoint: The mount point served by the client
+func (t *MountTable) TrackPID(pid int, mountpoint string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pids[pid] = filepath.Clean(mountpoint)
+}
+
+// ResolveVolume returns the volume served by the given client process.
+// PIDs that are not tracked yet are resolved by looking for a registered
+// mount point on the process command line, since glusterfs daemonizes
+// and logs with a PID different from the one that was started.
+// PIDs that do not resolve are not looked up again for unresolvedTTL.
+//
+// Parameters:
+// - pid: The PID of the logging process
+//
+// Returns:
+// - The volume name and true if the PID could be resolved
+func (t *MountTable) ResolveVolume(pid int) (string, bool) {
+	t.mu.RLock()
+	if mp, ok := t.pids[pid]; ok {
+		name, found := t.volumes[mp]
+		t.mu.RUnlock()
+		return name, found
+	}
+	lookedUp, unresolved := t.unresolved[pid]
+	t.mu.RUnlock()
+
+	now := t.now()
+	if unresolved && now.Sub(lookedUp) < unresolvedTTL {
+		return "", false
+	}
+
+	cmdline, err := os.ReadFile(filepath.Join(t.procRoot, strconv.Itoa(pid), "cmdline"))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err != nil {
+		t.markUnresolved(pid, now)
+		return "", false
+	}
+
+	// The mount point is the last argument of a glusterfs client
+	args := bytes.Split(bytes.TrimRight(cmdline, "\x00"), []byte{0})
+	for i := len(args) - 1; i > 0; i-- {
+		mp := filepath.Clean(string(args[i]))
+		if name, ok := t.volumes[mp]; ok {
+			t.pids[pid] = mp
+			delete(t.unresolved, pid)
+			return name, true
+		}
+	}
+	t.markUnresolved(pid, now)
+	return "", false
+}
+
+// markUnresolved remembers a PID that could not be resolved, dropping
+// expired entries once maxUnresolved is reached. The caller holds t.mu.
+func (t *MountTable) markUnresolved(pid int, now time.Time) {
+	if len(t.unresolved) >= maxUnresolved {
+		for p, lookedUp := range t.unresolved {
+			if now.Sub(lookedUp) >= unresolvedTTL {
+				delete(t.unresolved, p)
+			}
+		}
+		if len(t.unresolved) >= maxUnresolved {
+			return
+		}
+	}
+	t.unresolved[pid] = now
+}
@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeCmdline(t *testing.T, procRoot string, pid string, args ...string) {
+	t.Helper()
+	dir := filepath.Join(procRoot, pid)
+	require.NoError(t, os.MkdirAll(dir, 0755))
+
+	var cmdline []byte
+	for _, arg := range args {
+		cmdline = append(cmdline, arg...)
+		cmdline = append(cmdline, 0)
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "cmdline"), cmdline, 0644))
+}
+
+func TestMountTable_ResolveVolume(t *testing.T) {
+	table := NewMountTable()
+	table.procRoot = t.TempDir()
+
+	table.Register("/mnt/glusterfs/vol1", "vol1")
+	table.Register("/mnt/glusterfs/vol2/sub/", "vol2/sub")
+	writeCmdline(t, table.procRoot, "100", "/usr/sbin/glusterfs", "-s", "server1", "--volfile-id=vol2", "--subdir-mount=/sub", "/mnt/glusterfs/vol2/sub")
+	writeCmdline(t, table.procRoot, "200", "/usr/sbin/glusterfs", "-s", "server1", "/mnt/other")
+
+	table.TrackPID(50, "/mnt/glusterfs/vol1")
+
+	tests := []struct {
+		name   string
+		pid    int
+		want   string
+		wantOK bool
+	}{
+		{name: "tracked pid", pid: 50, want: "vol1", wantOK: true},
+		{name: "pid resolved from cmdline", pid: 100, want: "vol2/sub", wantOK: true},
+		{name: "unknown mount point", pid: 200, wantOK: false},
+		{name: "unknown pid", pid: 300, wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := table.ResolveVolume(tt.pid)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	// Resolved PIDs are cached and dropped together with their mount point
+	assert.Equal(t, "/mnt/glusterfs/vol2/sub", table.pids[100])
+	table.Unregister("/mnt/glusterfs/vol2/sub")
+	_, ok := table.ResolveVolume(100)
+	assert.False(t, ok)
+	assert.NotContains(t, table.pids, 100)
+}
+
+func TestMountTable_ResolveVolumeUnresolved(t *testing.T) {
+	table := NewMountTable()
+	table.procRoot = t.TempDir()
+	now := time.Now()
+	table.now = func() time.Time { return now }
+
+	table.Register("/mnt/glusterfs/vol1", "vol1")
+
+	// An unresolved PID is not looked up again until unresolvedTTL passes
+	_, ok := table.ResolveVolume(100)
+	assert.False(t, ok)
+	writeCmdline(t, table.procRoot, "100", "/usr/sbin/glusterfs", "/mnt/glusterfs/vol1")
+	_, ok = table.ResolveVolume(100)
+	assert.False(t, ok)
+
+	now = now.Add(unresolvedTTL)
+	name, ok := table.ResolveVolume(100)
+	assert.True(t, ok)
+	assert.Equal(t, "vol1", name)
+	assert.NotContains(t, table.unresolved, 100)
+
+	// Registering a mount point forgets unresolved PIDs
+	_, ok = table.ResolveVolume(200)
+	assert.False(t, ok)
+	table.Register("/mnt/glusterfs/vol2", "vol2")
+	writeCmdline(t, table.procRoot, "200", "/usr/sbin/glusterfs", "/mnt/glusterfs/vol2")
+	name, ok = table.ResolveVolume(200)
+	assert.True(t, ok)
+	assert.Equal(t, "vol2", name)
+
+	// The unresolved PIDs are bounded, expired ones are dropped first
+	for pid := 1000; pid < 1000+maxUnresolved+10; pid++ {
+		table.ResolveVolume(pid)
+	}
+	assert.Len(t, table.unresolved, maxUnresolved)
+	now = now.Add(unresolvedTTL)
+	table.ResolveVolume(5000)
+	assert.Len(t, table.unresolved, 1)
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 
@@ -20,83 +21,66 @@ const (
 	// socketName is the name of the Unix socket file.
 	// This is the name that Docker will use to communicate with the plugin.
 	socketName = "glusterfs.sock"
+
+	// SocketPath is the Unix socket Docker talks to the plugin on.
+	SocketPath = socketDir + "/" + socketName
 )
 
 // StartUnixSocket starts the Unix socket server for the volume driver.
 // It creates and manages the Unix socket that Docker uses to communicate
 // with the plugin.
 //
+// Parameters:
+// - driver: The volume driver implementation
+//
+// Returns:
+// - error if the server fails to start, nil otherwise
+func StartUnixSocket(driver volume.Driver) error {
+	return ServeUnixSocket(driver, SocketPath)
+}
+
+// ServeUnixSocket serves the Docker volume plugin protocol for a driver
+// on a Unix socket at path, such as a socket in a test directory.
+//
 // The function:
 // 1. Creates the socket directory if it doesn't exist
 // 2. Removes any existing socket file
 // 3. Creates a new Unix socket
 // 4. Sets appropriate permissions
-// 5. Starts accepting connections
+// 5. Serves the Docker volume plugin protocol on it
 //
 // Parameters:
 // - driver: The volume driver implementation
-// - root: The root directory for volume mounts
+// - path: The socket path
 //
 // Returns:
 // - error if the server fails to start, nil otherwise
-func StartUnixSocket(driver volume.Driver, root string) error {
+func ServeUnixSocket(driver volume.Driver, path string) error {
 	// Ensure the socket directory exists
-	if err := os.MkdirAll(socketDir, 0755); err != nil {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 		return fmt.Errorf("failed to create socket directory: %v", err)
 	}
 
 	// Remove existing socket if it exists
-	socketPath := filepath.Join(socketDir, socketName)
-	if err := os.RemoveAll(socketPath); err != nil {
+	if err := os.RemoveAll(path); err != nil {
 		return fmt.Errorf("failed to remove existing socket: %v", err)
 	}
 
 	// Create Unix socket listener
-	listener, err := net.Listen("unix", socketPath)
+	listener, err := net.Listen("unix", path)
 	if err != nil {
 		return fmt.Errorf("failed to create Unix socket: %v", err)
 	}
 	defer listener.Close()
 
 	// Set socket permissions
-	if err := os.Chmod(socketPath, 0660); err != nil {
+	if err := os.Chmod(path, 0660); err != nil {
 		return fmt.Errorf("failed to set socket permissions: %v", err)
 	}
 
-	log.Printf("Starting Unix socket server at %s", socketPath)
+	log.Printf("Starting Unix socket server at %s", path)
 
-	// Start accepting connections
-	for {
-		conn, err := listener.Accept()
-		if err != nil {
-			log.Printf("Error accepting connection: %v", err)
-			continue
-		}
-
-		go handleConnection(conn, driver)
-	}
+	// Serve the Docker plugin protocol; each connection is handled
+	// in its own goroutine
+	return http.Serve(listener, NewHandler(driver))
 }
-
-// handleConnection handles a single client connection.
-// It processes incoming requests from Docker and forwards them to the driver.
-//
-// The function:
-// 1. Reads the request from the connection
-// 2. Parses the request
-// 3. Calls the appropriate driver method
-// 4. Writes the response back to the connection
-//
-// Parameters:
-// - conn: The network connection to handle
-// - driver: The volume driver implementation
-func handleConnection(conn net.Conn, driver volume.Driver) {
-	defer conn.Close()
-
-	// TODO: Implement the actual request handling logic
-	// This would involve:
-	// 1. Reading the request from the connection
-	// 2. Parsing the request
-	// 3. Calling the appropriate driver method
-	// 4. Writing the response back to the connection
-	log.Printf("New connection from %s", conn.RemoteAddr())
-} 
\ No newline at end of file
@@ -1,27 +1,341 @@
 package utils
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"log"
-	"os/exec"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
-// StartSyslog starts the rsyslog daemon
-func StartSyslog() error {
-	cmd := exec.Command("rsyslogd", "-n")
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start rsyslog: %v", err)
+const (
+	// SyslogSocketPath is the socket glusterfs clients write to when they are
+	// started with --logger=syslog.
+	SyslogSocketPath = "/dev/log"
+
+	// maxSyslogMessageSize is the largest datagram accepted from a client.
+	maxSyslogMessageSize = 64 * 1024
+
+	// maxReadBackoff caps the delay between reads that keep failing.
+	maxReadBackoff = time.Second
+)
+
+// severityNames maps syslog severities (RFC 5424, section 6.2.1) to their names.
+var severityNames = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// VolumeResolver maps the PID of a logging process to the volume it serves.
+type VolumeResolver interface {
+	// ResolveVolume returns the volume name for the process, if known.
+	ResolveVolume(pid int) (string, bool)
+}
+
+// SyslogMessage is a single parsed syslog message.
+type SyslogMessage struct {
+	Facility  int
+	Severity  int
+	Timestamp time.Time
+	Hostname  string
+	AppName   string
+	PID       int
+	Message   string
+}
+
+// SeverityName returns the textual name of the message severity.
+func (m *SyslogMessage) SeverityName() string {
+	if m.Severity < 0 || m.Severity >= len(severityNames) {
+		return strconv.Itoa(m.Severity)
+	}
+	return severityNames[m.Severity]
+}
+
+// SyslogServer receives syslog datagrams from glusterfs clients and forwards
+// them to the plugin log, tagged with the volume each client serves.
+// It replaces the rsyslog daemon previously shipped in the image.
+type SyslogServer struct {
+	path     string
+	resolver VolumeResolver
+	logs     *ClientLogs
+
+	mu   sync.Mutex
+	conn *net.UnixConn
+	done chan struct{}
+}
+
+// NewSyslogServer creates a syslog receiver bound to the given socket path.
+//
+// Parameters:
+// - path: The unixgram socket to listen on (usually SyslogSocketPath)
+// - resolver: Maps client PIDs to volume names, may be nil
+// - logs: Receives the messages of every resolved volume, may be nil
+//
+// Returns:
+// - A new SyslogServer, not yet listening
+func NewSyslogServer(path string, resolver VolumeResolver, logs *ClientLogs) *SyslogServer {
+	return &SyslogServer{
+		path:     path,
+		resolver: resolver,
+		logs:     logs,
+	}
+}
+
+// Start binds the syslog socket and starts receiving messages in the background.
+//
+// Returns:
+// - error if the socket cannot be created, nil otherwise
+func (s *SyslogServer) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn != nil {
+		return fmt.Errorf("syslog server already started on %s", s.path)
+	}
+
+	// Remove a stale socket left behind by a previous run
+	if err := os.RemoveAll(s.path); err != nil {
+		return fmt.Errorf("failed to remove existing syslog socket: %v", err)
 	}
 
-	// Wait a short time to ensure rsyslog started properly
-	time.Sleep(100 * time.Millisecond)
-	
-	// Check if rsyslog is running
-	if cmd.Process == nil {
-		return fmt.Errorf("rsyslog process failed to start")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: s.path, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("failed to create syslog socket: %v", err)
 	}
 
-	log.Printf("rsyslog daemon started successfully")
+	// Any local process must be able to log
+	if err := os.Chmod(s.path, 0666); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to set syslog socket permissions: %v", err)
+	}
+
+	s.conn = conn
+	s.done = make(chan struct{})
+	go s.serve(conn, s.done)
+
+	log.Printf("Starting syslog receiver at %s", s.path)
 	return nil
-} 
\ No newline at end of file
+}
+
+// Close stops the receiver and removes the socket.
+//
+// Returns:
+// - error if the socket cannot be closed, nil otherwise
+func (s *SyslogServer) Close() error {
+	s.mu.Lock()
+	conn, done := s.conn, s.done
+	s.conn = nil
+	s.mu.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+
+	err := conn.Close()
+	<-done
+	os.Remove(s.path)
+	return err
+}
+
+// serve reads datagrams until the connection is closed.
+// Reads that keep failing are retried with a growing delay, so a
+// persistent error does not spin.
+func (s *SyslogServer) serve(conn *net.UnixConn, done chan struct{}) {
+	defer close(done)
+
+	buf := make([]byte, maxSyslogMessageSize)
+	var backoff time.Duration
+	for {
+		n, _, err := conn.ReadFromUnix(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			backoff = nextReadBackoff(backoff)
+			log.Printf("Error reading syslog message, retrying in %v: %v", backoff, err)
+			time.Sleep(backoff)
+			continue
+		}
+		backoff = 0
+		s.handle(buf[:n])
+	}
+}
+
+// nextReadBackoff doubles the delay after a failed read, starting at
+// 10ms and capped at maxReadBackoff.
+func nextReadBackoff(backoff time.Duration) time.Duration {
+	if backoff == 0 {
+		return 10 * time.Millisecond
+	}
+	if backoff *= 2; backoff > maxReadBackoff {
+		return maxReadBackoff
+	}
+	return backoff
+}
+
+// handle parses a single datagram and writes it to the plugin log.
+// Messages from known volumes are also kept in their client log buffer.
+func (s *SyslogServer) handle(data []byte) {
+	// A datagram may carry several newline separated messages
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		msg, err := ParseSyslogMessage(line)
+		if err != nil {
+			log.Printf("syslog: %v: %q", err, line)
+			continue
+		}
+
+		volumeName := "-"
+		if s.resolver != nil && msg.PID > 0 {
+			if name, ok := s.resolver.ResolveVolume(msg.PID); ok {
+				volumeName = name
+				if s.logs != nil {
+					s.logs.Append(name, msg.Message)
+				}
+			}
+		}
+
+		log.Printf("%s", formatSyslogMessage(msg, volumeName))
+	}
+}
+
+// formatSyslogMessage renders a message as a single structured log line.
+func formatSyslogMessage(msg *SyslogMessage, volumeName string) string {
+	app := msg.AppName
+	if app == "" {
+		app = "-"
+	}
+	return fmt.Sprintf("%s[%d] volume=%s severity=%s: %s", app, msg.PID, volumeName, msg.SeverityName(), msg.Message)
+}
+
+// ParseSyslogMessage parses an RFC 3164 or RFC 5424 formatted message.
+// Messages written to /dev/log by syslog(3) use the RFC 3164 layout without
+// a hostname, which is also accepted.
+//
+// Parameters:
+// - data: The raw message, without trailing newline
+//
+// Returns:
+// - The parsed message
+// - error if the message has no valid priority header
+func ParseSyslogMessage(data []byte) (*SyslogMessage, error) {
+	line := strings.TrimRight(string(data), "\r\n\x00")
+
+	if !strings.HasPrefix(line, "<") {
+		return nil, fmt.Errorf("missing priority")
+	}
+	end := strings.IndexByte(line, '>')
+	if end < 2 || end > 4 {
+		return nil, fmt.Errorf("invalid priority")
+	}
+	pri, err := strconv.Atoi(line[1:end])
+	if err != nil || pri < 0 || pri > 191 {
+		return nil, fmt.Errorf("invalid priority")
+	}
+
+	msg := &SyslogMessage{
+		Facility: pri / 8,
+		Severity: pri % 8,
+	}
+	rest := line[end+1:]
+
+	if strings.HasPrefix(rest, "1 ") {
+		parseRFC5424(msg, rest[2:])
+	} else {
+		parseRFC3164(msg, rest)
+	}
+	return msg, nil
+}
+
+// parseRFC5424 parses the part of an RFC 5424 message after "<PRI>1 ".
+func parseRFC5424(msg *SyslogMessage, rest string) {
+	fields := strings.SplitN(rest, " ", 6)
+	for len(fields) < 6 {
+		fields = append(fields, "-")
+	}
+
+	if ts, err := time.Parse(time.RFC3339Nano, fields[0]); err == nil {
+		msg.Timestamp = ts
+	}
+	msg.Hostname = nilValue(fields[1])
+	msg.AppName = nilValue(fields[2])
+	if pid, err := strconv.Atoi(fields[3]); err == nil {
+		msg.PID = pid
+	}
+
+	// fields[4] is the MSGID, fields[5] holds STRUCTURED-DATA and MSG
+	msg.Message = strings.TrimPrefix(skipStructuredData(fields[5]), "\ufeff")
+}
+
+// skipStructuredData strips the STRUCTURED-DATA element from an RFC 5424 message.
+func skipStructuredData(s string) string {
+	if strings.HasPrefix(s, "-") {
+		return strings.TrimPrefix(strings.TrimPrefix(s, "-"), " ")
+	}
+
+	inElement, escaped := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '[':
+			inElement = true
+		case c == ']':
+			inElement = false
+		case c == ' ' && !inElement:
+			return s[i+1:]
+		}
+	}
+	return ""
+}
+
+// parseRFC3164 parses the part of an RFC 3164 message after "<PRI>".
+func parseRFC3164(msg *SyslogMessage, rest string) {
+	// Timestamp is "Mmm dd hh:mm:ss", the day is space padded
+	if len(rest) >= 16 && rest[15] == ' ' {
+		if ts, err := time.Parse(time.Stamp, rest[:15]); err == nil {
+			msg.Timestamp = ts
+			rest = rest[16:]
+		}
+	}
+
+	// The hostname is optional; the tag is terminated by ':' or '['
+	tagEnd := strings.IndexAny(rest, ":[ ")
+	if tagEnd >= 0 && rest[tagEnd] == ' ' {
+		msg.Hostname = rest[:tagEnd]
+		rest = rest[tagEnd+1:]
+		tagEnd = strings.IndexAny(rest, ":[ ")
+	}
+	if tagEnd < 0 || rest[tagEnd] == ' ' {
+		msg.Message = rest
+		return
+	}
+
+	msg.AppName = rest[:tagEnd]
+	rest = rest[tagEnd:]
+	if strings.HasPrefix(rest, "[") {
+		if closing := strings.IndexByte(rest, ']'); closing > 0 {
+			if pid, err := strconv.Atoi(rest[1:closing]); err == nil {
+				msg.PID = pid
+			}
+			rest = rest[closing+1:]
+		}
+	}
+	msg.Message = strings.TrimPrefix(strings.TrimPrefix(rest, ":"), " ")
+}
+
+// nilValue converts the RFC 5424 NILVALUE to an empty string.
+func nilValue(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return s
+}
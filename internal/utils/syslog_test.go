@@ -1,18 +1,178 @@
 package utils
 
 import (
-	"os/exec"
+	"bytes"
+	"log"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-func TestStartSyslog(t *testing.T) {
-	// Skip this test if rsyslog is not installed
-	if _, err := exec.LookPath("rsyslogd"); err != nil {
-		t.Skip("rsyslogd not found, skipping test")
+type staticResolver map[int]string
+
+func (r staticResolver) ResolveVolume(pid int) (string, bool) {
+	name, ok := r[pid]
+	return name, ok
+}
+
+// syncBuffer is a bytes.Buffer safe for use as a log output.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestParseSyslogMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    *SyslogMessage
+		wantErr bool
+	}{
+		{
+			name:  "rfc3164 from syslog(3) without hostname",
+			input: "<27>Jan  2 15:04:05 glusterfs[1234]: [2024-01-02 15:04:05] E [MSGID: 100] failed to fetch volume file",
+			want: &SyslogMessage{
+				Facility:  3,
+				Severity:  3,
+				Timestamp: time.Date(0, time.January, 2, 15, 4, 5, 0, time.UTC),
+				AppName:   "glusterfs",
+				PID:       1234,
+				Message:   "[2024-01-02 15:04:05] E [MSGID: 100] failed to fetch volume file",
+			},
+		},
+		{
+			name:  "rfc3164 with hostname",
+			input: "<30>Oct 11 22:14:15 store1 glusterfs[42]: connected",
+			want: &SyslogMessage{
+				Facility:  3,
+				Severity:  6,
+				Timestamp: time.Date(0, time.October, 11, 22, 14, 15, 0, time.UTC),
+				Hostname:  "store1",
+				AppName:   "glusterfs",
+				PID:       42,
+				Message:   "connected",
+			},
+		},
+		{
+			name:  "rfc3164 tag without pid",
+			input: "<14>mount: done",
+			want: &SyslogMessage{
+				Facility: 1,
+				Severity: 6,
+				AppName:  "mount",
+				Message:  "done",
+			},
+		},
+		{
+			name:  "rfc5424 with structured data",
+			input: `<165>1 2024-01-02T15:04:05.000Z store1 glusterfs 99 ID47 [origin ip="10.0.0.1" x="a\]b"] auth rejected`,
+			want: &SyslogMessage{
+				Facility:  20,
+				Severity:  5,
+				Timestamp: time.Date(2024, time.January, 2, 15, 4, 5, 0, time.UTC),
+				Hostname:  "store1",
+				AppName:   "glusterfs",
+				PID:       99,
+				Message:   "auth rejected",
+			},
+		},
+		{
+			name:  "rfc5424 with nil values",
+			input: "<11>1 - - glusterfs 7 - - mount failed",
+			want: &SyslogMessage{
+				Facility: 1,
+				Severity: 3,
+				AppName:  "glusterfs",
+				PID:      7,
+				Message:  "mount failed",
+			},
+		},
+		{
+			name:    "missing priority",
+			input:   "glusterfs[1]: hello",
+			wantErr: true,
+		},
+		{
+			name:    "invalid priority",
+			input:   "<999>hello",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSyslogMessage([]byte(tt.input))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
 	}
+}
+
+func TestSyslogServer(t *testing.T) {
+	var out syncBuffer
+	prev := log.Writer()
+	log.SetOutput(&out)
+	defer log.SetOutput(prev)
 
-	err := StartSyslog()
-	assert.NoError(t, err)
-} 
\ No newline at end of file
+	socketPath := filepath.Join(t.TempDir(), "log")
+	logs := NewClientLogs(10)
+	server := NewSyslogServer(socketPath, staticResolver{1234: "myvol/sub"}, logs)
+	require.NoError(t, server.Start())
+	defer server.Close()
+
+	assert.Error(t, server.Start(), "starting twice should fail")
+
+	conn, err := net.Dial("unixgram", socketPath)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("<27>Jan  2 15:04:05 glusterfs[1234]: failed to fetch volume file"))
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("<30>Jan  2 15:04:05 glusterfs[5678]: unknown client"))
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		return strings.Contains(out.String(), "glusterfs[1234] volume=myvol/sub severity=err: failed to fetch volume file") &&
+			strings.Contains(out.String(), "glusterfs[5678] volume=- severity=info: unknown client")
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"failed to fetch volume file"}, logs.Tail("myvol/sub", 0))
+
+	assert.NoError(t, server.Close())
+	assert.NoError(t, server.Close(), "closing twice should be a no-op")
+}
+
+func TestNextReadBackoff(t *testing.T) {
+	var delays []time.Duration
+	var backoff time.Duration
+	for i := 0; i < 9; i++ {
+		backoff = nextReadBackoff(backoff)
+		delays = append(delays, backoff)
+	}
+	assert.Equal(t, []time.Duration{
+		10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond,
+		80 * time.Millisecond, 160 * time.Millisecond, 320 * time.Millisecond,
+		640 * time.Millisecond, time.Second, time.Second,
+	}, delays)
+}
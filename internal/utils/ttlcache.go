@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// TTLCache remembers values for a short time, so that status that is
+// slow to read, such as the answer of a gluster command, is not read
+// again on every request.
+// Expired entries are dropped when new ones are stored.
+type TTLCache struct {
+	ttl time.Duration
+
+	// now returns the current time, replaceable in tests
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]ttlEntry
+}
+
+// ttlEntry is a cached value and its expiry.
+type ttlEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// NewTTLCache creates an empty TTLCache.
+//
+// Parameters:
+//   - ttl: How long values are remembered; values are never cached if it
+//     is not positive
+//
+// Returns:
+// - A new TTLCache
+func NewTTLCache(ttl time.Duration) *TTLCache {
+	return &TTLCache{ttl: ttl, now: time.Now, entries: make(map[string]ttlEntry)}
+}
+
+// Get returns the value stored for key if it has not expired.
+//
+// Parameters:
+// - key: The key of the value
+//
+// Returns:
+// - The value and true, or nil and false if there is none
+func (c *TTLCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || !c.now().Before(entry.expires) {
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// Set stores a value for key until the TTL elapses.
+//
+// Parameters:
+// - key: The key of the value
+// - value: The value, which must not be modified afterwards
+func (c *TTLCache) Set(key string, value interface{}) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for k, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = ttlEntry{value: value, expires: now.Add(c.ttl)}
+}
+
+// Delete forgets the value stored for key, if any.
+func (c *TTLCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
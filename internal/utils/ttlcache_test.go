@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTTLCache(t *testing.T) {
+	now := time.Now()
+	c := NewTTLCache(10 * time.Second)
+	c.now = func() time.Time { return now }
+
+	_, ok := c.Get("vol1")
+	assert.False(t, ok)
+
+	c.Set("vol1", 42)
+	value, ok := c.Get("vol1")
+	assert.True(t, ok)
+	assert.Equal(t, 42, value)
+
+	now = now.Add(10 * time.Second)
+	_, ok = c.Get("vol1")
+	assert.False(t, ok, "values expire after the TTL")
+
+	// Storing drops the expired entries
+	c.Set("vol2", "x")
+	assert.Len(t, c.entries, 1)
+
+	c.Delete("vol2")
+	_, ok = c.Get("vol2")
+	assert.False(t, ok)
+}
+
+func TestTTLCache_Disabled(t *testing.T) {
+	c := NewTTLCache(0)
+	c.Set("vol1", 42)
+	_, ok := c.Get("vol1")
+	assert.False(t, ok)
+}
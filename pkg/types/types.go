@@ -2,26 +2,13 @@
 // These types are used to represent the state and configuration of the plugin.
 package types
 
-import (
-	"glusterfs-plugin/pkg/volume"
-)
-
-// Driver interface defines the methods that must be implemented by the GlusterFS driver
-type Driver interface {
-	Validate(req *volume.CreateRequest) error
-	MountOptions(req *volume.CreateRequest) []string
-	PreMount(req *volume.MountRequest) error
-	PostMount(req *volume.MountRequest)
-}
-
-// GFSDriver implements the Driver interface for GlusterFS volumes.
-// It provides the core functionality for managing GlusterFS volumes,
-// including server configuration and volume operations.
+// GFSDriver holds the configuration shared by the GlusterFS driver.
+// The volume lifecycle itself is implemented by the driver package,
+// which embeds this type.
 type GFSDriver struct {
 	// Servers contains the list of GlusterFS servers to use for volume operations.
 	// These servers are used to mount volumes and perform other GlusterFS operations.
 	Servers []string
-	volume.Driver
 }
 
 // NewGFSDriver creates a new instance of the GlusterFS driver.
@@ -36,4 +23,4 @@ func NewGFSDriver(servers []string) *GFSDriver {
 	return &GFSDriver{
 		Servers: servers,
 	}
-} 
\ No newline at end of file
+}
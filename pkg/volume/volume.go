@@ -3,12 +3,15 @@
 package volume
 
 import (
+	"context"
 	"fmt"
 )
 
 // Driver defines the interface that volume drivers must implement.
-// This interface provides the contract for all volume operations including
-// validation, mounting, and capability reporting.
+// It is the single contract for the full plugin lifecycle: the Docker
+// volume protocol operations (Create, Remove, Get, List, Path, Mount,
+// Unmount and Capabilities) together with the validation and mount hooks
+// the lifecycle is built on.
 type Driver interface {
 	// Validate checks if a volume creation request is valid.
 	// It should verify all required parameters and their values.
@@ -24,8 +27,35 @@ type Driver interface {
 	PreMount(req *MountRequest) error
 
 	// PostMount performs any necessary operations after mounting a volume.
-	// This includes verifying the mount was successful and logging the result.
-	PostMount(req *MountRequest)
+	// This includes verifying the mount was successful, preparing the
+	// mounted root and logging the result. An error fails the mount.
+	PostMount(req *MountRequest) error
+
+	// Create registers a new volume after validating the request.
+	Create(req *CreateRequest) error
+
+	// Remove unregisters a volume. Mounted volumes cannot be removed.
+	Remove(name string) error
+
+	// Get returns a registered volume together with its status.
+	Get(name string) (*Volume, error)
+
+	// List returns all registered volumes.
+	List() ([]*Volume, error)
+
+	// Path returns the mount point of a volume, empty if it is not mounted.
+	Path(name string) (string, error)
+
+	// Mount mounts a volume and returns its mount point.
+	// Cancelling the context aborts the mount and cleans up after it.
+	Mount(ctx context.Context, req *MountRequest) (string, error)
+
+	// Unmount releases a mount of the volume.
+	// Cancelling the context aborts the unmount.
+	Unmount(ctx context.Context, req *MountRequest) error
+
+	// Capabilities reports the capabilities of the driver.
+	Capabilities() Capability
 }
 
 // CreateRequest represents a request to create a new volume.
@@ -38,7 +68,7 @@ type CreateRequest struct {
 	// Common options include:
 	// - servers: comma-separated list of GlusterFS servers
 	// - glusteropts: custom GlusterFS mount options
-	Options map[string]string
+	Options map[string]string `json:"Opts"`
 }
 
 // Validate performs validation checks on the create request.
@@ -65,6 +95,9 @@ type MountRequest struct {
 	// Name is the unique identifier of the volume to mount
 	Name string
 
+	// ID identifies the container the volume is mounted for
+	ID string
+
 	// Mountpoint is the absolute path where the volume should be mounted
 	Mountpoint string
 }
@@ -90,11 +123,12 @@ func (r *MountRequest) Validate() error {
 // Volume represents a volume
 type Volume struct {
 	Name       string
-	Mountpoint string
-	Status     map[string]interface{}
+	Mountpoint string                 `json:",omitempty"`
+	Status     map[string]interface{} `json:",omitempty"`
 }
 
-// Capability represents the capabilities of a driver
+// Capability represents the capabilities of a driver.
+// Scope is either "local" or "global".
 type Capability struct {
 	Scope string
-} 
\ No newline at end of file
+}
@@ -0,0 +1,209 @@
+package volumetest
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// testActivate checks that the plugin implements VolumeDriver.
+func testActivate(t *testing.T, c *client, plugin Plugin) {
+	resp := c.call("Plugin.Activate", nil)
+	var implements []string
+	if err := json.Unmarshal(resp["Implements"], &implements); err != nil {
+		t.Fatalf("Plugin.Activate: Implements is %s, want an array of strings", resp["Implements"])
+	}
+	for _, name := range implements {
+		if name == "VolumeDriver" {
+			return
+		}
+	}
+	t.Fatalf("Plugin.Activate: implements %v, want VolumeDriver", implements)
+}
+
+// testCapabilities checks that the plugin reports a scope Docker knows.
+func testCapabilities(t *testing.T, c *client, plugin Plugin) {
+	resp := c.call("VolumeDriver.Capabilities", nil)
+	capabilities := objectField(t, "VolumeDriver.Capabilities", resp, "Capabilities")
+	if scope := stringField(t, "VolumeDriver.Capabilities", capabilities, "Scope"); scope != "local" && scope != "global" {
+		t.Fatalf("VolumeDriver.Capabilities: Scope is %q, want local or global", scope)
+	}
+}
+
+// testUnknownVolume checks that every operation on a volume that was not
+// created reports an error, and that List does not report it.
+func testUnknownVolume(t *testing.T, c *client, plugin Plugin) {
+	for _, endpoint := range []string{"VolumeDriver.Get", "VolumeDriver.Path", "VolumeDriver.Remove"} {
+		requireErr(t, endpoint, c.call(endpoint, map[string]string{"Name": missing}))
+	}
+	requireErr(t, "VolumeDriver.Mount", c.mount(missing, "c1"))
+	requireErr(t, "VolumeDriver.Unmount", c.unmount(missing, "c1"))
+
+	resp := c.call("VolumeDriver.List", nil)
+	requireNoErr(t, "VolumeDriver.List", resp)
+	if list := volumes(t, resp); len(list) != 0 {
+		t.Fatalf("VolumeDriver.List: lists %d volume(s) of a new plugin, want none", len(list))
+	}
+}
+
+// testCreateIdempotent checks that creating a volume again with the same
+// options succeeds and keeps a single volume, as Docker does when a
+// stack is redeployed.
+func testCreateIdempotent(t *testing.T, c *client, plugin Plugin) {
+	requireNoErr(t, "VolumeDriver.Create", c.create("vol1", plugin.Options))
+	requireNoErr(t, "VolumeDriver.Create", c.create("vol1", plugin.Options))
+
+	resp := c.call("VolumeDriver.List", nil)
+	requireNoErr(t, "VolumeDriver.List", resp)
+	list := volumes(t, resp)
+	if len(list) != 1 || stringField(t, "VolumeDriver.List", list[0], "Name") != "vol1" {
+		t.Fatalf("VolumeDriver.List: lists %s, want only vol1", resp["Volumes"])
+	}
+
+	resp = c.call("VolumeDriver.Get", map[string]string{"Name": "vol1"})
+	requireNoErr(t, "VolumeDriver.Get", resp)
+	if name := stringField(t, "VolumeDriver.Get", objectField(t, "VolumeDriver.Get", resp, "Volume"), "Name"); name != "vol1" {
+		t.Fatalf("VolumeDriver.Get: Name is %q, want vol1", name)
+	}
+}
+
+// testCreateConflict checks that creating a volume again with other
+// options fails and leaves the volume usable.
+func testCreateConflict(t *testing.T, c *client, plugin Plugin) {
+	requireNoErr(t, "VolumeDriver.Create", c.create("vol1", plugin.Options))
+	requireErr(t, "VolumeDriver.Create", c.create("vol1", plugin.Conflicting))
+
+	requireNoErr(t, "VolumeDriver.Get", c.call("VolumeDriver.Get", map[string]string{"Name": "vol1"}))
+	requireNoErr(t, "VolumeDriver.Mount", c.mount("vol1", "c1"))
+	requireNoErr(t, "VolumeDriver.Unmount", c.unmount("vol1", "c1"))
+}
+
+// testMountUnmountPairing checks that a volume mounted for two containers
+// stays mounted, at the same mount point, until both are unmounted, and
+// cannot be removed meanwhile.
+func testMountUnmountPairing(t *testing.T, c *client, plugin Plugin) {
+	requireNoErr(t, "VolumeDriver.Create", c.create("vol1", plugin.Options))
+	if mountpoint := c.path("vol1"); mountpoint != "" {
+		t.Fatalf("VolumeDriver.Path: %q before any mount, want empty", mountpoint)
+	}
+
+	resp := c.mount("vol1", "c1")
+	requireNoErr(t, "VolumeDriver.Mount", resp)
+	mountpoint := stringField(t, "VolumeDriver.Mount", resp, "Mountpoint")
+	if mountpoint == "" {
+		t.Fatal("VolumeDriver.Mount: Mountpoint is empty")
+	}
+	resp = c.mount("vol1", "c2")
+	requireNoErr(t, "VolumeDriver.Mount", resp)
+	if second := stringField(t, "VolumeDriver.Mount", resp, "Mountpoint"); second != mountpoint {
+		t.Fatalf("VolumeDriver.Mount: second container got %q, want %q", second, mountpoint)
+	}
+	if path := c.path("vol1"); path != mountpoint {
+		t.Fatalf("VolumeDriver.Path: %q while mounted, want %q", path, mountpoint)
+	}
+	requireErr(t, "VolumeDriver.Remove", c.call("VolumeDriver.Remove", map[string]string{"Name": "vol1"}))
+
+	requireNoErr(t, "VolumeDriver.Unmount", c.unmount("vol1", "c1"))
+	if path := c.path("vol1"); path != mountpoint {
+		t.Fatalf("VolumeDriver.Path: %q while c2 is mounted, want %q", path, mountpoint)
+	}
+	requireNoErr(t, "VolumeDriver.Unmount", c.unmount("vol1", "c2"))
+	if path := c.path("vol1"); path != "" {
+		t.Fatalf("VolumeDriver.Path: %q after the last unmount, want empty", path)
+	}
+	requireErr(t, "VolumeDriver.Unmount", c.unmount("vol1", "c2"))
+
+	requireNoErr(t, "VolumeDriver.Remove", c.call("VolumeDriver.Remove", map[string]string{"Name": "vol1"}))
+}
+
+// testUnmountUnknownID checks that an Unmount with an ID the volume is
+// not mounted with fails and releases nothing.
+func testUnmountUnknownID(t *testing.T, c *client, plugin Plugin) {
+	requireNoErr(t, "VolumeDriver.Create", c.create("vol1", plugin.Options))
+	resp := c.mount("vol1", "c1")
+	requireNoErr(t, "VolumeDriver.Mount", resp)
+	mountpoint := stringField(t, "VolumeDriver.Mount", resp, "Mountpoint")
+
+	requireErr(t, "VolumeDriver.Unmount", c.unmount("vol1", "c2"))
+	if path := c.path("vol1"); path != mountpoint {
+		t.Fatalf("VolumeDriver.Path: %q after an unmount for an unknown ID, want %q", path, mountpoint)
+	}
+
+	requireNoErr(t, "VolumeDriver.Unmount", c.unmount("vol1", "c1"))
+	if path := c.path("vol1"); path != "" {
+		t.Fatalf("VolumeDriver.Path: %q after the last unmount, want empty", path)
+	}
+}
+
+// testDuplicateUnmount checks that unmounting the same ID twice releases
+// a single mount, leaving the other containers mounted.
+func testDuplicateUnmount(t *testing.T, c *client, plugin Plugin) {
+	requireNoErr(t, "VolumeDriver.Create", c.create("vol1", plugin.Options))
+	resp := c.mount("vol1", "c1")
+	requireNoErr(t, "VolumeDriver.Mount", resp)
+	mountpoint := stringField(t, "VolumeDriver.Mount", resp, "Mountpoint")
+	requireNoErr(t, "VolumeDriver.Mount", c.mount("vol1", "c2"))
+
+	requireNoErr(t, "VolumeDriver.Unmount", c.unmount("vol1", "c1"))
+	requireErr(t, "VolumeDriver.Unmount", c.unmount("vol1", "c1"))
+	if path := c.path("vol1"); path != mountpoint {
+		t.Fatalf("VolumeDriver.Path: %q while c2 is mounted, want %q", path, mountpoint)
+	}
+
+	requireNoErr(t, "VolumeDriver.Unmount", c.unmount("vol1", "c2"))
+	if path := c.path("vol1"); path != "" {
+		t.Fatalf("VolumeDriver.Path: %q after the last unmount, want empty", path)
+	}
+}
+
+// testFieldNames checks that every response uses the field names of the
+// protocol, with their exact casing, and nothing else.
+func testFieldNames(t *testing.T, c *client, plugin Plugin) {
+	checkFields(t, "Plugin.Activate", c.call("Plugin.Activate", nil), []string{"Implements"})
+	checkFields(t, "VolumeDriver.Create", c.create("vol1", plugin.Options), []string{"Err"})
+
+	resp := c.mount("vol1", "c1")
+	checkFields(t, "VolumeDriver.Mount", resp, []string{"Mountpoint", "Err"})
+	checkFields(t, "VolumeDriver.Path", c.call("VolumeDriver.Path", map[string]string{"Name": "vol1"}), []string{"Mountpoint", "Err"})
+
+	resp = c.call("VolumeDriver.Get", map[string]string{"Name": "vol1"})
+	checkFields(t, "VolumeDriver.Get", resp, []string{"Volume", "Err"})
+	checkFields(t, "VolumeDriver.Get", objectField(t, "VolumeDriver.Get", resp, "Volume"), []string{"Name"}, "Mountpoint", "CreatedAt", "Status")
+
+	resp = c.call("VolumeDriver.List", nil)
+	checkFields(t, "VolumeDriver.List", resp, []string{"Volumes", "Err"})
+	for _, v := range volumes(t, resp) {
+		checkFields(t, "VolumeDriver.List", v, []string{"Name"}, "Mountpoint", "CreatedAt", "Status")
+	}
+
+	resp = c.call("VolumeDriver.Capabilities", nil)
+	checkFields(t, "VolumeDriver.Capabilities", resp, []string{"Capabilities"})
+	checkFields(t, "VolumeDriver.Capabilities", objectField(t, "VolumeDriver.Capabilities", resp, "Capabilities"), []string{"Scope"})
+
+	checkFields(t, "VolumeDriver.Unmount", c.unmount("vol1", "c1"), []string{"Err"})
+	checkFields(t, "VolumeDriver.Remove", c.call("VolumeDriver.Remove", map[string]string{"Name": "vol1"}), []string{"Err"})
+
+	// Errors use the same fields
+	checkFields(t, "VolumeDriver.Path", c.call("VolumeDriver.Path", map[string]string{"Name": missing}), []string{"Err"}, "Mountpoint")
+	checkFields(t, "VolumeDriver.Get", c.call("VolumeDriver.Get", map[string]string{"Name": missing}), []string{"Err"}, "Volume")
+}
+
+// checkFields fails the test unless an object has every required field
+// and otherwise only optional ones, compared case-sensitively.
+func checkFields(t *testing.T, endpoint string, object response, required []string, optional ...string) {
+	t.Helper()
+	allowed := make(map[string]bool, len(required)+len(optional))
+	for _, field := range required {
+		if _, ok := object[field]; !ok {
+			t.Errorf("%s: response has no %s field: %s", endpoint, field, fieldNames(object))
+		}
+		allowed[field] = true
+	}
+	for _, field := range optional {
+		allowed[field] = true
+	}
+	for _, field := range fieldNames(object) {
+		if !allowed[field] {
+			t.Errorf("%s: response has unexpected field %s", endpoint, field)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+// Package volumetest checks that a volume plugin speaks the Docker volume
+// plugin protocol: every VolumeDriver endpoint, the errors for unknown
+// volumes, idempotent Create, Mount/Unmount pairing by ID and the JSON
+// field names Docker decodes. Backends run it against a plugin serving
+// them, such as utils.NewHandler of a driver.
+package volumetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+)
+
+// contentType is the media type of Docker plugin API messages.
+const contentType = "application/vnd.docker.plugins.v1.2+json"
+
+// missing names a volume the suite never creates.
+const missing = "volumetest-missing"
+
+// Plugin describes the plugin under test.
+type Plugin struct {
+	// New returns the protocol handler of a new plugin without volumes.
+	// Every test of the suite gets its own plugin.
+	New func(t *testing.T) http.Handler
+
+	// Options are the driver options of the volumes the suite creates;
+	// the plugin must be able to mount them
+	Options map[string]string
+
+	// Conflicting are valid driver options that differ from Options
+	Conflicting map[string]string
+}
+
+// Run runs the conformance suite against a plugin, one subtest per
+// property.
+//
+// Parameters:
+// - t: The test
+// - plugin: The plugin under test
+func Run(t *testing.T, plugin Plugin) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, c *client, plugin Plugin)
+	}{
+		{"Activate", testActivate},
+		{"Capabilities", testCapabilities},
+		{"UnknownVolume", testUnknownVolume},
+		{"CreateIdempotent", testCreateIdempotent},
+		{"CreateConflict", testCreateConflict},
+		{"MountUnmountPairing", testMountUnmountPairing},
+		{"UnmountUnknownID", testUnmountUnknownID},
+		{"DuplicateUnmount", testDuplicateUnmount},
+		{"FieldNames", testFieldNames},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, &client{t: t, handler: plugin.New(t)}, plugin)
+		})
+	}
+}
+
+// response is a decoded plugin response, by JSON field name.
+type response map[string]json.RawMessage
+
+// client calls a plugin handler.
+type client struct {
+	t       *testing.T
+	handler http.Handler
+}
+
+// call posts a request to an endpoint of the plugin and decodes the
+// response, failing the test if it is not a JSON object.
+func (c *client) call(endpoint string, req interface{}) response {
+	c.t.Helper()
+	body, err := json.Marshal(req)
+	if err != nil {
+		c.t.Fatalf("%s: cannot encode request: %v", endpoint, err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/"+endpoint, bytes.NewReader(body))
+	r.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	c.handler.ServeHTTP(rec, r)
+
+	var resp response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		c.t.Fatalf("%s: response %q is not a JSON object: %v", endpoint, rec.Body.String(), err)
+	}
+	return resp
+}
+
+// create creates a volume in the form Docker sends.
+func (c *client) create(name string, options map[string]string) response {
+	c.t.Helper()
+	return c.call("VolumeDriver.Create", map[string]interface{}{"Name": name, "Opts": options})
+}
+
+// mount mounts a volume for a container in the form Docker sends.
+func (c *client) mount(name, id string) response {
+	c.t.Helper()
+	return c.call("VolumeDriver.Mount", map[string]string{"Name": name, "ID": id})
+}
+
+// unmount releases the mount of a container in the form Docker sends.
+func (c *client) unmount(name, id string) response {
+	c.t.Helper()
+	return c.call("VolumeDriver.Unmount", map[string]string{"Name": name, "ID": id})
+}
+
+// path returns the mount point the plugin reports for a volume.
+func (c *client) path(name string) string {
+	c.t.Helper()
+	resp := c.call("VolumeDriver.Path", map[string]string{"Name": name})
+	requireNoErr(c.t, "VolumeDriver.Path", resp)
+	return stringField(c.t, "VolumeDriver.Path", resp, "Mountpoint")
+}
+
+// errOf returns the Err field of a response, failing the test if it is
+// missing or not a string.
+func errOf(t *testing.T, endpoint string, resp response) string {
+	t.Helper()
+	return stringField(t, endpoint, resp, "Err")
+}
+
+// requireNoErr fails the test if a response reports an error.
+func requireNoErr(t *testing.T, endpoint string, resp response) {
+	t.Helper()
+	if err := errOf(t, endpoint, resp); err != "" {
+		t.Fatalf("%s: unexpected Err %q", endpoint, err)
+	}
+}
+
+// requireErr fails the test if a response does not report an error.
+func requireErr(t *testing.T, endpoint string, resp response) {
+	t.Helper()
+	if errOf(t, endpoint, resp) == "" {
+		t.Fatalf("%s: Err is empty, want an error", endpoint)
+	}
+}
+
+// stringField decodes a string field of a response, failing the test if
+// it is missing or not a string.
+func stringField(t *testing.T, endpoint string, resp response, field string) string {
+	t.Helper()
+	raw, ok := resp[field]
+	if !ok {
+		t.Fatalf("%s: response has no %s field: %s", endpoint, field, fieldNames(resp))
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		t.Fatalf("%s: %s is %s, want a string", endpoint, field, raw)
+	}
+	return s
+}
+
+// objectField decodes an object field of a response, failing the test
+// if it is missing or not an object.
+func objectField(t *testing.T, endpoint string, resp response, field string) response {
+	t.Helper()
+	raw, ok := resp[field]
+	if !ok {
+		t.Fatalf("%s: response has no %s field: %s", endpoint, field, fieldNames(resp))
+	}
+	var object response
+	if err := json.Unmarshal(raw, &object); err != nil || object == nil {
+		t.Fatalf("%s: %s is %s, want an object", endpoint, field, raw)
+	}
+	return object
+}
+
+// volumes decodes the Volumes field of a List response, failing the
+// test if it is missing or not an array.
+func volumes(t *testing.T, resp response) []response {
+	t.Helper()
+	raw, ok := resp["Volumes"]
+	if !ok {
+		t.Fatalf("VolumeDriver.List: response has no Volumes field: %s", fieldNames(resp))
+	}
+	var list []response
+	if err := json.Unmarshal(raw, &list); err != nil || list == nil {
+		t.Fatalf("VolumeDriver.List: Volumes is %s, want an array", raw)
+	}
+	return list
+}
+
+// fieldNames lists the fields of a response, sorted, for failure
+// messages.
+func fieldNames(resp response) []string {
+	names := make([]string, 0, len(resp))
+	for name := range resp {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}